@@ -1,28 +1,79 @@
 package main
 
 import (
+	"context"
 	"github.com/joho/godotenv"
 	"log"
+	"os"
+	"prReviewerAssignment/internal/clock"
 	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/directory"
 	"prReviewerAssignment/internal/handlers"
+	"prReviewerAssignment/internal/notify"
 	"prReviewerAssignment/internal/routes"
+	"prReviewerAssignment/internal/scheduler"
+	"prReviewerAssignment/internal/services"
+	"time"
 )
 
 func main() {
 	godotenv.Load()
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(os.Args[2:]))
+	}
+
 	if err := db.InitDB(); err != nil {
-		log.Fatal("failed to connect to the database: ", err)
+		log.Fatal("failed to set up the database: ", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "reconcile" {
+		os.Exit(runReconcile(os.Args[2:]))
 	}
 
+	if err := notify.Init(services.NewUserService(), services.NewTeamService()); err != nil {
+		log.Fatal("failed to configure notifications: ", err)
+	}
+
+	jobs := scheduler.New(clock.Real{})
+	jobs.Every("sla", durationFromEnv("SLA_CHECK_INTERVAL", 5*time.Minute), services.NewSLAService().CheckBreaches)
+	jobs.Every("digest", durationFromEnv("DIGEST_CHECK_INTERVAL", 5*time.Minute), services.NewDigestService().SendDigests)
+	idempotencyTTL := durationFromEnv("IDEMPOTENCY_TTL", 24*time.Hour)
+	jobs.Every("idempotency-purge", time.Hour, services.NewIdempotencyService(idempotencyTTL).PurgeExpired)
+	if config := directory.ConfigFromEnv(); config.URL != "" {
+		jobs.Every("ldap-sync", durationFromEnv("LDAP_SYNC_INTERVAL", 15*time.Minute), services.NewLDAPSyncService(config).Sync)
+	}
+	go jobs.Run(context.Background())
+
 	teamHandler := handlers.NewTeamHandler()
 	userHandler := handlers.NewUserHandler()
 	prHandler := handlers.NewPRHandler()
 	statsHandler := handlers.NewStatsHandler()
+	adminHandler := handlers.NewAdminHandler()
+	scimHandler := handlers.NewSCIMHandler()
+	idempotencyHandler := handlers.NewIdempotencyHandler(idempotencyTTL)
+	v2Handler := handlers.NewV2Handler()
+	docsHandler, err := handlers.NewDocsHandler()
+	if err != nil {
+		log.Fatal("failed to load the OpenAPI document: ", err)
+	}
 
-	router := routes.SetupRouter(teamHandler, userHandler, prHandler, statsHandler)
+	router := routes.SetupRouter(teamHandler, userHandler, prHandler, statsHandler, adminHandler, scimHandler, idempotencyHandler, docsHandler, v2Handler)
 
 	if err := router.Run(":8080"); err != nil {
 		log.Fatal("failed to start server: ", err)
 	}
-}
\ No newline at end of file
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return duration
+}
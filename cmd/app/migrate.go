@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/db/migrations"
+)
+
+// runMigrate implements "migrate up|down [-steps N]|status": it applies the
+// pending migrations, reverts the last N applied ones or lists every
+// migration with the time it was applied. It returns the exit code.
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	steps := flags.Int("steps", 1, "number of migrations to revert with down")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: app migrate up|down [-steps N]|status")
+		flags.PrintDefaults()
+	}
+	if len(args) == 0 {
+		flags.Usage()
+		return 2
+	}
+	command := args[0]
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 0 || (command == "down" && *steps < 1) {
+		flags.Usage()
+		return 2
+	}
+
+	// status only reads, so it leaves an unversioned database to up.
+	open := db.Open
+	if command == "status" {
+		open = db.Connect
+	}
+	conn, err := open()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to connect to the database:", err)
+		return 1
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrations.Up(conn)
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations.")
+		}
+	case "down":
+		reverted, err := migrations.Down(conn, *steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations.")
+		}
+	case "status":
+		states, err := migrations.Status(conn)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.AppliedAt != nil {
+				appliedAt = "applied " + state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s  %s\n", state.Version, state.Name, appliedAt)
+		}
+	default:
+		flags.Usage()
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/services"
+	"strings"
+)
+
+// runReconcile implements "reconcile [-apply] FILE": it prints the plan that
+// converges the database to the roster spec in FILE, "-" for stdin, and with
+// -apply applies it. It returns the exit code.
+func runReconcile(args []string) int {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	apply := flags.Bool("apply", false, "apply the plan after printing it")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: app reconcile [-apply] FILE")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	var data []byte
+	var err error
+	if path := flags.Arg(0); path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to read roster spec:", err)
+		return 1
+	}
+
+	service := services.NewReconcileService()
+	plan, err := service.Reconcile(data, false)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	printPlan(os.Stdout, plan.Changes)
+	if len(plan.Changes) == 0 {
+		return 0
+	}
+	if !*apply {
+		fmt.Println("Run with -apply to apply this plan.")
+		return 0
+	}
+
+	result, err := service.Reconcile(data, true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to apply plan:", err)
+		return 1
+	}
+	fmt.Printf("Applied %d changes.\n", len(result.Changes))
+	for _, handoff := range result.Handoffs {
+		newReviewer := handoff.NewReviewerID
+		if newReviewer == "" {
+			newReviewer = "nobody"
+		}
+		fmt.Printf("  review of %s handed off from %s to %s\n", handoff.PullRequestID, handoff.OldReviewerID, newReviewer)
+	}
+
+	return 0
+}
+
+func printPlan(w io.Writer, changes []models.RosterChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "No changes: the database matches the roster spec.")
+		return
+	}
+
+	fmt.Fprintf(w, "Plan: %d changes\n", len(changes))
+	for _, change := range changes {
+		symbol := "~"
+		switch change.Action {
+		case models.RosterCreateTeam, models.RosterCreateUser, models.RosterAddMember:
+			symbol = "+"
+		case models.RosterDeactivateMember:
+			symbol = "-"
+		}
+
+		target := change.TeamName
+		if change.UserID != "" {
+			target = change.UserID + " in " + change.TeamName
+		}
+		line := fmt.Sprintf("  %s %s %s", symbol, change.Action, target)
+		if len(change.Fields) > 0 {
+			line += ": " + strings.Join(change.Fields, ", ")
+		}
+		fmt.Fprintln(w, line)
+	}
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"gorm.io/datatypes"
+	"time"
+)
+
+// The models as they were when migration 0001_create_tables was written.
+// adoptUnversioned migrates unversioned databases to exactly this schema, so
+// these must not follow later changes of the models.
+
+type legacyUser struct {
+	UserID       string `gorm:"primaryKey"`
+	Username     string `gorm:"not null"`
+	TeamName     string `gorm:"not null"`
+	IsActive     bool   `gorm:"not null;default:true"`
+	Email        string
+	ChatHandle   string
+	Timezone     string     `gorm:"not null;default:'UTC'"`
+	WorkStart    string     `gorm:"type:varchar(5);not null;default:'09:00'"`
+	WorkEnd      string     `gorm:"type:varchar(5);not null;default:'18:00'"`
+	Capacity     int        `gorm:"not null;default:0"`
+	OOOFrom      *time.Time `gorm:"column:ooo_from"`
+	OOOUntil     *time.Time `gorm:"column:ooo_until"`
+	LastDigestAt *time.Time
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime"`
+}
+
+func (legacyUser) TableName() string {
+	return "users"
+}
+
+type legacyTeamMembership struct {
+	TeamName  string    `gorm:"primaryKey"`
+	UserID    string    `gorm:"primaryKey"`
+	Role      string    `gorm:"type:varchar(20);not null;default:'MEMBER'"`
+	IsActive  bool      `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (legacyTeamMembership) TableName() string {
+	return "team_memberships"
+}
+
+type legacyTeam struct {
+	TeamName       string    `gorm:"primaryKey"`
+	ParentTeamName string    `gorm:"index"`
+	MinReviewers   int       `gorm:"not null;default:0"`
+	MaxReviewers   int       `gorm:"not null;default:2"`
+	SLAHours       int       `gorm:"not null;default:0"`
+	SLAPolicy      string    `gorm:"type:varchar(20);not null;default:'ESCALATE'"`
+	Strategy       string    `gorm:"column:assignment_strategy;type:varchar(20);not null;default:'RANDOM'"`
+	DigestTime     string    `gorm:"type:varchar(5)"`
+	QuietStart     string    `gorm:"column:quiet_hours_start;type:varchar(5)"`
+	QuietEnd       string    `gorm:"column:quiet_hours_end;type:varchar(5)"`
+	ChatWebhook    string    `gorm:"column:chat_webhook_url"`
+	CreatedAt      time.Time `gorm:"autoCreateTime"`
+}
+
+func (legacyTeam) TableName() string {
+	return "team_dbs"
+}
+
+type legacyPullRequest struct {
+	PullRequestID     string `gorm:"primaryKey"`
+	PullRequestName   string `gorm:"not null"`
+	AuthorID          string `gorm:"not null"`
+	TeamName          string `gorm:"not null;default:''"`
+	Status            string `gorm:"type:varchar(20);not null;default:'OPEN';check:status IN ('OPEN', 'MERGED')"`
+	AssignedReviewers datatypes.JSON
+	CreatedAt         time.Time `gorm:"autoCreateTime"`
+	MergedAt          *time.Time
+
+	Author legacyUser `gorm:"foreignKey:AuthorID;references:UserID"`
+}
+
+func (legacyPullRequest) TableName() string {
+	return "pull_requests"
+}
+
+type legacyReviewDecline struct {
+	PullRequestID string `gorm:"primaryKey"`
+	ReviewerID    string `gorm:"primaryKey"`
+	Reason        string `gorm:"type:varchar(30);not null"`
+	ReplacedBy    string
+	CreatedAt     time.Time `gorm:"autoCreateTime"`
+}
+
+func (legacyReviewDecline) TableName() string {
+	return "review_declines"
+}
+
+type legacyReviewAssignment struct {
+	PullRequestID string    `gorm:"primaryKey"`
+	ReviewerID    string    `gorm:"primaryKey"`
+	AssignedAt    time.Time `gorm:"not null"`
+	EscalatedAt   *time.Time
+}
+
+func (legacyReviewAssignment) TableName() string {
+	return "review_assignments"
+}
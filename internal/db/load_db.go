@@ -1,36 +1,154 @@
 package db
 
 import (
+	"errors"
 	"fmt"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"os"
-	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/db/migrations"
+	"prReviewerAssignment/internal/repository"
 )
 
-var DB *gorm.DB
+var (
+	DB    *gorm.DB
+	Store repository.Store
+)
+
+const defaultSQLitePath = "pr_reviewer.db"
 
+// InitDB sets up Store for the backend named by DATABASE_DRIVER: postgres,
+// the default, sqlite, which keeps everything in the file named by
+// DATABASE_PATH, or memory, which keeps everything in the process and is
+// meant for demos and tests. DB is only set for postgres and sqlite, whose
+// pending migrations are applied first. It refuses a database migrated by a
+// newer build.
 func InitDB() error {
-	dsn := fmt.Sprintf(
-		"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
-		os.Getenv("DATABASE_HOST"),
-		os.Getenv("DATABASE_USER"),
-		os.Getenv("DATABASE_PASSWORD"),
-		os.Getenv("DATABASE_NAME"),
-		os.Getenv("DATABASE_PORT"),
-		os.Getenv("SSL_MODE"),
-	)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		return err
+	if os.Getenv("DATABASE_DRIVER") == "memory" {
+		Store = repository.NewMemoryStore()
+		return nil
 	}
 
-	err = db.AutoMigrate(&models.User{}, &models.TeamDB{}, &models.PullRequest{})
+	db, err := Open()
 	if err != nil {
 		return err
 	}
+	if _, err := migrations.Up(db); err != nil {
+		return err
+	}
 
 	DB = db
+	Store = repository.NewGormStore(db)
 	return nil
 }
+
+// Open connects to the postgres or sqlite database named by the environment
+// without applying migrations. A database whose schema was created by
+// AutoMigrate before migrations were versioned is brought up to date and
+// recorded as being at the first migration.
+func Open() (*gorm.DB, error) {
+	db, err := Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := adoptUnversioned(db); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Connect connects to the database like Open but leaves it untouched, for
+// commands that only read the schema state.
+func Connect() (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver := os.Getenv("DATABASE_DRIVER"); driver {
+	case "", "postgres":
+		dialector = postgres.Open(fmt.Sprintf(
+			"host=%s user=%s password=%s dbname=%s port=%s sslmode=%s",
+			os.Getenv("DATABASE_HOST"),
+			os.Getenv("DATABASE_USER"),
+			os.Getenv("DATABASE_PASSWORD"),
+			os.Getenv("DATABASE_NAME"),
+			os.Getenv("DATABASE_PORT"),
+			os.Getenv("SSL_MODE"),
+		))
+	case "sqlite":
+		path := os.Getenv("DATABASE_PATH")
+		if path == "" {
+			path = defaultSQLitePath
+		}
+		dialector = sqlite.Open(path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
+	case "memory":
+		return nil, errors.New("the memory driver has no schema")
+	default:
+		return nil, fmt.Errorf("unknown DATABASE_DRIVER %q", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if dialector.Name() == "sqlite" {
+		// SQLite allows a single writer; sharing one connection queues
+		// transactions instead of failing them with "database is locked".
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
+
+// adoptUnversioned finishes what AutoMigrate used to do on every start for a
+// database that has tables but no schema_migrations yet, then baselines it at
+// 0001_create_tables, which matches that schema.
+func adoptUnversioned(db *gorm.DB) error {
+	if migrations.Versioned(db) || !db.Migrator().HasTable(&legacyUser{}) {
+		return nil
+	}
+
+	err := db.AutoMigrate(&legacyUser{}, &legacyTeam{}, &legacyPullRequest{}, &legacyReviewDecline{}, &legacyReviewAssignment{}, &legacyTeamMembership{})
+	if err != nil {
+		return err
+	}
+	if err := backfillMemberships(db); err != nil {
+		return err
+	}
+
+	return migrations.Baseline(db, 1)
+}
+
+// backfillMemberships carries data from before team memberships over: every
+// user is a member of their home team and every pull request belongs to the
+// home team of its author.
+func backfillMemberships(db *gorm.DB) error {
+	err := db.Exec(`INSERT INTO team_memberships (team_name, user_id, role, is_active)
+		SELECT team_name, user_id, 'MEMBER', true FROM users
+		WHERE team_name <> '' AND NOT EXISTS (SELECT 1 FROM team_memberships m WHERE m.user_id = users.user_id)`).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Exec(`UPDATE pull_requests SET team_name = (SELECT team_name FROM users WHERE users.user_id = pull_requests.author_id)
+		WHERE team_name = ''`).Error
+	if err != nil {
+		return err
+	}
+
+	// Team leads used to be a column of teams and are a membership role now.
+	if !db.Migrator().HasColumn(&legacyTeam{}, "lead_user_id") {
+		return nil
+	}
+	err = db.Exec(`UPDATE team_memberships SET role = 'LEAD'
+		WHERE EXISTS (SELECT 1 FROM team_dbs t WHERE t.team_name = team_memberships.team_name AND t.lead_user_id = team_memberships.user_id)`).Error
+	if err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&legacyTeam{}, "lead_user_id")
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"prReviewerAssignment/internal/db/migrations"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestAdoptUnversioned starts from a database the way AutoMigrate left it
+// before migrations were versioned, with a team lead still kept on the team.
+func TestAdoptUnversioned(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:?_pragma=foreign_keys(1)"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), TranslateError: true})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(&legacyUser{}, &legacyTeam{}, &legacyPullRequest{}))
+	require.NoError(t, db.Exec("ALTER TABLE team_dbs ADD COLUMN lead_user_id text").Error)
+	require.NoError(t, db.Exec("INSERT INTO team_dbs (team_name, lead_user_id) VALUES ('backend', 'u1')").Error)
+	require.NoError(t, db.Create(&legacyUser{UserID: "u1", Username: "Alice", TeamName: "backend"}).Error)
+	require.NoError(t, db.Create(&legacyPullRequest{PullRequestID: "pr-1", PullRequestName: "First", AuthorID: "u1"}).Error)
+
+	require.NoError(t, adoptUnversioned(db))
+	states, err := migrations.Status(db)
+	require.NoError(t, err)
+	assert.NotNil(t, states[0].AppliedAt)
+	assert.Nil(t, states[1].AppliedAt)
+
+	_, err = migrations.Up(db)
+	require.NoError(t, err)
+	require.NoError(t, adoptUnversioned(db))
+
+	var membership legacyTeamMembership
+	require.NoError(t, db.First(&membership).Error)
+	assert.Equal(t, "backend", membership.TeamName)
+	assert.Equal(t, "LEAD", membership.Role)
+	var pr legacyPullRequest
+	require.NoError(t, db.First(&pr).Error)
+	assert.Equal(t, "backend", pr.TeamName)
+	assert.False(t, db.Migrator().HasColumn(&legacyTeam{}, "lead_user_id"))
+}
+
+// TestConnectLeavesUnversioned checks that reading the migration status of
+// an unversioned database, as "migrate status" does, changes nothing.
+func TestConnectLeavesUnversioned(t *testing.T) {
+	t.Setenv("DATABASE_DRIVER", "sqlite")
+	t.Setenv("DATABASE_PATH", filepath.Join(t.TempDir(), "reviews.db"))
+	db, err := Connect()
+	require.NoError(t, err)
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	require.NoError(t, db.AutoMigrate(&legacyUser{}, &legacyTeam{}, &legacyPullRequest{}))
+
+	states, err := migrations.Status(db)
+	require.NoError(t, err)
+	for _, state := range states {
+		assert.Nil(t, state.AppliedAt, state.Name)
+	}
+	assert.False(t, migrations.Versioned(db))
+	assert.False(t, db.Migrator().HasTable(&legacyTeamMembership{}))
+}
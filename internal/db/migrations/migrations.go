@@ -0,0 +1,279 @@
+// Package migrations keeps the database schema as numbered SQL migrations,
+// one directory per dialect, and records the applied ones in the
+// schema_migrations table.
+package migrations
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+var ErrUnknownVersion = errors.New("unknown schema version")
+
+// Migration is one numbered schema change. Its files are named
+// NNNN_name.up.sql and NNNN_name.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// State is a migration together with the time it was applied, nil while it
+// is pending.
+type State struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+type schemaMigration struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"not null"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Load returns the migrations of dialect sorted by version.
+func Load(dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction := strings.TrimSuffix(name, ".sql"), ""
+		switch {
+		case strings.HasSuffix(base, ".up"):
+			base, direction = strings.TrimSuffix(base, ".up"), "up"
+		case strings.HasSuffix(base, ".down"):
+			base, direction = strings.TrimSuffix(base, ".down"), "down"
+		default:
+			return nil, fmt.Errorf("migration %s is neither up nor down", name)
+		}
+		number, title, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no version", name)
+		}
+
+		data, err := files.ReadFile(path.Join(dialect, name))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: title}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Status lists every known migration and when it was applied. It only reads:
+// without a schema_migrations table every migration is pending. It fails
+// with ErrUnknownVersion when the database holds migrations this build does
+// not know, which means it was migrated by a newer build.
+func Status(db *gorm.DB) ([]State, error) {
+	migrations, err := Load(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []State
+	for _, migration := range migrations {
+		state := State{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			appliedAt := record.AppliedAt
+			state.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		states = append(states, state)
+	}
+	if len(applied) > 0 {
+		var unknown []string
+		for version := range applied {
+			unknown = append(unknown, strconv.Itoa(version))
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("%w: database has migrations %s", ErrUnknownVersion, strings.Join(unknown, ", "))
+	}
+
+	return states, nil
+}
+
+// Up applies the pending migrations in order, each in a transaction of its
+// own, and returns the ones it applied.
+func Up(db *gorm.DB) ([]Migration, error) {
+	if err := createTable(db); err != nil {
+		return nil, err
+	}
+	states, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, state := range states {
+		if state.AppliedAt != nil {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, state.Up); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: state.Version, Name: state.Name, AppliedAt: time.Now().UTC()}).Error
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %w", state.Version, state.Name, err)
+		}
+		applied = append(applied, state.Migration)
+	}
+
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones it reverted.
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+	states, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		state := states[i]
+		if state.AppliedAt == nil {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, state.Down); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: state.Version}).Error
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %04d_%s: %w", state.Version, state.Name, err)
+		}
+		reverted = append(reverted, state.Migration)
+	}
+
+	return reverted, nil
+}
+
+// Baseline records the migrations up to version as applied without running
+// them, for databases whose schema was created before migrations were
+// versioned.
+func Baseline(db *gorm.DB, version int) error {
+	if err := createTable(db); err != nil {
+		return err
+	}
+	states, err := Status(db)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, state := range states {
+			if state.Version > version || state.AppliedAt != nil {
+				continue
+			}
+			record := schemaMigration{Version: state.Version, Name: state.Name, AppliedAt: time.Now().UTC()}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Versioned reports whether the database has a schema_migrations table.
+func Versioned(db *gorm.DB) bool {
+	return db.Migrator().HasTable(&schemaMigration{})
+}
+
+// createTable creates the schema_migrations table unless it exists.
+func createTable(db *gorm.DB) error {
+	if Versioned(db) {
+		return nil
+	}
+	return db.Exec(`CREATE TABLE schema_migrations (
+		version integer PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp NOT NULL
+	)`).Error
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]schemaMigration, error) {
+	if !Versioned(db) {
+		return map[int]schemaMigration{}, nil
+	}
+
+	var records []schemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]schemaMigration)
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// execScript runs the statements of a migration file one at a time; every
+// statement ends with a semicolon at the end of a line.
+func execScript(tx *gorm.DB, script string) error {
+	for _, statement := range strings.Split(script, ";\n") {
+		var lines []string
+		for _, line := range strings.Split(statement, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		statement = strings.TrimSuffix(strings.TrimSpace(strings.Join(lines, "\n")), ";")
+		if statement == "" {
+			continue
+		}
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
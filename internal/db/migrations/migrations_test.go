@@ -0,0 +1,193 @@
+package migrations
+
+import (
+	"os"
+	"testing"
+
+	"prReviewerAssignment/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var tables = []string{"idempotency_keys", "review_assignments", "review_declines", "pull_requests", "team_memberships", "users", "team_dbs", "schema_migrations"}
+
+// testMigrations runs every test against an empty database made by open.
+func testMigrations(t *testing.T, open func(t *testing.T) *gorm.DB) {
+	tests := map[string]func(t *testing.T, db *gorm.DB){
+		"UpAndDown":      testUpAndDown,
+		"MatchesModels":  testMatchesModels,
+		"Constraints":    testConstraints,
+		"UnknownVersion": testUnknownVersion,
+		"Baseline":       testBaseline,
+		"StatusReadOnly": testStatusReadOnly,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, open(t))
+		})
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	testMigrations(t, func(t *testing.T) *gorm.DB {
+		return openDB(t, sqlite.Open(":memory:?_pragma=foreign_keys(1)"))
+	})
+}
+
+// TestPostgres runs against the database TEST_POSTGRES_DSN points to,
+// dropping its tables before every test.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	testMigrations(t, func(t *testing.T) *gorm.DB {
+		return openDB(t, postgres.Open(dsn))
+	})
+}
+
+func openDB(t *testing.T, dialector gorm.Dialector) *gorm.DB {
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), TranslateError: true})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: opens a database of its own.
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	for _, table := range tables {
+		require.NoError(t, db.Migrator().DropTable(table))
+	}
+	return db
+}
+
+func versions(migrations []Migration) []int {
+	versions := []int{}
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func testUpAndDown(t *testing.T, db *gorm.DB) {
+	applied, err := Up(db)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, versions(applied))
+
+	states, err := Status(db)
+	require.NoError(t, err)
+	for _, state := range states {
+		assert.NotNil(t, state.AppliedAt, state.Name)
+	}
+	applied, err = Up(db)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := Down(db, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{4}, versions(reverted))
+	states, err = Status(db)
+	require.NoError(t, err)
+	assert.NotNil(t, states[2].AppliedAt)
+	assert.Nil(t, states[3].AppliedAt)
+
+	reverted, err = Down(db, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 2, 1}, versions(reverted))
+	assert.False(t, db.Migrator().HasTable("users"))
+
+	applied, err = Up(db)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4}, versions(applied))
+}
+
+func testMatchesModels(t *testing.T, db *gorm.DB) {
+	_, err := Up(db)
+	require.NoError(t, err)
+
+	for _, model := range []interface{}{
+		&models.User{}, &models.TeamDB{}, &models.TeamMembership{},
+		&models.PullRequest{}, &models.ReviewAssignment{}, &models.ReviewDecline{},
+		&models.IdempotencyKey{},
+	} {
+		statement := &gorm.Statement{DB: db}
+		require.NoError(t, statement.Parse(model))
+		for _, field := range statement.Schema.Fields {
+			if field.DBName != "" {
+				assert.True(t, db.Migrator().HasColumn(model, field.DBName), "%s.%s", statement.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func testConstraints(t *testing.T, db *gorm.DB) {
+	_, err := Up(db)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Create(&models.TeamDB{TeamName: "backend"}).Error)
+	require.NoError(t, db.Create(&models.User{UserID: "u1", Username: "Alice", TeamName: "backend"}).Error)
+	require.NoError(t, db.Create(&models.User{UserID: "u2", Username: "Bob", TeamName: "backend"}).Error)
+	require.NoError(t, db.Create(&models.TeamMembership{TeamName: "backend", UserID: "u1", IsActive: true}).Error)
+
+	err = db.Create(&models.TeamMembership{TeamName: "missing", UserID: "u1"}).Error
+	assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+	err = db.Create(&models.TeamMembership{TeamName: "backend", UserID: "u2", Role: "OWNER"}).Error
+	assert.Error(t, err)
+	err = db.Create(&models.TeamDB{TeamName: "frontend", Strategy: "ROUND_ROBIN"}).Error
+	assert.Error(t, err)
+
+	require.NoError(t, db.Create(&models.PullRequest{PullRequestID: "pr-1", PullRequestName: "First", AuthorID: "u1", Status: "OPEN"}).Error)
+	require.NoError(t, db.Create(&models.ReviewDecline{PullRequestID: "pr-1", ReviewerID: "u1", Reason: models.DeclineReasonBusy}).Error)
+	err = db.Create(&models.ReviewDecline{PullRequestID: "pr-1", ReviewerID: "missing", Reason: models.DeclineReasonBusy}).Error
+	assert.ErrorIs(t, err, gorm.ErrForeignKeyViolated)
+
+	require.NoError(t, db.Where("team_name = ?", "backend").Delete(&models.TeamDB{}).Error)
+	var memberships int64
+	require.NoError(t, db.Model(&models.TeamMembership{}).Count(&memberships).Error)
+	assert.Zero(t, memberships)
+	require.NoError(t, db.Where("pull_request_id = ?", "pr-1").Delete(&models.PullRequest{}).Error)
+	var declines int64
+	require.NoError(t, db.Model(&models.ReviewDecline{}).Count(&declines).Error)
+	assert.Zero(t, declines)
+}
+
+func testUnknownVersion(t *testing.T, db *gorm.DB) {
+	_, err := Up(db)
+	require.NoError(t, err)
+	require.NoError(t, db.Create(&schemaMigration{Version: 999, Name: "from_the_future"}).Error)
+
+	_, err = Status(db)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	_, err = Up(db)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+	_, err = Down(db, 1)
+	assert.ErrorIs(t, err, ErrUnknownVersion)
+}
+
+func testBaseline(t *testing.T, db *gorm.DB) {
+	assert.False(t, Versioned(db))
+	migrations, err := Load(db.Dialector.Name())
+	require.NoError(t, err)
+	require.NoError(t, execScript(db, migrations[0].Up))
+
+	require.NoError(t, Baseline(db, 1))
+	assert.True(t, Versioned(db))
+	applied, err := Up(db)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3, 4}, versions(applied))
+}
+
+func testStatusReadOnly(t *testing.T, db *gorm.DB) {
+	states, err := Status(db)
+	require.NoError(t, err)
+	assert.Len(t, states, 4)
+	for _, state := range states {
+		assert.Nil(t, state.AppliedAt, state.Name)
+	}
+	assert.False(t, Versioned(db))
+}
@@ -0,0 +1,6 @@
+DROP TABLE review_assignments;
+DROP TABLE review_declines;
+DROP TABLE pull_requests;
+DROP TABLE team_memberships;
+DROP TABLE users;
+DROP TABLE team_dbs;
@@ -0,0 +1,28 @@
+DROP INDEX idx_review_assignments_reviewer_id;
+DROP INDEX idx_review_declines_reviewer_id;
+DROP INDEX idx_pull_requests_team_status;
+DROP INDEX idx_pull_requests_status;
+DROP INDEX idx_pull_requests_author_id;
+DROP INDEX idx_team_memberships_user_id;
+DROP INDEX idx_users_team_active;
+
+ALTER TABLE review_assignments
+    DROP CONSTRAINT fk_review_assignments_reviewer,
+    DROP CONSTRAINT fk_review_assignments_pull_request;
+
+ALTER TABLE review_declines
+    DROP CONSTRAINT chk_review_declines_reason,
+    DROP CONSTRAINT fk_review_declines_reviewer,
+    DROP CONSTRAINT fk_review_declines_pull_request;
+
+ALTER TABLE team_memberships
+    DROP CONSTRAINT chk_team_memberships_role,
+    DROP CONSTRAINT fk_team_memberships_user,
+    DROP CONSTRAINT fk_team_memberships_team;
+
+ALTER TABLE users
+    DROP CONSTRAINT chk_users_capacity;
+
+ALTER TABLE team_dbs
+    DROP CONSTRAINT chk_team_dbs_assignment_strategy,
+    DROP CONSTRAINT chk_team_dbs_sla_policy;
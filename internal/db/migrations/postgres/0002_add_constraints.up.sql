@@ -0,0 +1,30 @@
+-- Parent teams and home teams are not foreign keys: an empty name stands for
+-- none, and merged pull requests keep the name of a deleted team.
+ALTER TABLE team_dbs
+    ADD CONSTRAINT chk_team_dbs_sla_policy CHECK (sla_policy IN ('ESCALATE', 'NOTIFY_LEAD', 'REASSIGN')),
+    ADD CONSTRAINT chk_team_dbs_assignment_strategy CHECK (assignment_strategy IN ('RANDOM', 'WORKING_HOURS'));
+
+ALTER TABLE users
+    ADD CONSTRAINT chk_users_capacity CHECK (capacity >= 0);
+
+ALTER TABLE team_memberships
+    ADD CONSTRAINT fk_team_memberships_team FOREIGN KEY (team_name) REFERENCES team_dbs (team_name) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_team_memberships_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE,
+    ADD CONSTRAINT chk_team_memberships_role CHECK (role IN ('LEAD', 'MEMBER', 'OBSERVER'));
+
+ALTER TABLE review_declines
+    ADD CONSTRAINT fk_review_declines_pull_request FOREIGN KEY (pull_request_id) REFERENCES pull_requests (pull_request_id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_review_declines_reviewer FOREIGN KEY (reviewer_id) REFERENCES users (user_id),
+    ADD CONSTRAINT chk_review_declines_reason CHECK (reason IN ('BUSY', 'CONFLICT_OF_INTEREST', 'LACKS_CONTEXT'));
+
+ALTER TABLE review_assignments
+    ADD CONSTRAINT fk_review_assignments_pull_request FOREIGN KEY (pull_request_id) REFERENCES pull_requests (pull_request_id) ON DELETE CASCADE,
+    ADD CONSTRAINT fk_review_assignments_reviewer FOREIGN KEY (reviewer_id) REFERENCES users (user_id);
+
+CREATE INDEX idx_users_team_active ON users (team_name, is_active);
+CREATE INDEX idx_team_memberships_user_id ON team_memberships (user_id);
+CREATE INDEX idx_pull_requests_author_id ON pull_requests (author_id);
+CREATE INDEX idx_pull_requests_status ON pull_requests (status);
+CREATE INDEX idx_pull_requests_team_status ON pull_requests (team_name, status);
+CREATE INDEX idx_review_declines_reviewer_id ON review_declines (reviewer_id);
+CREATE INDEX idx_review_assignments_reviewer_id ON review_assignments (reviewer_id);
@@ -0,0 +1,3 @@
+-- Every update of a pull request increments its version, so writers that
+-- read an older version can detect that they lost a race.
+ALTER TABLE pull_requests ADD COLUMN version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,74 @@
+-- The schema GORM's AutoMigrate created before migrations were versioned.
+CREATE TABLE team_dbs (
+    team_name text PRIMARY KEY,
+    parent_team_name text,
+    min_reviewers integer NOT NULL DEFAULT 0,
+    max_reviewers integer NOT NULL DEFAULT 2,
+    sla_hours integer NOT NULL DEFAULT 0,
+    sla_policy varchar(20) NOT NULL DEFAULT 'ESCALATE',
+    assignment_strategy varchar(20) NOT NULL DEFAULT 'RANDOM',
+    digest_time varchar(5),
+    quiet_hours_start varchar(5),
+    quiet_hours_end varchar(5),
+    chat_webhook_url text,
+    created_at datetime
+);
+
+CREATE INDEX idx_team_dbs_parent_team_name ON team_dbs (parent_team_name);
+
+CREATE TABLE users (
+    user_id text PRIMARY KEY,
+    username text NOT NULL,
+    team_name text NOT NULL,
+    is_active boolean NOT NULL DEFAULT true,
+    email text,
+    chat_handle text,
+    timezone text NOT NULL DEFAULT 'UTC',
+    work_start varchar(5) NOT NULL DEFAULT '09:00',
+    work_end varchar(5) NOT NULL DEFAULT '18:00',
+    capacity integer NOT NULL DEFAULT 0,
+    ooo_from datetime,
+    ooo_until datetime,
+    last_digest_at datetime,
+    created_at datetime,
+    updated_at datetime
+);
+
+CREATE TABLE team_memberships (
+    team_name text,
+    user_id text,
+    role varchar(20) NOT NULL DEFAULT 'MEMBER',
+    is_active boolean NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (team_name, user_id)
+);
+
+CREATE TABLE pull_requests (
+    pull_request_id text PRIMARY KEY,
+    pull_request_name text NOT NULL,
+    author_id text NOT NULL,
+    team_name text NOT NULL DEFAULT '',
+    status varchar(20) NOT NULL DEFAULT 'OPEN',
+    assigned_reviewers JSON,
+    created_at datetime,
+    merged_at datetime,
+    CONSTRAINT fk_pull_requests_author FOREIGN KEY (author_id) REFERENCES users (user_id),
+    CONSTRAINT chk_pull_requests_status CHECK (status IN ('OPEN', 'MERGED'))
+);
+
+CREATE TABLE review_declines (
+    pull_request_id text,
+    reviewer_id text,
+    reason varchar(30) NOT NULL,
+    replaced_by text,
+    created_at datetime,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
+
+CREATE TABLE review_assignments (
+    pull_request_id text,
+    reviewer_id text,
+    assigned_at datetime NOT NULL,
+    escalated_at datetime,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
@@ -0,0 +1,59 @@
+DROP INDEX idx_pull_requests_team_status;
+DROP INDEX idx_pull_requests_status;
+DROP INDEX idx_pull_requests_author_id;
+DROP INDEX idx_users_team_active;
+
+CREATE TABLE review_assignments_old (
+    pull_request_id text,
+    reviewer_id text,
+    assigned_at datetime NOT NULL,
+    escalated_at datetime,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
+INSERT INTO review_assignments_old SELECT pull_request_id, reviewer_id, assigned_at, escalated_at FROM review_assignments;
+DROP TABLE review_assignments;
+ALTER TABLE review_assignments_old RENAME TO review_assignments;
+
+CREATE TABLE review_declines_old (
+    pull_request_id text,
+    reviewer_id text,
+    reason varchar(30) NOT NULL,
+    replaced_by text,
+    created_at datetime,
+    PRIMARY KEY (pull_request_id, reviewer_id)
+);
+INSERT INTO review_declines_old SELECT pull_request_id, reviewer_id, reason, replaced_by, created_at FROM review_declines;
+DROP TABLE review_declines;
+ALTER TABLE review_declines_old RENAME TO review_declines;
+
+CREATE TABLE team_memberships_old (
+    team_name text,
+    user_id text,
+    role varchar(20) NOT NULL DEFAULT 'MEMBER',
+    is_active boolean NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (team_name, user_id)
+);
+INSERT INTO team_memberships_old SELECT team_name, user_id, role, is_active, created_at FROM team_memberships;
+DROP TABLE team_memberships;
+ALTER TABLE team_memberships_old RENAME TO team_memberships;
+
+CREATE TABLE team_dbs_old (
+    team_name text PRIMARY KEY,
+    parent_team_name text,
+    min_reviewers integer NOT NULL DEFAULT 0,
+    max_reviewers integer NOT NULL DEFAULT 2,
+    sla_hours integer NOT NULL DEFAULT 0,
+    sla_policy varchar(20) NOT NULL DEFAULT 'ESCALATE',
+    assignment_strategy varchar(20) NOT NULL DEFAULT 'RANDOM',
+    digest_time varchar(5),
+    quiet_hours_start varchar(5),
+    quiet_hours_end varchar(5),
+    chat_webhook_url text,
+    created_at datetime
+);
+INSERT INTO team_dbs_old SELECT team_name, parent_team_name, min_reviewers, max_reviewers, sla_hours, sla_policy,
+    assignment_strategy, digest_time, quiet_hours_start, quiet_hours_end, chat_webhook_url, created_at FROM team_dbs;
+DROP TABLE team_dbs;
+ALTER TABLE team_dbs_old RENAME TO team_dbs;
+CREATE INDEX idx_team_dbs_parent_team_name ON team_dbs (parent_team_name);
@@ -0,0 +1,77 @@
+-- SQLite cannot add constraints to a table, so the tables that gain some are
+-- rebuilt. users and pull_requests are referenced by other tables and keep
+-- their definition; the capacity check exists on Postgres only. Parent teams
+-- and home teams are not foreign keys: an empty name stands for none, and
+-- merged pull requests keep the name of a deleted team.
+CREATE TABLE team_dbs_new (
+    team_name text PRIMARY KEY,
+    parent_team_name text,
+    min_reviewers integer NOT NULL DEFAULT 0,
+    max_reviewers integer NOT NULL DEFAULT 2,
+    sla_hours integer NOT NULL DEFAULT 0,
+    sla_policy varchar(20) NOT NULL DEFAULT 'ESCALATE',
+    assignment_strategy varchar(20) NOT NULL DEFAULT 'RANDOM',
+    digest_time varchar(5),
+    quiet_hours_start varchar(5),
+    quiet_hours_end varchar(5),
+    chat_webhook_url text,
+    created_at datetime,
+    CONSTRAINT chk_team_dbs_sla_policy CHECK (sla_policy IN ('ESCALATE', 'NOTIFY_LEAD', 'REASSIGN')),
+    CONSTRAINT chk_team_dbs_assignment_strategy CHECK (assignment_strategy IN ('RANDOM', 'WORKING_HOURS'))
+);
+INSERT INTO team_dbs_new SELECT team_name, parent_team_name, min_reviewers, max_reviewers, sla_hours, sla_policy,
+    assignment_strategy, digest_time, quiet_hours_start, quiet_hours_end, chat_webhook_url, created_at FROM team_dbs;
+DROP TABLE team_dbs;
+ALTER TABLE team_dbs_new RENAME TO team_dbs;
+CREATE INDEX idx_team_dbs_parent_team_name ON team_dbs (parent_team_name);
+
+CREATE TABLE team_memberships_new (
+    team_name text,
+    user_id text,
+    role varchar(20) NOT NULL DEFAULT 'MEMBER',
+    is_active boolean NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (team_name, user_id),
+    CONSTRAINT fk_team_memberships_team FOREIGN KEY (team_name) REFERENCES team_dbs (team_name) ON DELETE CASCADE,
+    CONSTRAINT fk_team_memberships_user FOREIGN KEY (user_id) REFERENCES users (user_id) ON DELETE CASCADE,
+    CONSTRAINT chk_team_memberships_role CHECK (role IN ('LEAD', 'MEMBER', 'OBSERVER'))
+);
+INSERT INTO team_memberships_new SELECT team_name, user_id, role, is_active, created_at FROM team_memberships;
+DROP TABLE team_memberships;
+ALTER TABLE team_memberships_new RENAME TO team_memberships;
+
+CREATE TABLE review_declines_new (
+    pull_request_id text,
+    reviewer_id text,
+    reason varchar(30) NOT NULL,
+    replaced_by text,
+    created_at datetime,
+    PRIMARY KEY (pull_request_id, reviewer_id),
+    CONSTRAINT fk_review_declines_pull_request FOREIGN KEY (pull_request_id) REFERENCES pull_requests (pull_request_id) ON DELETE CASCADE,
+    CONSTRAINT fk_review_declines_reviewer FOREIGN KEY (reviewer_id) REFERENCES users (user_id),
+    CONSTRAINT chk_review_declines_reason CHECK (reason IN ('BUSY', 'CONFLICT_OF_INTEREST', 'LACKS_CONTEXT'))
+);
+INSERT INTO review_declines_new SELECT pull_request_id, reviewer_id, reason, replaced_by, created_at FROM review_declines;
+DROP TABLE review_declines;
+ALTER TABLE review_declines_new RENAME TO review_declines;
+
+CREATE TABLE review_assignments_new (
+    pull_request_id text,
+    reviewer_id text,
+    assigned_at datetime NOT NULL,
+    escalated_at datetime,
+    PRIMARY KEY (pull_request_id, reviewer_id),
+    CONSTRAINT fk_review_assignments_pull_request FOREIGN KEY (pull_request_id) REFERENCES pull_requests (pull_request_id) ON DELETE CASCADE,
+    CONSTRAINT fk_review_assignments_reviewer FOREIGN KEY (reviewer_id) REFERENCES users (user_id)
+);
+INSERT INTO review_assignments_new SELECT pull_request_id, reviewer_id, assigned_at, escalated_at FROM review_assignments;
+DROP TABLE review_assignments;
+ALTER TABLE review_assignments_new RENAME TO review_assignments;
+
+CREATE INDEX idx_users_team_active ON users (team_name, is_active);
+CREATE INDEX idx_team_memberships_user_id ON team_memberships (user_id);
+CREATE INDEX idx_pull_requests_author_id ON pull_requests (author_id);
+CREATE INDEX idx_pull_requests_status ON pull_requests (status);
+CREATE INDEX idx_pull_requests_team_status ON pull_requests (team_name, status);
+CREATE INDEX idx_review_declines_reviewer_id ON review_declines (reviewer_id);
+CREATE INDEX idx_review_assignments_reviewer_id ON review_assignments (reviewer_id);
@@ -0,0 +1,11 @@
+CREATE TABLE idempotency_keys (
+    idempotency_key text PRIMARY KEY,
+    fingerprint text NOT NULL,
+    status_code integer NOT NULL DEFAULT 0,
+    response_headers JSON,
+    response_body blob,
+    created_at datetime,
+    expires_at datetime NOT NULL
+);
+
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
@@ -0,0 +1,191 @@
+package directory
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const pageSize = 500
+
+type Config struct {
+	URL          string
+	BindDN       string
+	BindPassword string
+	// InsecureSkipVerify disables certificate verification for ldaps:// URLs.
+	InsecureSkipVerify bool
+
+	UserBaseDN string
+	UserFilter string
+	// UserIDAttribute, DisplayNameAttribute and EmailAttribute map directory
+	// entries to user_id, username and email.
+	UserIDAttribute      string
+	DisplayNameAttribute string
+	EmailAttribute       string
+
+	GroupBaseDN string
+	GroupFilter string
+	// GroupNameAttribute maps a group to its team_name. MemberAttribute may
+	// hold member DNs (groupOfNames) or user ids (posixGroup memberUid).
+	GroupNameAttribute string
+	MemberAttribute    string
+
+	Timeout time.Duration
+}
+
+func ConfigFromEnv() Config {
+	return Config{
+		URL:                  os.Getenv("LDAP_URL"),
+		BindDN:               os.Getenv("LDAP_BIND_DN"),
+		BindPassword:         os.Getenv("LDAP_BIND_PASSWORD"),
+		InsecureSkipVerify:   os.Getenv("LDAP_INSECURE_SKIP_VERIFY") == "true",
+		UserBaseDN:           os.Getenv("LDAP_USER_BASE_DN"),
+		UserFilter:           envOrDefault("LDAP_USER_FILTER", "(objectClass=person)"),
+		UserIDAttribute:      envOrDefault("LDAP_USER_ID_ATTRIBUTE", "uid"),
+		DisplayNameAttribute: envOrDefault("LDAP_DISPLAY_NAME_ATTRIBUTE", "cn"),
+		EmailAttribute:       envOrDefault("LDAP_EMAIL_ATTRIBUTE", "mail"),
+		GroupBaseDN:          os.Getenv("LDAP_GROUP_BASE_DN"),
+		GroupFilter:          envOrDefault("LDAP_GROUP_FILTER", "(objectClass=groupOfNames)"),
+		GroupNameAttribute:   envOrDefault("LDAP_GROUP_ATTRIBUTE", "cn"),
+		MemberAttribute:      envOrDefault("LDAP_MEMBER_ATTRIBUTE", "member"),
+		Timeout:              30 * time.Second,
+	}
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+type User struct {
+	DN          string
+	UserID      string
+	DisplayName string
+	Email       string
+}
+
+type Group struct {
+	Name string
+	// Members holds the user ids of members found among the directory users,
+	// sorted. Nested groups and entries outside UserBaseDN are skipped.
+	Members []string
+}
+
+type Snapshot struct {
+	Users  []User
+	Groups []Group
+}
+
+// Fetch reads all users and groups matching the configured filters. Users
+// without a user id and groups without a name are skipped, as are later
+// entries repeating a user id or group name.
+func Fetch(config Config) (*Snapshot, error) {
+	if config.URL == "" {
+		return nil, errors.New("LDAP_URL is not set")
+	}
+
+	conn, err := ldap.DialURL(config.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: config.Timeout}),
+		ldap.DialWithTLSConfig(&tls.Config{InsecureSkipVerify: config.InsecureSkipVerify}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if config.Timeout > 0 {
+		conn.SetTimeout(config.Timeout)
+	}
+
+	if config.BindDN != "" {
+		err = conn.Bind(config.BindDN, config.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userEntries, err := search(conn, config.UserBaseDN, config.UserFilter,
+		[]string{config.UserIDAttribute, config.DisplayNameAttribute, config.EmailAttribute})
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{Users: []User{}, Groups: []Group{}}
+	usersByDN := make(map[string]string)
+	userIDs := make(map[string]bool)
+	for _, entry := range userEntries {
+		user := User{
+			DN:          entry.DN,
+			UserID:      entry.GetEqualFoldAttributeValue(config.UserIDAttribute),
+			DisplayName: entry.GetEqualFoldAttributeValue(config.DisplayNameAttribute),
+			Email:       entry.GetEqualFoldAttributeValue(config.EmailAttribute),
+		}
+		if user.UserID == "" || userIDs[user.UserID] {
+			continue
+		}
+		userIDs[user.UserID] = true
+		usersByDN[normalizeDN(entry.DN)] = user.UserID
+		snapshot.Users = append(snapshot.Users, user)
+	}
+
+	groupEntries, err := search(conn, config.GroupBaseDN, config.GroupFilter,
+		[]string{config.GroupNameAttribute, config.MemberAttribute})
+	if err != nil {
+		return nil, err
+	}
+
+	groupNames := make(map[string]bool)
+	for _, entry := range groupEntries {
+		group := Group{Name: entry.GetEqualFoldAttributeValue(config.GroupNameAttribute), Members: []string{}}
+		if group.Name == "" || groupNames[group.Name] {
+			continue
+		}
+		groupNames[group.Name] = true
+
+		seen := make(map[string]bool)
+		for _, value := range entry.GetEqualFoldAttributeValues(config.MemberAttribute) {
+			userID, ok := usersByDN[normalizeDN(value)]
+			if !ok && userIDs[value] {
+				userID, ok = value, true
+			}
+			if ok && !seen[userID] {
+				seen[userID] = true
+				group.Members = append(group.Members, userID)
+			}
+		}
+		sort.Strings(group.Members)
+		snapshot.Groups = append(snapshot.Groups, group)
+	}
+
+	sort.Slice(snapshot.Users, func(i, j int) bool { return snapshot.Users[i].UserID < snapshot.Users[j].UserID })
+	sort.Slice(snapshot.Groups, func(i, j int) bool { return snapshot.Groups[i].Name < snapshot.Groups[j].Name })
+	return snapshot, nil
+}
+
+func search(conn *ldap.Conn, baseDN string, filter string, attributes []string) ([]*ldap.Entry, error) {
+	request := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		filter, attributes, nil)
+	result, err := conn.SearchWithPaging(request, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+// normalizeDN makes DNs that differ only in case or spacing compare equal.
+// Values that are not DNs, such as memberUid user ids, are returned as is.
+func normalizeDN(value string) string {
+	dn, err := ldap.ParseDN(value)
+	if err != nil || len(dn.RDNs) == 0 {
+		return value
+	}
+	return strings.ToLower(dn.String())
+}
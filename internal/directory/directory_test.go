@@ -0,0 +1,185 @@
+package directory
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBindDN       = "cn=sync,dc=example,dc=org"
+	testBindPassword = "secret"
+)
+
+var objectClassFilter = regexp.MustCompile(`^\(objectClass=([A-Za-z]+)\)$`)
+
+// startTestServer runs an in-process LDAP server that accepts testBindDN and
+// answers subtree searches filtered by (objectClass=...) from entries.
+func startTestServer(t *testing.T, entries []*gldap.Entry) string {
+	server, err := gldap.NewServer()
+	require.NoError(t, err)
+	mux, err := gldap.NewMux()
+	require.NoError(t, err)
+
+	require.NoError(t, mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		response := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+		defer w.Write(response)
+
+		message, err := r.GetSimpleBindMessage()
+		if err == nil && message.UserName == testBindDN && string(message.Password) == testBindPassword {
+			response.SetResultCode(gldap.ResultSuccess)
+		}
+	}))
+	require.NoError(t, mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		response := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+		defer w.Write(response)
+
+		message, err := r.GetSearchMessage()
+		if err != nil {
+			response.SetResultCode(gldap.ResultProtocolError)
+			return
+		}
+		match := objectClassFilter.FindStringSubmatch(message.Filter)
+		if match == nil {
+			response.SetResultCode(gldap.ResultUnwillingToPerform)
+			return
+		}
+
+		for _, entry := range entries {
+			if !strings.HasSuffix(strings.ToLower(entry.DN), ","+strings.ToLower(message.BaseDN)) {
+				continue
+			}
+			if !containsFold(entry.GetAttributeValues("objectClass"), match[1]) {
+				continue
+			}
+			result := r.NewSearchResponseEntry(entry.DN)
+			for _, attribute := range entry.Attributes {
+				result.AddAttribute(attribute.Name, attribute.Values)
+			}
+			w.Write(result)
+		}
+	}))
+	require.NoError(t, server.Router(mux))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := listener.Addr().String()
+	listener.Close()
+
+	go server.Run(address)
+	t.Cleanup(func() { server.Stop() })
+	for !server.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	return "ldap://" + address
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func testConfig(url string) Config {
+	return Config{
+		URL:                  url,
+		BindDN:               testBindDN,
+		BindPassword:         testBindPassword,
+		UserBaseDN:           "ou=people,dc=example,dc=org",
+		UserFilter:           "(objectClass=person)",
+		UserIDAttribute:      "uid",
+		DisplayNameAttribute: "cn",
+		EmailAttribute:       "mail",
+		GroupBaseDN:          "ou=groups,dc=example,dc=org",
+		GroupFilter:          "(objectClass=groupOfNames)",
+		GroupNameAttribute:   "cn",
+		MemberAttribute:      "member",
+		Timeout:              5 * time.Second,
+	}
+}
+
+func TestFetchGroupOfNames(t *testing.T) {
+	url := startTestServer(t, []*gldap.Entry{
+		gldap.NewEntry("uid=alice,ou=people,dc=example,dc=org", map[string][]string{
+			"objectClass": {"person"}, "uid": {"alice"}, "cn": {"Alice Liddell"}, "mail": {"alice@example.org"},
+		}),
+		gldap.NewEntry("uid=bob,ou=people,dc=example,dc=org", map[string][]string{
+			"objectClass": {"person"}, "uid": {"bob"}, "cn": {"Bob Builder"},
+		}),
+		gldap.NewEntry("cn=printer,ou=people,dc=example,dc=org", map[string][]string{
+			"objectClass": {"person"}, "cn": {"Printer"},
+		}),
+		gldap.NewEntry("cn=backend,ou=groups,dc=example,dc=org", map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"cn":          {"backend"},
+			"member": {
+				"UID=Bob, OU=People, DC=example, DC=org",
+				"uid=alice,ou=people,dc=example,dc=org",
+				"cn=frontend,ou=groups,dc=example,dc=org",
+				"uid=carol,ou=people,dc=example,dc=org",
+			},
+		}),
+		gldap.NewEntry("cn=frontend,ou=groups,dc=example,dc=org", map[string][]string{
+			"objectClass": {"groupOfNames"}, "cn": {"frontend"},
+		}),
+	})
+
+	snapshot, err := Fetch(testConfig(url))
+	require.NoError(t, err)
+
+	assert.Equal(t, []User{
+		{DN: "uid=alice,ou=people,dc=example,dc=org", UserID: "alice", DisplayName: "Alice Liddell", Email: "alice@example.org"},
+		{DN: "uid=bob,ou=people,dc=example,dc=org", UserID: "bob", DisplayName: "Bob Builder"},
+	}, snapshot.Users)
+	assert.Equal(t, []Group{
+		{Name: "backend", Members: []string{"alice", "bob"}},
+		{Name: "frontend", Members: []string{}},
+	}, snapshot.Groups)
+}
+
+func TestFetchCustomAttributes(t *testing.T) {
+	url := startTestServer(t, []*gldap.Entry{
+		gldap.NewEntry("cn=Alice Liddell,ou=people,dc=example,dc=org", map[string][]string{
+			"objectClass": {"user"}, "sAMAccountName": {"aliddell"}, "displayName": {"Alice L."},
+		}),
+		gldap.NewEntry("cn=Platform Team,ou=groups,dc=example,dc=org", map[string][]string{
+			"objectClass": {"posixGroup"}, "description": {"platform"}, "memberUid": {"aliddell", "ghost"},
+		}),
+	})
+
+	config := testConfig(url)
+	config.UserFilter = "(objectClass=user)"
+	config.UserIDAttribute = "sAMAccountName"
+	config.DisplayNameAttribute = "displayName"
+	config.GroupFilter = "(objectClass=posixGroup)"
+	config.GroupNameAttribute = "description"
+	config.MemberAttribute = "memberUid"
+
+	snapshot, err := Fetch(config)
+	require.NoError(t, err)
+
+	if assert.Len(t, snapshot.Users, 1) {
+		assert.Equal(t, "aliddell", snapshot.Users[0].UserID)
+		assert.Equal(t, "Alice L.", snapshot.Users[0].DisplayName)
+	}
+	assert.Equal(t, []Group{{Name: "platform", Members: []string{"aliddell"}}}, snapshot.Groups)
+}
+
+func TestFetchInvalidCredentials(t *testing.T) {
+	url := startTestServer(t, nil)
+
+	config := testConfig(url)
+	config.BindPassword = "wrong"
+
+	_, err := Fetch(config)
+	assert.Error(t, err)
+}
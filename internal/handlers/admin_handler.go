@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"io"
+	"prReviewerAssignment/internal/services"
+	"strings"
+)
+
+type AdminHandler struct {
+	importService    *services.ImportService
+	reconcileService *services.ReconcileService
+}
+
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{
+		importService:    services.NewImportService(),
+		reconcileService: services.NewReconcileService(),
+	}
+}
+
+// Import takes the format from ?format=csv|yaml or, failing that, from the
+// Content-Type; anything else is read as YAML. ?dry_run=true only reports
+// the changes.
+func (h *AdminHandler) Import(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = services.ImportFormatYAML
+		if strings.Contains(c.ContentType(), "csv") {
+			format = services.ImportFormatCSV
+		}
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(services.InvalidField(services.CodeInvalidDocument, "", "failed to read request body"))
+		return
+	}
+
+	response, err := h.importService.Import(format, data, c.Query("dry_run") == "true")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
+}
+
+// Reconcile takes a YAML roster spec and returns the plan converging to it;
+// ?apply=true also applies the plan.
+func (h *AdminHandler) Reconcile(c *gin.Context) {
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Error(services.InvalidField(services.CodeInvalidDocument, "", "failed to read request body"))
+		return
+	}
+
+	response, err := h.reconcileService.Reconcile(data, c.Query("apply") == "true")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
+}
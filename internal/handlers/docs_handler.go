@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"prReviewerAssignment/internal/openapi"
+)
+
+type DocsHandler struct {
+	spec []byte
+}
+
+// NewDocsHandler fails when the bundled OpenAPI document is invalid.
+func NewDocsHandler() (*DocsHandler, error) {
+	spec, err := openapi.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return &DocsHandler{spec: spec}, nil
+}
+
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.Data(200, "application/json; charset=utf-8", h.spec)
+}
+
+func (h *DocsHandler) Page(c *gin.Context) {
+	c.Data(200, "text/html; charset=utf-8", openapi.DocsPage)
+}
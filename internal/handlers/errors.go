@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"log"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/services"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// errorStatuses is the error catalog: the HTTP status of every code services
+// report. Codes missing here are reported as INTERNAL.
+var errorStatuses = map[string]int{
+	services.CodeValidation:            400,
+	services.CodeInvalidQuery:          400,
+	services.CodeInvalidDocument:       400,
+	services.CodeInvalidReason:         400,
+	services.CodeInvalidIdempotencyKey: 400,
+	services.CodeNotTeamLead:           403,
+	services.CodeNotFound:              404,
+	services.CodeTeamExists:            409,
+	services.CodePRExists:              409,
+	services.CodeUserExists:            409,
+	services.CodePRMerged:              409,
+	services.CodeNotAssigned:           409,
+	services.CodeNoCandidate:           409,
+	services.CodeAlreadyAssigned:       409,
+	services.CodeInvalidReviewer:       409,
+	services.CodeNotTeamMember:         409,
+	services.CodeReviewerLimit:         409,
+	services.CodeReviewDeclined:        409,
+	services.CodeTeamHasOpenPRs:        409,
+	services.CodeAlreadyMember:         409,
+	services.CodeIdempotencyInProgress: 409,
+	services.CodeVersionMismatch:       412,
+	services.CodeIdempotencyKeyReused:  422,
+	services.CodeInternal:              500,
+}
+
+// RenderErrors is middleware writing the last error a handler attached with
+// c.Error as an ErrorResponse, with the status its code has in the catalog.
+// Handlers report errors this way and leave the response to it.
+func RenderErrors(c *gin.Context) {
+	c.Next()
+
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+	writeError(c, c.Errors.Last().Err)
+}
+
+// RecoverInternal reports a handler that panicked as INTERNAL; it is meant
+// for gin.CustomRecovery, which has logged the panic already.
+func RecoverInternal(c *gin.Context, recovered interface{}) {
+	errorResponse := models.ErrorResponse{}
+	errorResponse.Error.Code = services.CodeInternal
+	errorResponse.Error.Message = "Internal server error"
+	c.AbortWithStatusJSON(500, errorResponse)
+}
+
+// abortWithError writes err the way RenderErrors does and stops the chain,
+// for middleware that runs ahead of RenderErrors.
+func abortWithError(c *gin.Context, err error) {
+	writeError(c, err)
+	c.Abort()
+}
+
+// writeError reports a *services.Error with its code, message and details.
+// Any other error is logged and reported as INTERNAL without its text.
+func writeError(c *gin.Context, err error) {
+	serviceError, status := catalogError(err)
+	if serviceError.Code == services.CodeInternal {
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	}
+
+	errorResponse := models.ErrorResponse{}
+	errorResponse.Error.Code = serviceError.Code
+	errorResponse.Error.Message = serviceError.Message
+	errorResponse.Error.Details = serviceError.Details
+	c.JSON(status, errorResponse)
+}
+
+// catalogError returns err as a *services.Error with a code from the catalog
+// and the status of the code.
+func catalogError(err error) (*services.Error, int) {
+	var serviceError *services.Error
+	if errors.As(err, &serviceError) {
+		if status, ok := errorStatuses[serviceError.Code]; ok {
+			return serviceError, status
+		}
+	}
+	return &services.Error{Code: services.CodeInternal, Message: "Internal server error"}, 500
+}
+
+// bindJSON decodes the request body into request, which must be a pointer
+// to a struct. On failure it reports a VALIDATION_ERROR naming the fields at
+// fault and returns false.
+func bindJSON(c *gin.Context, request interface{}) bool {
+	err := c.ShouldBindJSON(request)
+	if err == nil {
+		return true
+	}
+
+	var validationErrors validator.ValidationErrors
+	var typeError *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrors):
+		var details []models.FieldError
+		for _, fieldError := range validationErrors {
+			problem := "is invalid"
+			if fieldError.Tag() == "required" {
+				problem = "is required"
+			}
+			details = append(details, models.FieldError{Field: jsonName(request, fieldError.StructField()), Message: problem})
+		}
+		c.Error(services.Invalid(services.CodeValidation, details))
+	case errors.As(err, &typeError) && typeError.Field != "":
+		c.Error(services.InvalidField(services.CodeValidation, typeError.Field, "must be "+jsonType(typeError.Type)))
+	default:
+		c.Error(services.Invalid(services.CodeValidation, []models.FieldError{{Message: "request body must be a valid JSON object"}}))
+	}
+	return false
+}
+
+// bindQuery binds the query string to the struct request points to. A
+// parameter that does not parse is reported by its name.
+func bindQuery(c *gin.Context, request interface{}) bool {
+	if err := c.ShouldBindQuery(request); err == nil {
+		return true
+	}
+
+	requestType := reflect.TypeOf(request).Elem()
+	for i := 0; i < requestType.NumField(); i++ {
+		field := requestType.Field(i)
+		name := field.Tag.Get("form")
+		value := c.Query(name)
+		if name == "" || value == "" {
+			continue
+		}
+
+		kind := field.Type.Kind()
+		if kind == reflect.Pointer {
+			kind = field.Type.Elem().Kind()
+		}
+		if _, err := strconv.Atoi(value); kind == reflect.Int && err != nil {
+			c.Error(services.InvalidField(services.CodeInvalidQuery, name, "must be a number"))
+			return false
+		}
+		if _, err := strconv.ParseBool(value); kind == reflect.Bool && err != nil {
+			c.Error(services.InvalidField(services.CodeInvalidQuery, name, "must be true or false"))
+			return false
+		}
+	}
+	c.Error(services.Invalid(services.CodeInvalidQuery, []models.FieldError{{Message: "query parameters are invalid"}}))
+	return false
+}
+
+// jsonName returns the JSON name of the field of the struct request points
+// to.
+func jsonName(request interface{}, field string) string {
+	structField, ok := reflect.TypeOf(request).Elem().FieldByName(field)
+	if !ok {
+		return field
+	}
+	if name, _, _ := strings.Cut(structField.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return field
+}
+
+func jsonType(goType reflect.Type) string {
+	switch goType.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Pointer:
+		return jsonType(goType.Elem())
+	}
+	return "a number"
+}
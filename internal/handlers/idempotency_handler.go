@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"io"
+	"log"
+	"net/http"
+	"prReviewerAssignment/internal/services"
+	"time"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored with the body and sent
+// again on replay.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+type IdempotencyHandler struct {
+	idempotencyService *services.IdempotencyService
+}
+
+// NewIdempotencyHandler replays responses for ttl after they were sent.
+func NewIdempotencyHandler(ttl time.Duration) *IdempotencyHandler {
+	return &IdempotencyHandler{
+		idempotencyService: services.NewIdempotencyService(ttl),
+	}
+}
+
+// Replay is middleware for POST requests with an Idempotency-Key header. The
+// first request with a key is handled and its response stored; requests
+// repeating it with the same method, path, query, body and Authorization get
+// the stored response with Idempotent-Replayed set. Server errors and panics
+// are not stored, so retrying them handles the request again.
+func (h *IdempotencyHandler) Replay(c *gin.Context) {
+	key := c.GetHeader("Idempotency-Key")
+	if c.Request.Method != http.MethodPost || key == "" {
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		abortWithError(c, services.InvalidField(services.CodeInvalidIdempotencyKey, "Idempotency-Key", "must be at most 255 characters"))
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		abortWithError(c, services.InvalidField(services.CodeValidation, "", "failed to read request body"))
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	record, err := h.idempotencyService.Begin(key, fingerprint(c.Request, body))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	if record != nil {
+		headers := map[string]string{}
+		json.Unmarshal(record.ResponseHeaders, &headers)
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(record.StatusCode)
+		c.Writer.Write(record.ResponseBody)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	release := h.idempotencyService.Hold(key)
+	defer func() {
+		release()
+		// A panicking handler leaves no response to store; the key is
+		// abandoned so a retry is handled again.
+		if recovered := recover(); recovered != nil {
+			if err := h.idempotencyService.Abandon(key); err != nil {
+				log.Printf("failed to release idempotency key %q: %v", key, err)
+			}
+			panic(recovered)
+		}
+		h.store(key, recorder)
+	}()
+	c.Next()
+}
+
+// store keeps the recorded response for replay, or abandons key after a
+// server error.
+func (h *IdempotencyHandler) store(key string, recorder *responseRecorder) {
+	var err error
+	if recorder.Status() >= 500 {
+		err = h.idempotencyService.Abandon(key)
+	} else {
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		headersJSON, _ := json.Marshal(headers)
+		err = h.idempotencyService.Finish(key, recorder.Status(), headersJSON, recorder.body.Bytes())
+	}
+	if err != nil {
+		log.Printf("failed to store the response for idempotency key %q: %v", key, err)
+	}
+}
+
+// fingerprint identifies a request by everything that may change its
+// outcome.
+func fingerprint(request *http.Request, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{request.Method, request.URL.Path, request.URL.RawQuery, request.Header.Get("Authorization")} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the body written through it.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"prReviewerAssignment/internal/models"
 	"prReviewerAssignment/internal/services"
+	"strconv"
+	"strings"
 )
 
 type PRHandler struct {
@@ -19,25 +22,17 @@ func NewPRHandler() *PRHandler {
 func (h *PRHandler) CreatePullRequest(c *gin.Context) {
 	var request models.CreatePRRequest
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		h.sendError(c, "PR_EXISTS", "Invalid JSON data", 400)
+	if !bindJSON(c, &request) {
 		return
 	}
 
 	pr, err := h.prService.CreatePullRequest(request)
 	if err != nil {
-		if err.Error() == "PR already exists" {
-			h.sendError(c, "PR_EXISTS", "PR id already exists", 409)
-			return
-		}
-		if err.Error() == "author not found or inactive" {
-			h.sendError(c, "NOT_FOUND", "author not found or inactive", 404)
-			return
-		}
-		h.sendError(c, "PR_EXISTS", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
+	setETag(c, pr)
 	c.JSON(201, gin.H{
 		"pr": pr,
 	})
@@ -46,21 +41,17 @@ func (h *PRHandler) CreatePullRequest(c *gin.Context) {
 func (h *PRHandler) MergePullRequest(c *gin.Context) {
 	var request models.MergePRRequest
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		h.sendError(c, "NOT_FOUND", "Invalid JSON data", 400)
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	pr, err := h.prService.MergePullRequest(request.PullRequestID)
+	pr, err := h.prService.MergePullRequest(request.PullRequestID, ifMatch(c))
 	if err != nil {
-		if err.Error() == "PR not found" {
-			h.sendError(c, "NOT_FOUND", "PR not found", 404)
-			return
-		}
-		h.sendError(c, "NOT_FOUND", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
+	setETag(c, pr)
 	c.JSON(200, gin.H{
 		"pr": pr,
 	})
@@ -69,39 +60,97 @@ func (h *PRHandler) MergePullRequest(c *gin.Context) {
 func (h *PRHandler) ReassignReviewer(c *gin.Context) {
 	var request models.ReassignPRRequest
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		h.sendError(c, "NOT_FOUND", "Invalid JSON data", 400)
+	if !bindJSON(c, &request) {
 		return
 	}
 
-	pr, newReviewer, err := h.prService.ReassignReviewer(request.PullRequestID, request.OldReviewerID)
+	pr, newReviewer, err := h.prService.ReassignReviewer(request.PullRequestID, request.OldReviewerID, ifMatch(c))
 	if err != nil {
-		switch err.Error() {
-		case "PR not found":
-			h.sendError(c, "NOT_FOUND", "PR not found", 404)
-		case "old reviewer not found or inactive":
-			h.sendError(c, "NOT_FOUND", "reviewer not found or inactive", 404)
-		case "cannot reassign on merged PR":
-			h.sendError(c, "PR_MERGED", "cannot reassign on merged PR", 409)
-		case "reviewer is not assigned to this PR":
-			h.sendError(c, "NOT_ASSIGNED", "reviewer is not assigned to this PR", 409)
-		case "no active replacement candidate in team":
-			h.sendError(c, "NO_CANDIDATE", "no active replacement candidate in team", 409)
-		default:
-			h.sendError(c, "NOT_FOUND", "Internal server error", 500)
-		}
+		c.Error(err)
 		return
 	}
 
+	setETag(c, pr)
 	c.JSON(200, gin.H{
 		"pr":          pr,
 		"replaced_by": newReviewer,
 	})
 }
 
-func (h *PRHandler) sendError(c *gin.Context, code, message string, statusCode int) {
-	errorResponse := models.ErrorResponse{}
-	errorResponse.Error.Code = code
-	errorResponse.Error.Message = message
-	c.JSON(statusCode, errorResponse)
+func (h *PRHandler) AddReviewer(c *gin.Context) {
+	var request models.AddReviewerRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	pr, err := h.prService.AddReviewer(request.PullRequestID, request.ReviewerID, request.OverrideApprovedBy, ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, gin.H{
+		"pr": pr,
+	})
+}
+
+func (h *PRHandler) RemoveReviewer(c *gin.Context) {
+	var request models.RemoveReviewerRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	pr, err := h.prService.RemoveReviewer(request.PullRequestID, request.ReviewerID, request.OverrideApprovedBy, ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, gin.H{
+		"pr": pr,
+	})
+}
+
+func (h *PRHandler) DeclineReview(c *gin.Context) {
+	var request models.DeclineReviewRequest
+
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	pr, newReviewer, err := h.prService.DeclineReview(request.PullRequestID, request.ReviewerID, request.Reason, ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, gin.H{
+		"pr":          pr,
+		"replaced_by": newReviewer,
+	})
+}
+
+// ifMatch returns the pull request version named by the If-Match header,
+// sent as "3" like the ETag or as W/"3" or 3. A missing header or * matches
+// any version and yields zero. A value that is no version matches none.
+func ifMatch(c *gin.Context) int {
+	value := strings.TrimSpace(c.GetHeader("If-Match"))
+	if value == "" || value == "*" {
+		return 0
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(value, "W/"), `"`))
+	if err != nil || version < 1 {
+		return -1
+	}
+	return version
+}
+
+func setETag(c *gin.Context, pr *models.PullRequest) {
+	c.Header("ETag", fmt.Sprintf(`"%d"`, pr.Version))
 }
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"log"
+	"os"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/pagination"
+	"prReviewerAssignment/internal/services"
+	"strconv"
+	"strings"
+)
+
+type SCIMHandler struct {
+	scimService *services.SCIMService
+	token       string
+}
+
+// NewSCIMHandler protects the SCIM endpoints with the bearer token in
+// SCIM_BEARER_TOKEN when it is set.
+func NewSCIMHandler() *SCIMHandler {
+	return &SCIMHandler{
+		scimService: services.NewSCIMService(),
+		token:       os.Getenv("SCIM_BEARER_TOKEN"),
+	}
+}
+
+func (h *SCIMHandler) Authenticate(c *gin.Context) {
+	c.Header("Content-Type", "application/scim+json")
+	if h.token == "" {
+		return
+	}
+
+	token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !found || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		h.sendError(c, 401, "", "invalid bearer token")
+		c.Abort()
+	}
+}
+
+func (h *SCIMHandler) ListUsers(c *gin.Context) {
+	startIndex, count, ok := h.page(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.scimService.ListUsers(c.Query("filter"), startIndex, count)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *SCIMHandler) GetUser(c *gin.Context) {
+	user, err := h.scimService.GetUser(c.Param("id"))
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+func (h *SCIMHandler) CreateUser(c *gin.Context) {
+	var request models.SCIMUser
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, 400, "invalidSyntax", "Invalid JSON data")
+		return
+	}
+
+	user, err := h.scimService.CreateUser(request)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.Header("Location", user.Meta.Location)
+	c.JSON(201, user)
+}
+
+func (h *SCIMHandler) ReplaceUser(c *gin.Context) {
+	var request models.SCIMUser
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, 400, "invalidSyntax", "Invalid JSON data")
+		return
+	}
+
+	user, err := h.scimService.ReplaceUser(c.Param("id"), request)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+func (h *SCIMHandler) PatchUser(c *gin.Context) {
+	var request models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, 400, "invalidSyntax", "Invalid JSON data")
+		return
+	}
+
+	user, err := h.scimService.PatchUser(c.Param("id"), request)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+func (h *SCIMHandler) DeleteUser(c *gin.Context) {
+	if err := h.scimService.DeleteUser(c.Param("id")); err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+func (h *SCIMHandler) ListGroups(c *gin.Context) {
+	startIndex, count, ok := h.page(c)
+	if !ok {
+		return
+	}
+
+	response, err := h.scimService.ListGroups(c.Query("filter"), startIndex, count)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	if groups, ok := response.Resources.([]models.SCIMGroup); ok && excludesMembers(c) {
+		for i := range groups {
+			groups[i].Members = nil
+		}
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *SCIMHandler) GetGroup(c *gin.Context) {
+	group, err := h.scimService.GetGroup(c.Param("id"))
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	if excludesMembers(c) {
+		group.Members = nil
+	}
+
+	c.JSON(200, group)
+}
+
+func (h *SCIMHandler) CreateGroup(c *gin.Context) {
+	var request models.SCIMGroup
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, 400, "invalidSyntax", "Invalid JSON data")
+		return
+	}
+
+	group, err := h.scimService.CreateGroup(request)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.Header("Location", group.Meta.Location)
+	c.JSON(201, group)
+}
+
+func (h *SCIMHandler) ReplaceGroup(c *gin.Context) {
+	var request models.SCIMGroup
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, 400, "invalidSyntax", "Invalid JSON data")
+		return
+	}
+
+	group, err := h.scimService.ReplaceGroup(c.Param("id"), request)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(200, group)
+}
+
+func (h *SCIMHandler) PatchGroup(c *gin.Context) {
+	var request models.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.sendError(c, 400, "invalidSyntax", "Invalid JSON data")
+		return
+	}
+
+	group, err := h.scimService.PatchGroup(c.Param("id"), request)
+	if err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.JSON(200, group)
+}
+
+func (h *SCIMHandler) DeleteGroup(c *gin.Context) {
+	if err := h.scimService.DeleteGroup(c.Param("id")); err != nil {
+		h.sendServiceError(c, err)
+		return
+	}
+
+	c.Status(204)
+}
+
+// page reads startIndex and count; count defaults to pagination.DefaultLimit.
+func (h *SCIMHandler) page(c *gin.Context) (int, int, bool) {
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil {
+		h.sendError(c, 400, "invalidValue", "startIndex must be a number")
+		return 0, 0, false
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(pagination.DefaultLimit)))
+	if err != nil {
+		h.sendError(c, 400, "invalidValue", "count must be a number")
+		return 0, 0, false
+	}
+	return startIndex, count, true
+}
+
+func excludesMembers(c *gin.Context) bool {
+	for _, attribute := range strings.Split(c.Query("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(attribute), "members") {
+			return true
+		}
+	}
+	return false
+}
+
+// sendServiceError reports a service error in the SCIM format, with the
+// status its code has in the error catalog.
+func (h *SCIMHandler) sendServiceError(c *gin.Context, err error) {
+	serviceError, status := catalogError(err)
+
+	scimType := ""
+	switch {
+	case serviceError.Code == services.CodeInternal:
+		log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+	case errors.Is(err, services.ErrInvalidFilter):
+		scimType = "invalidFilter"
+	case errors.Is(err, services.ErrInvalidPatch):
+		scimType = "invalidSyntax"
+	case errors.Is(err, services.ErrUserNameImmutable), errors.Is(err, services.ErrDisplayNameImmutable):
+		scimType = "mutability"
+	case serviceError.Code == services.CodeUserExists, serviceError.Code == services.CodeTeamExists:
+		scimType = "uniqueness"
+	case status == 400:
+		scimType = "invalidValue"
+	}
+	h.sendError(c, status, scimType, serviceError.Message)
+}
+
+func (h *SCIMHandler) sendError(c *gin.Context, statusCode int, scimType, detail string) {
+	c.JSON(statusCode, models.SCIMError{
+		Schemas:  []string{models.SCIMErrorSchema},
+		Status:   strconv.Itoa(statusCode),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"prReviewerAssignment/internal/models"
 	"prReviewerAssignment/internal/services"
 	"github.com/gin-gonic/gin"
 )
@@ -17,18 +16,11 @@ func NewStatsHandler() *StatsHandler {
 }
 
 func (h *StatsHandler) GetReviewerStats(c *gin.Context) {
-	stats, err := h.statsService.GetReviewerStats()
+	stats, err := h.statsService.GetReviewerStats(c.Query("team_name"))
 	if err != nil {
-		h.sendError(c, "NOT_FOUND", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
 	c.JSON(200, stats)
 }
-
-func (h *StatsHandler) sendError(c *gin.Context, code, message string, statusCode int) {
-	errorResponse := models.ErrorResponse{}
-	errorResponse.Error.Code = code
-	errorResponse.Error.Message = message
-	c.JSON(statusCode, errorResponse)
-}
\ No newline at end of file
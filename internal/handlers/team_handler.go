@@ -18,27 +18,18 @@ func NewTeamHandler() *TeamHandler {
 
 func (h *TeamHandler) AddTeam(c *gin.Context) {
 	var team models.Team
-	if err := c.ShouldBindJSON(&team); err != nil {
-		h.sendError(c, "TEAM_EXISTS", "Invalid JSON", 400)
+	if !bindJSON(c, &team) {
 		return
 	}
 
-	if team.TeamName == "" {
-		h.sendError(c, "TEAM_EXISTS", "Team name is required", 400)
-		return
-	}
-	if len(team.Members) == 0 {
-		h.sendError(c, "TEAM_EXISTS", "Team must have at least one member", 400)
+	if err := checkNewTeam(team); err != nil {
+		c.Error(err)
 		return
 	}
 
 	createdTeam, err := h.teamService.CreateTeam(team)
 	if err != nil {
-		if err.Error() == "team already exists" {
-			h.sendError(c, "TEAM_EXISTS", "team_name already exists", 400)
-			return
-		}
-		h.sendError(c, "TEAM_EXISTS", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
@@ -47,29 +38,83 @@ func (h *TeamHandler) AddTeam(c *gin.Context) {
 	})
 }
 
+// checkNewTeam reports a team to create that lacks a name or members.
+func checkNewTeam(team models.Team) error {
+	var problems []models.FieldError
+	if team.TeamName == "" {
+		problems = append(problems, models.FieldError{Field: "team_name", Message: "is required"})
+	}
+	if len(team.Members) == 0 {
+		problems = append(problems, models.FieldError{Field: "members", Message: "must list at least one member"})
+	}
+	if len(problems) > 0 {
+		return services.Invalid(services.CodeValidation, problems)
+	}
+	return nil
+}
+
 func (h *TeamHandler) GetTeam(c *gin.Context) {
 	teamName := c.Query("team_name")
 	if teamName == "" {
-		h.sendError(c, "NOT_FOUND", "team_name parameter is required", 400)
+		c.Error(services.InvalidField(services.CodeInvalidQuery, "team_name", "is required"))
 		return
 	}
 
-	team, err := h.teamService.GetTeam(teamName)
+	includeDescendants := c.Query("include_descendants") == "true"
+
+	team, err := h.teamService.GetTeam(teamName, includeDescendants)
 	if err != nil {
-		if err.Error() == "team not found" {
-			h.sendError(c, "NOT_FOUND", "team not found", 404)
-			return
-		}
-		h.sendError(c, "NOT_FOUND", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
 	c.JSON(200, team)
 }
 
-func (h *TeamHandler) sendError(c *gin.Context, code, message string, statusCode int) {
-	errorResponse := models.ErrorResponse{}
-	errorResponse.Error.Code = code
-	errorResponse.Error.Message = message
-	c.JSON(statusCode, errorResponse)
+func (h *TeamHandler) UpdateTeam(c *gin.Context) {
+	var request models.UpdateTeamRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	team, handoffs, err := h.teamService.UpdateTeam(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, models.UpdateTeamResponse{
+		Team:     team,
+		Handoffs: handoffs,
+	})
+}
+
+func (h *TeamHandler) DeleteTeam(c *gin.Context) {
+	var request models.DeleteTeamRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	response, err := h.teamService.DeleteTeam(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *TeamHandler) ListTeams(c *gin.Context) {
+	var request models.ListTeamsRequest
+	if !bindQuery(c, &request) {
+		return
+	}
+
+	response, err := h.teamService.ListTeams(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
 }
@@ -22,23 +22,18 @@ func (h *UserHandler) SetUserActive(c *gin.Context) {
 		IsActive bool   `json:"is_active"`
 	}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		h.sendError(c, "NOT_FOUND", "Invalid JSON data", 400)
+	if !bindJSON(c, &request) {
 		return
 	}
 
 	if request.UserID == "" {
-		h.sendError(c, "NOT_FOUND", "user_id is required", 400)
+		c.Error(services.InvalidField(services.CodeValidation, "user_id", "is required"))
 		return
 	}
 
 	user, err := h.userService.SetUserActive(request.UserID, request.IsActive)
 	if err != nil {
-		if err.Error() == "user not found" {
-			h.sendError(c, "NOT_FOUND", "user not found", 404)
-			return
-		}
-		h.sendError(c, "NOT_FOUND", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
@@ -50,26 +45,45 @@ func (h *UserHandler) SetUserActive(c *gin.Context) {
 func (h *UserHandler) GetUserReviews(c *gin.Context) {
 	userID := c.Query("user_id")
 	if userID == "" {
-		h.sendError(c, "NOT_FOUND", "user_id parameter is required", 400)
+		c.Error(services.InvalidField(services.CodeInvalidQuery, "user_id", "is required"))
 		return
 	}
 
 	response, err := h.userService.GetUserReviews(userID)
 	if err != nil {
-		if err.Error() == "user not found" {
-			h.sendError(c, "NOT_FOUND", "user not found", 404)
-			return
-		}
-		h.sendError(c, "NOT_FOUND", "Internal server error", 500)
+		c.Error(err)
 		return
 	}
 
 	c.JSON(200, response)
 }
 
-func (h *UserHandler) sendError(c *gin.Context, code, message string, statusCode int) {
-	errorResponse := models.ErrorResponse{}
-	errorResponse.Error.Code = code
-	errorResponse.Error.Message = message
-	c.JSON(statusCode, errorResponse)
+func (h *UserHandler) MoveUser(c *gin.Context) {
+	var request models.MoveUserRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	response, err := h.userService.MoveUser(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
+}
+
+func (h *UserHandler) ListUsers(c *gin.Context) {
+	var request models.ListUsersRequest
+	if !bindQuery(c, &request) {
+		return
+	}
+
+	response, err := h.userService.ListUsers(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/services"
+)
+
+// V2Handler serves the resource-oriented v2 API on top of the services
+// behind the v1 routes. Resources are named in the path, changes use PATCH
+// and DELETE and every field is snake_case. Collections come as a
+// ListResponseV2: teams, users and pull requests are paged by cursor, while
+// the reviewers of a pull request, bounded by the team's reviewer limits,
+// always fit on one page. Reviewer stats are a single report, not a
+// collection.
+type V2Handler struct {
+	teamService  *services.TeamService
+	userService  *services.UserService
+	prService    *services.PRService
+	statsService *services.StatsService
+}
+
+func NewV2Handler() *V2Handler {
+	return &V2Handler{
+		teamService:  services.NewTeamService(),
+		userService:  services.NewUserService(),
+		prService:    services.NewPRService(),
+		statsService: services.NewStatsService(),
+	}
+}
+
+func (h *V2Handler) GetReviewerStats(c *gin.Context) {
+	stats, err := h.statsService.GetReviewerStats(c.Query("team_name"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, stats)
+}
+
+// resourcePath joins the path of a collection and the id of one of its
+// resources, for Location headers.
+func resourcePath(collection string, id string) string {
+	return collection + "/" + url.PathEscape(id)
+}
+
+func pullRequestV2(pr *models.PullRequest) models.PullRequestV2 {
+	reviewers := []string{}
+	json.Unmarshal(pr.AssignedReviewers, &reviewers)
+	if reviewers == nil {
+		reviewers = []string{}
+	}
+
+	return models.PullRequestV2{
+		PullRequestID:     pr.PullRequestID,
+		PullRequestName:   pr.PullRequestName,
+		AuthorID:          pr.AuthorID,
+		TeamName:          pr.TeamName,
+		Status:            pr.Status,
+		AssignedReviewers: reviewers,
+		CreatedAt:         pr.CreatedAt,
+		MergedAt:          pr.MergedAt,
+		Version:           pr.Version,
+	}
+}
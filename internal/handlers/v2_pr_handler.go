@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/services"
+)
+
+func (h *V2Handler) ListPullRequests(c *gin.Context) {
+	var request models.ListPullRequestsRequest
+	if !bindQuery(c, &request) {
+		return
+	}
+
+	h.listPullRequests(c, request)
+}
+
+func (h *V2Handler) listPullRequests(c *gin.Context, request models.ListPullRequestsRequest) {
+	prs, next, err := h.prService.ListPullRequests(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	items := make([]models.PullRequestV2, 0, len(prs))
+	for i := range prs {
+		items = append(items, pullRequestV2(&prs[i]))
+	}
+
+	c.JSON(200, models.ListResponseV2{Items: items, NextCursor: next})
+}
+
+func (h *V2Handler) CreatePullRequest(c *gin.Context) {
+	var request models.CreatePRRequest
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	pr, err := h.prService.CreatePullRequest(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.Header("Location", resourcePath("/v2/pull-requests", pr.PullRequestID))
+	c.JSON(201, pullRequestV2(pr))
+}
+
+func (h *V2Handler) GetPullRequest(c *gin.Context) {
+	pr, err := h.prService.GetPullRequest(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, pullRequestV2(pr))
+}
+
+// UpdatePullRequest merges the pull request when status is MERGED, the only
+// change it supports.
+func (h *V2Handler) UpdatePullRequest(c *gin.Context) {
+	var request models.PatchPullRequestRequestV2
+	if !bindJSON(c, &request) {
+		return
+	}
+	if request.Status != "MERGED" {
+		c.Error(services.InvalidField(services.CodeValidation, "status", "must be MERGED"))
+		return
+	}
+
+	pr, err := h.prService.MergePullRequest(c.Param("id"), ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, pullRequestV2(pr))
+}
+
+// ListReviewers returns every reviewer of a pull request as one page without
+// a next_cursor.
+func (h *V2Handler) ListReviewers(c *gin.Context) {
+	reviewers, err := h.prService.Reviewers(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, models.ListResponseV2{Items: reviewers})
+}
+
+func (h *V2Handler) AddReviewer(c *gin.Context) {
+	var request models.AddReviewerRequestV2
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	pr, err := h.prService.AddReviewer(c.Param("id"), request.ReviewerID, request.OverrideApprovedBy, ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, pullRequestV2(pr))
+}
+
+// RemoveReviewer takes override_approved_by from the query.
+func (h *V2Handler) RemoveReviewer(c *gin.Context) {
+	pr, err := h.prService.RemoveReviewer(c.Param("id"), c.Param("user_id"), c.Query("override_approved_by"), ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, pullRequestV2(pr))
+}
+
+func (h *V2Handler) ReassignReviewer(c *gin.Context) {
+	pr, newReviewer, err := h.prService.ReassignReviewer(c.Param("id"), c.Param("user_id"), ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, models.ReplacementV2{PullRequest: pullRequestV2(pr), ReplacedBy: newReviewer})
+}
+
+func (h *V2Handler) DeclineReview(c *gin.Context) {
+	var request models.DeclineReviewRequestV2
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	pr, newReviewer, err := h.prService.DeclineReview(c.Param("id"), c.Param("user_id"), request.Reason, ifMatch(c))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setETag(c, pr)
+	c.JSON(200, models.ReplacementV2{PullRequest: pullRequestV2(pr), ReplacedBy: newReviewer})
+}
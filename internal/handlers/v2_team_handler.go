@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"prReviewerAssignment/internal/models"
+)
+
+func (h *V2Handler) ListTeams(c *gin.Context) {
+	var request models.ListTeamsRequest
+	if !bindQuery(c, &request) {
+		return
+	}
+
+	response, err := h.teamService.ListTeams(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, models.ListResponseV2{Items: response.Teams, NextCursor: response.NextCursor})
+}
+
+func (h *V2Handler) CreateTeam(c *gin.Context) {
+	var team models.Team
+	if !bindJSON(c, &team) {
+		return
+	}
+
+	if err := checkNewTeam(team); err != nil {
+		c.Error(err)
+		return
+	}
+
+	createdTeam, err := h.teamService.CreateTeam(team)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Location", resourcePath("/v2/teams", createdTeam.TeamName))
+	c.JSON(201, createdTeam)
+}
+
+func (h *V2Handler) GetTeam(c *gin.Context) {
+	team, err := h.teamService.GetTeam(c.Param("name"), c.Query("include_descendants") == "true")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, team)
+}
+
+// UpdateTeam adds and removes members; the open reviews of removed members
+// are handed off.
+func (h *V2Handler) UpdateTeam(c *gin.Context) {
+	var request models.PatchTeamRequestV2
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	team, handoffs, err := h.teamService.UpdateTeam(models.UpdateTeamRequest{
+		TeamName:      c.Param("name"),
+		AddMembers:    request.AddMembers,
+		RemoveMembers: request.RemoveMembers,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, models.UpdateTeamResponse{
+		Team:     team,
+		Handoffs: handoffs,
+	})
+}
+
+// DeleteTeam takes the policy and transfer_to of /team/delete from the
+// query.
+func (h *V2Handler) DeleteTeam(c *gin.Context) {
+	response, err := h.teamService.DeleteTeam(models.DeleteTeamRequest{
+		TeamName:   c.Param("name"),
+		Policy:     c.Query("policy"),
+		TransferTo: c.Query("transfer_to"),
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"prReviewerAssignment/internal/models"
+)
+
+func (h *V2Handler) ListUsers(c *gin.Context) {
+	var request models.ListUsersRequest
+	if !bindQuery(c, &request) {
+		return
+	}
+
+	response, err := h.userService.ListUsers(request)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, models.ListResponseV2{Items: response.Users, NextCursor: response.NextCursor})
+}
+
+func (h *V2Handler) GetUser(c *gin.Context) {
+	user, err := h.userService.GetUser(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+func (h *V2Handler) UpdateUser(c *gin.Context) {
+	var request models.PatchUserRequestV2
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	user, err := h.userService.SetUserActive(c.Param("id"), *request.IsActive)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, user)
+}
+
+// SetUserTeam moves the user to another home team like /users/moveTeam.
+func (h *V2Handler) SetUserTeam(c *gin.Context) {
+	var request models.SetUserTeamRequestV2
+	if !bindJSON(c, &request) {
+		return
+	}
+
+	response, err := h.userService.MoveUser(models.MoveUserRequest{
+		UserID:         c.Param("id"),
+		TeamName:       request.TeamName,
+		HandOffReviews: request.HandOffReviews,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(200, response)
+}
+
+// ListUserReviews pages the pull requests the user reviews, optionally
+// filtered by status.
+func (h *V2Handler) ListUserReviews(c *gin.Context) {
+	var request models.ListPullRequestsRequest
+	if !bindQuery(c, &request) {
+		return
+	}
+
+	user, err := h.userService.GetUser(c.Param("id"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	request.ReviewerID = user.UserID
+	h.listPullRequests(c, request)
+}
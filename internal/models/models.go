@@ -6,45 +6,154 @@ import (
 )
 
 type TeamMember struct {
-	UserID   string `json:"user_id"`
-	Username string `json:"username"`
-	IsActive bool   `json:"is_active"`
+	UserID     string     `json:"user_id"`
+	Username   string     `json:"username"`
+	IsActive   bool       `json:"is_active"`
+	Role       string     `json:"role,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	ChatHandle string     `json:"chat_handle,omitempty"`
+	Timezone   string     `json:"timezone,omitempty"`
+	WorkStart  string     `json:"work_start,omitempty"`
+	WorkEnd    string     `json:"work_end,omitempty"`
+	Capacity   int        `json:"capacity,omitempty"`
+	OOOFrom    *time.Time `json:"ooo_from,omitempty"`
+	OOOUntil   *time.Time `json:"ooo_until,omitempty"`
+	Teams      []string   `json:"teams,omitempty"`
 }
 
 type Team struct {
-	TeamName string       `json:"team_name"`
-	Members  []TeamMember `json:"members"`
+	TeamName       string       `json:"team_name"`
+	ParentTeamName string       `json:"parent_team_name,omitempty"`
+	MinReviewers   int          `json:"min_reviewers"`
+	MaxReviewers   int          `json:"max_reviewers"`
+	SLAHours       int          `json:"sla_hours"`
+	SLAPolicy      string       `json:"sla_policy,omitempty"`
+	LeadUserID     string       `json:"lead_user_id,omitempty"`
+	Strategy       string       `json:"assignment_strategy,omitempty"`
+	DigestTime     string       `json:"digest_time,omitempty"`
+	QuietStart     string       `json:"quiet_hours_start,omitempty"`
+	QuietEnd       string       `json:"quiet_hours_end,omitempty"`
+	ChatWebhook    string       `json:"chat_webhook_url,omitempty"`
+	Members        []TeamMember `json:"members"`
+	SubTeams       []Team       `json:"sub_teams,omitempty"`
 }
 
 type User struct {
+	UserID       string     `gorm:"primaryKey" json:"user_id"`
+	Username     string     `gorm:"not null" json:"username"`
+	TeamName     string     `gorm:"not null" json:"team_name"`
+	IsActive     bool       `gorm:"not null;default:true" json:"is_active"`
+	Email        string     `json:"email,omitempty"`
+	ChatHandle   string     `json:"chat_handle,omitempty"`
+	Timezone     string     `gorm:"not null;default:'UTC'" json:"timezone"`
+	WorkStart    string     `gorm:"type:varchar(5);not null;default:'09:00'" json:"work_start"`
+	WorkEnd      string     `gorm:"type:varchar(5);not null;default:'18:00'" json:"work_end"`
+	Capacity     int        `gorm:"not null;default:0" json:"capacity"`
+	OOOFrom      *time.Time `gorm:"column:ooo_from" json:"ooo_from,omitempty"`
+	OOOUntil     *time.Time `gorm:"column:ooo_until" json:"ooo_until,omitempty"`
+	LastDigestAt *time.Time `json:"-"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"-"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"-"`
+}
+
+const (
+	StrategyRandom       = "RANDOM"
+	StrategyWorkingHours = "WORKING_HOURS"
+)
+
+const (
+	SLAPolicyEscalate   = "ESCALATE"
+	SLAPolicyNotifyLead = "NOTIFY_LEAD"
+	SLAPolicyReassign   = "REASSIGN"
+)
+
+// Leads receive SLA escalations and may approve overrides of the team's
+// reviewer limits. Observers are never assigned automatically but can be
+// added as reviewers by hand.
+const (
+	MembershipRoleLead     = "LEAD"
+	MembershipRoleMember   = "MEMBER"
+	MembershipRoleObserver = "OBSERVER"
+)
+
+// TeamMembership links a user to one of the teams they review for. A user
+// may belong to several teams; User.TeamName is their home team.
+type TeamMembership struct {
+	TeamName  string    `gorm:"primaryKey" json:"team_name"`
 	UserID    string    `gorm:"primaryKey" json:"user_id"`
-	Username  string    `gorm:"not null" json:"username"`
-	TeamName  string    `gorm:"not null" json:"team_name"`
-	IsActive  bool      `gorm:"not null;default:true" json:"is_active"`
+	Role      string    `gorm:"type:varchar(20);not null;default:'MEMBER'" json:"role"`
+	IsActive  bool      `gorm:"not null" json:"is_active"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
 }
 
 type TeamDB struct {
-	TeamName  string    `gorm:"primaryKey" json:"team_name"`
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+	TeamName       string    `gorm:"primaryKey" json:"team_name"`
+	ParentTeamName string    `gorm:"index" json:"parent_team_name"`
+	MinReviewers   int       `gorm:"not null;default:0" json:"min_reviewers"`
+	MaxReviewers   int       `gorm:"not null;default:2" json:"max_reviewers"`
+	SLAHours       int       `gorm:"not null;default:0" json:"sla_hours"`
+	SLAPolicy      string    `gorm:"type:varchar(20);not null;default:'ESCALATE'" json:"sla_policy"`
+	Strategy       string    `gorm:"column:assignment_strategy;type:varchar(20);not null;default:'RANDOM'" json:"assignment_strategy"`
+	DigestTime     string    `gorm:"type:varchar(5)" json:"digest_time"`
+	QuietStart     string    `gorm:"column:quiet_hours_start;type:varchar(5)" json:"quiet_hours_start"`
+	QuietEnd       string    `gorm:"column:quiet_hours_end;type:varchar(5)" json:"quiet_hours_end"`
+	ChatWebhook    string    `gorm:"column:chat_webhook_url" json:"chat_webhook_url,omitempty"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"-"`
 }
 
 type PullRequest struct {
 	PullRequestID     string         `gorm:"primaryKey" json:"pull_request_id"`
 	PullRequestName   string         `gorm:"not null" json:"pull_request_name"`
 	AuthorID          string         `gorm:"not null" json:"author_id"`
+	TeamName          string         `gorm:"not null;default:''" json:"team_name"`
 	Status            string         `gorm:"type:varchar(20);not null;default:'OPEN';check:status IN ('OPEN', 'MERGED')" json:"status"`
-	AssignedReviewers datatypes.JSON `gorm:"type:jsonb" json:"assigned_reviewers"`
+	AssignedReviewers datatypes.JSON `json:"assigned_reviewers"`
 	CreatedAt         time.Time      `gorm:"autoCreateTime" json:"createdAt"`
 	MergedAt          *time.Time     `json:"mergedAt,omitempty"`
+	Version           int            `gorm:"not null;default:1" json:"version"`
 
 	Author User `gorm:"foreignKey:AuthorID;references:UserID" json:"-"`
 }
 
+const (
+	DeclineReasonBusy               = "BUSY"
+	DeclineReasonConflictOfInterest = "CONFLICT_OF_INTEREST"
+	DeclineReasonLacksContext       = "LACKS_CONTEXT"
+)
+
+type ReviewDecline struct {
+	PullRequestID string    `gorm:"primaryKey" json:"pull_request_id"`
+	ReviewerID    string    `gorm:"primaryKey" json:"reviewer_id"`
+	Reason        string    `gorm:"type:varchar(30);not null" json:"reason"`
+	ReplacedBy    string    `json:"replaced_by"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type ReviewAssignment struct {
+	PullRequestID string     `gorm:"primaryKey" json:"pull_request_id"`
+	ReviewerID    string     `gorm:"primaryKey" json:"reviewer_id"`
+	AssignedAt    time.Time  `gorm:"not null" json:"assigned_at"`
+	EscalatedAt   *time.Time `json:"escalated_at,omitempty"`
+}
+
 type PullRequestShort struct {
 	PullRequestID   string `json:"pull_request_id"`
 	PullRequestName string `json:"pull_request_name"`
 	AuthorID        string `json:"author_id"`
+	TeamName        string `json:"team_name"`
 	Status          string `json:"status"`
 }
+
+// IdempotencyKey remembers the response to a POST request sent with an
+// Idempotency-Key header until ExpiresAt. Fingerprint identifies the request
+// the key was first used for; a zero StatusCode marks a request still being
+// handled. ResponseHeaders maps header names to values.
+type IdempotencyKey struct {
+	Key             string         `gorm:"column:idempotency_key;primaryKey" json:"key"`
+	Fingerprint     string         `gorm:"not null" json:"fingerprint"`
+	StatusCode      int            `gorm:"not null;default:0" json:"status_code"`
+	ResponseHeaders datatypes.JSON `json:"response_headers"`
+	ResponseBody    []byte         `json:"-"`
+	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt       time.Time      `gorm:"not null" json:"expires_at"`
+}
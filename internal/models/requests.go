@@ -4,6 +4,7 @@ type CreatePRRequest struct {
 	PullRequestID   string `json:"pull_request_id" binding:"required"`
 	PullRequestName string `json:"pull_request_name" binding:"required"`
 	AuthorID        string `json:"author_id" binding:"required"`
+	TeamName        string `json:"team_name"`
 }
 
 type MergePRRequest struct {
@@ -15,7 +16,87 @@ type ReassignPRRequest struct {
 	OldReviewerID string `json:"old_reviewer_id" binding:"required"`
 }
 
+type AddReviewerRequest struct {
+	PullRequestID      string `json:"pull_request_id" binding:"required"`
+	ReviewerID         string `json:"reviewer_id" binding:"required"`
+	OverrideApprovedBy string `json:"override_approved_by"`
+}
+
+type RemoveReviewerRequest struct {
+	PullRequestID      string `json:"pull_request_id" binding:"required"`
+	ReviewerID         string `json:"reviewer_id" binding:"required"`
+	OverrideApprovedBy string `json:"override_approved_by"`
+}
+
+type DeclineReviewRequest struct {
+	PullRequestID string `json:"pull_request_id" binding:"required"`
+	ReviewerID    string `json:"reviewer_id" binding:"required"`
+	Reason        string `json:"reason" binding:"required"`
+}
+
+type UpdateTeamRequest struct {
+	TeamName      string       `json:"team_name" binding:"required"`
+	AddMembers    []TeamMember `json:"add_members"`
+	RemoveMembers []string     `json:"remove_members"`
+}
+
+const (
+	DeletePolicyBlock    = "BLOCK"
+	DeletePolicyReassign = "REASSIGN"
+	DeletePolicyCascade  = "CASCADE"
+)
+
+type DeleteTeamRequest struct {
+	TeamName   string `json:"team_name" binding:"required"`
+	Policy     string `json:"policy"`
+	TransferTo string `json:"transfer_to"`
+}
+
 type SetUserActiveRequest struct {
 	UserID   string `json:"user_id" binding:"required"`
 	IsActive bool   `json:"is_active" binding:"required"`
 }
+
+type MoveUserRequest struct {
+	UserID         string `json:"user_id" binding:"required"`
+	TeamName       string `json:"team_name" binding:"required"`
+	HandOffReviews bool   `json:"hand_off_reviews"`
+}
+
+type ListTeamsRequest struct {
+	Limit          int    `form:"limit"`
+	Cursor         string `form:"cursor"`
+	Search         string `form:"search"`
+	ParentTeamName string `form:"parent_team_name"`
+	Sort           string `form:"sort"`
+}
+
+type ListUsersRequest struct {
+	Limit    int    `form:"limit"`
+	Cursor   string `form:"cursor"`
+	Search   string `form:"search"`
+	TeamName string `form:"team_name"`
+	IsActive *bool  `form:"is_active"`
+	Sort     string `form:"sort"`
+}
+
+// ImportMember is a team member in an import document. Members without
+// is_active are imported as active.
+type ImportMember struct {
+	TeamMember
+	IsActive *bool `json:"is_active"`
+}
+
+// ImportTeam is a team in an import document. The reviewer and SLA settings
+// are nil when the document leaves them out, since zero is a valid value.
+type ImportTeam struct {
+	Team
+	MinReviewers *int           `json:"min_reviewers"`
+	MaxReviewers *int           `json:"max_reviewers"`
+	SLAHours     *int           `json:"sla_hours"`
+	Members      []ImportMember `json:"members"`
+}
+
+type ImportDocument struct {
+	Teams []ImportTeam `json:"teams"`
+}
@@ -2,26 +2,114 @@ package models
 
 type ErrorResponse struct {
 	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+		Code    string       `json:"code"`
+		Message string       `json:"message"`
+		Details []FieldError `json:"details,omitempty"`
 	} `json:"error"`
 }
 
+// FieldError describes the problem with one field of an invalid request.
+// Field is empty for problems that concern no single field.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
 type ReassignPRResponse struct {
 	PR         *PullRequest `json:"pr"`
 	ReplacedBy string       `json:"replaced_by"`
 }
 
+type ReviewHandoff struct {
+	PullRequestID string `json:"pull_request_id"`
+	OldReviewerID string `json:"old_reviewer_id"`
+	NewReviewerID string `json:"new_reviewer_id"`
+}
+
+type UpdateTeamResponse struct {
+	Team     *Team           `json:"team"`
+	Handoffs []ReviewHandoff `json:"reassigned_reviews"`
+}
+
+type DeleteTeamResponse struct {
+	TeamName            string          `json:"team_name"`
+	Policy              string          `json:"policy"`
+	Members             []string        `json:"members"`
+	TransferredTo       string          `json:"transferred_to,omitempty"`
+	DeletedPullRequests []string        `json:"deleted_pull_requests"`
+	Handoffs            []ReviewHandoff `json:"reassigned_reviews"`
+}
+
+type MoveUserResponse struct {
+	User                 *User           `json:"user"`
+	PreviousTeam         string          `json:"previous_team"`
+	NewTeam              string          `json:"new_team"`
+	Handoffs             []ReviewHandoff `json:"reassigned_reviews"`
+	RetainedReviews      []string        `json:"retained_reviews"`
+	AuthoredPullRequests []string        `json:"authored_pull_requests"`
+}
+
 type UserReviewResponse struct {
 	UserID       string             `json:"user_id"`
+	Teams        []TeamMembership   `json:"teams"`
 	PullRequests []PullRequestShort `json:"pull_requests"`
 }
 
+type TeamSummary struct {
+	TeamName          string `json:"team_name"`
+	ParentTeamName    string `json:"parent_team_name,omitempty"`
+	MemberCount       int    `json:"member_count"`
+	ActiveMemberCount int    `json:"active_member_count"`
+}
+
+type ListTeamsResponse struct {
+	Teams      []TeamSummary `json:"teams"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+type ListUsersResponse struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+const (
+	RosterCreateTeam       = "CREATE_TEAM"
+	RosterUpdateTeam       = "UPDATE_TEAM"
+	RosterCreateUser       = "CREATE_USER"
+	RosterUpdateUser       = "UPDATE_USER"
+	RosterAddMember        = "ADD_MEMBER"
+	RosterUpdateMember     = "UPDATE_MEMBER"
+	RosterDeactivateMember = "DEACTIVATE_MEMBER"
+)
+
+// RosterChange is one step of an import or reconcile plan.
+type RosterChange struct {
+	Action   string   `json:"action"`
+	TeamName string   `json:"team_name,omitempty"`
+	UserID   string   `json:"user_id,omitempty"`
+	Fields   []string `json:"fields,omitempty"`
+}
+
+type ImportResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Changes []RosterChange `json:"changes"`
+}
+
+type ReconcileResponse struct {
+	Applied  bool            `json:"applied"`
+	Changes  []RosterChange  `json:"changes"`
+	Handoffs []ReviewHandoff `json:"reassigned_reviews,omitempty"`
+}
+
 type ReviewerStats struct {
-	UserID  string `json:"user_id"`
-	Count   int    `json:"count"`
+	UserID       string  `json:"user_id"`
+	Count        int     `json:"count"`
+	DeclineCount int     `json:"decline_count"`
+	DeclineRate  float64 `json:"decline_rate"`
 }
 
 type StatsResponse struct {
+	TeamName      string          `json:"team_name,omitempty"`
+	Teams         []string        `json:"teams,omitempty"`
 	ReviewerStats []ReviewerStats `json:"reviewer_stats"`
-}
\ No newline at end of file
+}
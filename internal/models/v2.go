@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// The v2 API addresses teams, users and pull requests as resources. It
+// shares the other models where they are already snake_case; the types here
+// are the ones it represents differently or takes from the path.
+
+// PullRequestV2 is a pull request with snake_case timestamps and its
+// reviewers as a list.
+type PullRequestV2 struct {
+	PullRequestID     string     `json:"pull_request_id"`
+	PullRequestName   string     `json:"pull_request_name"`
+	AuthorID          string     `json:"author_id"`
+	TeamName          string     `json:"team_name"`
+	Status            string     `json:"status"`
+	AssignedReviewers []string   `json:"assigned_reviewers"`
+	CreatedAt         time.Time  `json:"created_at"`
+	MergedAt          *time.Time `json:"merged_at,omitempty"`
+	Version           int        `json:"version"`
+}
+
+type ReplacementV2 struct {
+	PullRequest PullRequestV2 `json:"pull_request"`
+	ReplacedBy  string        `json:"replaced_by"`
+}
+
+// ListResponseV2 is a page of any collection. NextCursor is empty on the
+// last page.
+type ListResponseV2 struct {
+	Items      interface{} `json:"items"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+type PatchTeamRequestV2 struct {
+	AddMembers    []TeamMember `json:"add_members"`
+	RemoveMembers []string     `json:"remove_members"`
+}
+
+type PatchUserRequestV2 struct {
+	IsActive *bool `json:"is_active" binding:"required"`
+}
+
+type SetUserTeamRequestV2 struct {
+	TeamName       string `json:"team_name" binding:"required"`
+	HandOffReviews bool   `json:"hand_off_reviews"`
+}
+
+type PatchPullRequestRequestV2 struct {
+	Status string `json:"status" binding:"required"`
+}
+
+type AddReviewerRequestV2 struct {
+	ReviewerID         string `json:"reviewer_id" binding:"required"`
+	OverrideApprovedBy string `json:"override_approved_by"`
+}
+
+type DeclineReviewRequestV2 struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// ListPullRequestsRequest filters pull requests; zero fields do not filter.
+type ListPullRequestsRequest struct {
+	Limit      int    `form:"limit"`
+	Cursor     string `form:"cursor"`
+	TeamName   string `form:"team_name"`
+	AuthorID   string `form:"author_id"`
+	ReviewerID string `form:"reviewer_id"`
+	Status     string `form:"status"`
+}
@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	MentionSlack      = "slack"
+	MentionMattermost = "mattermost"
+)
+
+type ChatConfig struct {
+	// MentionFormat is MentionSlack for <@handle> or MentionMattermost for
+	// @handle mentions.
+	MentionFormat string
+	Timeout       time.Duration
+}
+
+func ChatConfigFromEnv() ChatConfig {
+	format := os.Getenv("CHAT_MENTION_FORMAT")
+	if format == "" {
+		format = MentionSlack
+	}
+
+	return ChatConfig{MentionFormat: format, Timeout: 10 * time.Second}
+}
+
+// ChatNotifier posts Slack/Mattermost compatible messages to the incoming
+// webhook of the PR team. Teams without a webhook are skipped.
+type ChatNotifier struct {
+	config    ChatConfig
+	directory Directory
+	teams     TeamDirectory
+	client    *http.Client
+}
+
+func NewChatNotifier(config ChatConfig, directory Directory, teams TeamDirectory) *ChatNotifier {
+	return &ChatNotifier{
+		config:    config,
+		directory: directory,
+		teams:     teams,
+		client:    &http.Client{Timeout: config.Timeout},
+	}
+}
+
+type chatMessage struct {
+	Text string `json:"text"`
+}
+
+func (n *ChatNotifier) Notify(ctx context.Context, event Event) error {
+	if event.Type != EventReviewerAssigned && event.Type != EventReviewerReplaced && event.Type != EventPRMerged {
+		return nil
+	}
+	if event.TeamName == "" {
+		return nil
+	}
+
+	webhook, err := n.teams.ChatWebhook(ctx, event.TeamName)
+	if err != nil || webhook == "" {
+		return err
+	}
+
+	contacts, err := n.directory.Contacts(ctx, []string{event.AuthorID, event.ReviewerID, event.PreviousReviewerID})
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(chatMessage{Text: n.format(event, contacts)})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("chat webhook for team %s returned %s", event.TeamName, response.Status)
+	}
+	return nil
+}
+
+func (n *ChatNotifier) format(event Event, contacts map[string]Contact) string {
+	pr := fmt.Sprintf("*%s* (%s) by %s", event.PullRequestName, event.PullRequestID, n.mention(event.AuthorID, contacts))
+
+	switch event.Type {
+	case EventReviewerReplaced:
+		return fmt.Sprintf(":arrows_counterclockwise: %s replaces %s as reviewer of %s",
+			n.mention(event.ReviewerID, contacts), n.mention(event.PreviousReviewerID, contacts), pr)
+	case EventPRMerged:
+		return fmt.Sprintf(":white_check_mark: %s has been merged", pr)
+	default:
+		return fmt.Sprintf(":eyes: %s please review %s", n.mention(event.ReviewerID, contacts), pr)
+	}
+}
+
+// mention renders the user's chat handle, falling back to their name for
+// users without one so that nobody gets pinged by accident.
+func (n *ChatNotifier) mention(userID string, contacts map[string]Contact) string {
+	contact, ok := contacts[userID]
+	if !ok {
+		return userID
+	}
+	if contact.ChatHandle == "" {
+		if contact.Name != "" {
+			return contact.Name
+		}
+		return userID
+	}
+
+	if n.config.MentionFormat == MentionMattermost {
+		return "@" + contact.ChatHandle
+	}
+	return "<@" + contact.ChatHandle + ">"
+}
@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticTeams map[string]string
+
+func (t staticTeams) ChatWebhook(ctx context.Context, teamName string) (string, error) {
+	return t[teamName], nil
+}
+
+type chatStandIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	messages []string
+}
+
+func startChatStandIn(t *testing.T, status int) *chatStandIn {
+	standIn := &chatStandIn{}
+	standIn.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var message chatMessage
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&message))
+		standIn.mu.Lock()
+		standIn.messages = append(standIn.messages, message.Text)
+		standIn.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(standIn.Close)
+	return standIn
+}
+
+var chatDirectory = staticDirectory{
+	"u1": {UserID: "u1", Name: "Alice", ChatHandle: "alice"},
+	"u2": {UserID: "u2", Name: "Bob", ChatHandle: "U02BOB"},
+	"u3": {UserID: "u3", Name: "Carol"},
+}
+
+func TestChatNotifierSlackMentions(t *testing.T) {
+	standIn := startChatStandIn(t, http.StatusOK)
+	notifier := NewChatNotifier(ChatConfig{MentionFormat: MentionSlack, Timeout: time.Second}, chatDirectory, staticTeams{"backend": standIn.URL})
+
+	ctx := context.Background()
+	base := Event{TeamName: "backend", PullRequestID: "pr-1001", PullRequestName: "Add search", AuthorID: "u1"}
+
+	assigned := base
+	assigned.Type = EventReviewerAssigned
+	assigned.ReviewerID = "u2"
+	require.NoError(t, notifier.Notify(ctx, assigned))
+
+	replaced := base
+	replaced.Type = EventReviewerReplaced
+	replaced.ReviewerID = "u3"
+	replaced.PreviousReviewerID = "u2"
+	require.NoError(t, notifier.Notify(ctx, replaced))
+
+	merged := base
+	merged.Type = EventPRMerged
+	require.NoError(t, notifier.Notify(ctx, merged))
+
+	digest := Event{Type: EventReviewDigest, TeamName: "backend", Recipients: []string{"u2"}}
+	require.NoError(t, notifier.Notify(ctx, digest))
+
+	assert.Equal(t, []string{
+		":eyes: <@U02BOB> please review *Add search* (pr-1001) by <@alice>",
+		":arrows_counterclockwise: Carol replaces <@U02BOB> as reviewer of *Add search* (pr-1001) by <@alice>",
+		":white_check_mark: *Add search* (pr-1001) by <@alice> has been merged",
+	}, standIn.messages)
+}
+
+func TestChatNotifierMattermostMentions(t *testing.T) {
+	standIn := startChatStandIn(t, http.StatusOK)
+	notifier := NewChatNotifier(ChatConfig{MentionFormat: MentionMattermost, Timeout: time.Second}, chatDirectory, staticTeams{"backend": standIn.URL})
+
+	err := notifier.Notify(context.Background(), Event{
+		Type:            EventReviewerAssigned,
+		TeamName:        "backend",
+		PullRequestID:   "pr-1001",
+		PullRequestName: "Add search",
+		AuthorID:        "u1",
+		ReviewerID:      "u2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{":eyes: @U02BOB please review *Add search* (pr-1001) by @alice"}, standIn.messages)
+}
+
+func TestChatNotifierSkipsTeamsWithoutWebhookAndReportsFailures(t *testing.T) {
+	standIn := startChatStandIn(t, http.StatusInternalServerError)
+	notifier := NewChatNotifier(ChatConfig{MentionFormat: MentionSlack, Timeout: time.Second}, chatDirectory, staticTeams{"backend": standIn.URL})
+
+	event := Event{Type: EventPRMerged, TeamName: "frontend", PullRequestID: "pr-1", AuthorID: "u1"}
+	assert.NoError(t, notifier.Notify(context.Background(), event))
+	assert.Empty(t, standIn.messages)
+
+	event.TeamName = "backend"
+	assert.Error(t, notifier.Notify(context.Background(), event))
+	assert.Len(t, standIn.messages, 1)
+}
@@ -0,0 +1,232 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// TemplateDir may hold <event>.subject.tmpl, <event>.txt.tmpl and
+	// <event>.html.tmpl files that replace the built-in templates, e.g.
+	// reviewer_assigned.html.tmpl.
+	TemplateDir string
+}
+
+func SMTPConfigFromEnv() SMTPConfig {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+
+	return SMTPConfig{
+		Host:        os.Getenv("SMTP_HOST"),
+		Port:        port,
+		Username:    os.Getenv("SMTP_USERNAME"),
+		Password:    os.Getenv("SMTP_PASSWORD"),
+		From:        os.Getenv("SMTP_FROM"),
+		TemplateDir: os.Getenv("SMTP_TEMPLATE_DIR"),
+	}
+}
+
+var emailEvents = []EventType{
+	EventReviewerAssigned,
+	EventReviewerReplaced,
+	EventPRMerged,
+	EventSLABreached,
+	EventReviewDigest,
+}
+
+var templateFuncs = map[string]interface{}{
+	"age": func(d time.Duration) string {
+		if d < time.Hour {
+			return d.Round(time.Minute).String()
+		}
+		return d.Round(time.Hour).String()
+	},
+}
+
+type emailTemplates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+type emailData struct {
+	Event     Event
+	Recipient Contact
+}
+
+// EmailNotifier sends every event to its recipients' email addresses.
+// Recipients without an address are skipped.
+type EmailNotifier struct {
+	config    SMTPConfig
+	directory Directory
+	templates map[EventType]emailTemplates
+}
+
+func NewEmailNotifier(config SMTPConfig, directory Directory) (*EmailNotifier, error) {
+	if config.Host == "" || config.From == "" {
+		return nil, errors.New("SMTP host and sender address are required")
+	}
+
+	templates := make(map[EventType]emailTemplates)
+	for _, eventType := range emailEvents {
+		name := strings.ToLower(string(eventType))
+
+		subject, err := readTemplate(config.TemplateDir, name+".subject.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		text, err := readTemplate(config.TemplateDir, name+".txt.tmpl")
+		if err != nil {
+			return nil, err
+		}
+		html, err := readTemplate(config.TemplateDir, name+".html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+
+		var t emailTemplates
+		if t.subject, err = texttemplate.New(name).Funcs(templateFuncs).Parse(strings.TrimSpace(subject)); err != nil {
+			return nil, err
+		}
+		if t.text, err = texttemplate.New(name).Funcs(templateFuncs).Parse(text); err != nil {
+			return nil, err
+		}
+		if t.html, err = htmltemplate.New(name).Funcs(templateFuncs).Parse(html); err != nil {
+			return nil, err
+		}
+		templates[eventType] = t
+	}
+
+	return &EmailNotifier{config: config, directory: directory, templates: templates}, nil
+}
+
+func readTemplate(dir, name string) (string, error) {
+	if dir != "" {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(content), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	content, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, event Event) error {
+	templates, ok := n.templates[event.Type]
+	if !ok || len(event.Recipients) == 0 {
+		return nil
+	}
+
+	contacts, err := n.directory.Contacts(ctx, event.Recipients)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, userID := range event.Recipients {
+		contact, ok := contacts[userID]
+		if !ok || contact.Email == "" {
+			continue
+		}
+		if contact.Name == "" {
+			contact.Name = contact.UserID
+		}
+
+		message, err := n.render(templates, emailData{Event: event, Recipient: contact})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := n.send(contact.Email, message); err != nil {
+			errs = append(errs, fmt.Errorf("send %s email to %s: %w", event.Type, contact.UserID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (n *EmailNotifier) render(templates emailTemplates, data emailData) ([]byte, error) {
+	var subject, text, html bytes.Buffer
+	if err := templates.subject.Execute(&subject, data); err != nil {
+		return nil, err
+	}
+	if err := templates.text.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := templates.html.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", text.Bytes()},
+		{"text/html; charset=utf-8", html.Bytes()},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(part.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&message, "To: %s\r\n", data.Recipient.Email)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", parts.Boundary())
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+func (n *EmailNotifier) send(to string, message []byte) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+
+	addr := net.JoinHostPort(n.config.Host, n.config.Port)
+	return smtp.SendMail(addr, auth, n.config.From, []string{to}, message)
+}
@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeSMTPServer speaks just enough SMTP for net/smtp.SendMail.
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	mails    []receivedMail
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := &fakeSMTPServer{listener: listener}
+	go server.serve()
+	t.Cleanup(func() { listener.Close() })
+	return server
+}
+
+func (s *fakeSMTPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var mail receivedMail
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.TrimSpace(line)
+		upper := strings.ToUpper(command)
+
+		switch {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			mail = receivedMail{from: strings.Trim(command[len("MAIL FROM:"):], "<> ")}
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			mail.to = append(mail.to, strings.Trim(command[len("RCPT TO:"):], "<> "))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			mail.data = data.String()
+			s.mu.Lock()
+			s.mails = append(s.mails, mail)
+			s.mu.Unlock()
+			reply("250 OK")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) received() []receivedMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]receivedMail(nil), s.mails...)
+}
+
+func (s *fakeSMTPServer) config() SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	return SMTPConfig{Host: host, Port: port, From: "reviews@example.com"}
+}
+
+type staticDirectory map[string]Contact
+
+func (d staticDirectory) Contacts(ctx context.Context, userIDs []string) (map[string]Contact, error) {
+	contacts := make(map[string]Contact)
+	for _, id := range userIDs {
+		if contact, ok := d[id]; ok {
+			contacts[id] = contact
+		}
+	}
+	return contacts, nil
+}
+
+var testDirectory = staticDirectory{
+	"u1": {UserID: "u1", Name: "Alice", Email: "alice@example.com"},
+	"u2": {UserID: "u2", Name: "Bob", Email: "bob@example.com"},
+	"u3": {UserID: "u3", Name: "Carol"},
+}
+
+func TestEmailNotifierSendsReviewerAssignment(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	notifier, err := NewEmailNotifier(server.config(), testDirectory)
+	require.NoError(t, err)
+
+	err = notifier.Notify(context.Background(), Event{
+		Type:            EventReviewerAssigned,
+		PullRequestID:   "pr-1001",
+		PullRequestName: "Add search",
+		AuthorID:        "u1",
+		ReviewerID:      "u2",
+		Recipients:      []string{"u2", "u3"},
+	})
+	require.NoError(t, err)
+
+	mails := server.received()
+	require.Len(t, mails, 1)
+	assert.Equal(t, "reviews@example.com", mails[0].from)
+	assert.Equal(t, []string{"bob@example.com"}, mails[0].to)
+	assert.Contains(t, mails[0].data, "Subject: Review requested: Add search")
+	assert.Contains(t, mails[0].data, "Content-Type: text/plain; charset=utf-8")
+	assert.Contains(t, mails[0].data, "Content-Type: text/html; charset=utf-8")
+	assert.Contains(t, mails[0].data, `You have been assigned to review "Add search" (pr-1001) by u1.`)
+	assert.Contains(t, mails[0].data, "<b>Add search</b>")
+}
+
+func TestEmailNotifierTemplateOverride(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	config := server.config()
+	config.TemplateDir = t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(config.TemplateDir, "pr_merged.subject.tmpl"), []byte("Shipped {{.Event.PullRequestID}}"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(config.TemplateDir, "pr_merged.txt.tmpl"), []byte("Well done, {{.Recipient.Name}}!"), 0o644))
+
+	notifier, err := NewEmailNotifier(config, testDirectory)
+	require.NoError(t, err)
+
+	err = notifier.Notify(context.Background(), Event{
+		Type:            EventPRMerged,
+		PullRequestID:   "pr-1001",
+		PullRequestName: "Add <search>",
+		AuthorID:        "u1",
+		Recipients:      []string{"u1"},
+	})
+	require.NoError(t, err)
+
+	mails := server.received()
+	require.Len(t, mails, 1)
+	assert.Equal(t, []string{"alice@example.com"}, mails[0].to)
+	assert.Contains(t, mails[0].data, "Subject: Shipped pr-1001")
+	assert.Contains(t, mails[0].data, "Well done, Alice!")
+	// The HTML part still comes from the built-in template and is escaped.
+	assert.Contains(t, mails[0].data, "<b>Add &lt;search&gt;</b>")
+}
+
+func TestEmailNotifierDigest(t *testing.T) {
+	server := startFakeSMTPServer(t)
+	notifier, err := NewEmailNotifier(server.config(), testDirectory)
+	require.NoError(t, err)
+
+	err = notifier.Notify(context.Background(), Event{
+		Type:       EventReviewDigest,
+		Recipients: []string{"u2"},
+		Digest: []DigestEntry{
+			{PullRequestID: "pr-1", PullRequestName: "Add search", AuthorID: "u1", Age: 26*time.Hour + 10*time.Minute},
+			{PullRequestID: "pr-2", PullRequestName: "Fix login", AuthorID: "u1", Age: 20 * time.Minute},
+		},
+	})
+	require.NoError(t, err)
+
+	mails := server.received()
+	require.Len(t, mails, 1)
+	assert.Contains(t, mails[0].data, "Subject: 2 pull requests are waiting for your review")
+	assert.Contains(t, mails[0].data, "- Add search (pr-1) by u1, open for 26h0m0s")
+	assert.Contains(t, mails[0].data, "- Fix login (pr-2) by u1, open for 20m0s")
+}
@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventReviewerAssigned EventType = "REVIEWER_ASSIGNED"
+	EventReviewerReplaced EventType = "REVIEWER_REPLACED"
+	EventPRMerged         EventType = "PR_MERGED"
+	EventSLABreached      EventType = "SLA_BREACHED"
+	EventReviewDigest     EventType = "REVIEW_DIGEST"
+)
+
+type DigestEntry struct {
+	PullRequestID   string
+	PullRequestName string
+	AuthorID        string
+	Age             time.Duration
+}
+
+type Event struct {
+	Type               EventType
+	TeamName           string
+	PullRequestID      string
+	PullRequestName    string
+	AuthorID           string
+	ReviewerID         string
+	PreviousReviewerID string
+	Recipients         []string
+	Digest             []DigestEntry
+	OccurredAt         time.Time
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+type Contact struct {
+	UserID     string
+	Name       string
+	Email      string
+	ChatHandle string
+}
+
+// Directory resolves user ids to contact details for notifiers that deliver
+// outside of this service.
+type Directory interface {
+	Contacts(ctx context.Context, userIDs []string) (map[string]Contact, error)
+}
+
+// TeamDirectory resolves per-team delivery settings.
+type TeamDirectory interface {
+	ChatWebhook(ctx context.Context, teamName string) (string, error)
+}
+
+var Default Notifier = NewLogNotifier(log.Default())
+
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, event Event) error {
+	if event.Type == EventReviewDigest {
+		n.logger.Printf("notify %s: recipients=%s pending=%d", event.Type, strings.Join(event.Recipients, ","), len(event.Digest))
+		for _, entry := range event.Digest {
+			n.logger.Printf("  %s %q by %s, open for %s", entry.PullRequestID, entry.PullRequestName, entry.AuthorID, entry.Age.Round(time.Minute))
+		}
+		return nil
+	}
+
+	n.logger.Printf("notify %s: team=%s pr=%s reviewer=%s recipients=%s",
+		event.Type, event.TeamName, event.PullRequestID, event.ReviewerID, strings.Join(event.Recipients, ","))
+	return nil
+}
+
+// Multi delivers every event to all wrapped notifiers, so one failing
+// channel does not prevent delivery through the others.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []error
+	for _, notifier := range m {
+		if err := notifier.Notify(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Init sets Default from the environment: events are always logged, posted
+// to the chat webhook of teams that have one and additionally emailed when
+// SMTP_HOST is configured.
+func Init(directory Directory, teams TeamDirectory) error {
+	notifiers := Multi{
+		NewLogNotifier(log.Default()),
+		NewChatNotifier(ChatConfigFromEnv(), directory, teams),
+	}
+
+	config := SMTPConfigFromEnv()
+	if config.Host != "" {
+		email, err := NewEmailNotifier(config, directory)
+		if err != nil {
+			return err
+		}
+		notifiers = append(notifiers, email)
+	}
+
+	Default = notifiers
+	return nil
+}
@@ -0,0 +1,40 @@
+// Package openapi holds the OpenAPI 3 document describing every route of the
+// service, a page rendering it and middleware checking traffic against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var document []byte
+
+// DocsPage is a self-contained HTML page rendering the document served at
+// /openapi.json.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// Load parses and validates the document.
+func Load() (*openapi3.T, error) {
+	spec, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, err
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// JSON returns the document as JSON.
+func JSON() ([]byte, error) {
+	spec, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(spec)
+}
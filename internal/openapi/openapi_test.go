@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+	assert.NotNil(t, spec.Paths.Find("/pullRequest/create"))
+	assert.NotNil(t, spec.Paths.Find("/scim/v2/Users/{id}"))
+}
+
+func TestValidatorReportsDrift(t *testing.T) {
+	var pr string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(200)
+		w.Write([]byte(`{"pr": {"pull_request_id": "pr-1", "pull_request_name": "Fix", "author_id": "u1", "team_name": "backend",
+			"status": "MERGED", "assigned_reviewers": ["u2"], ` + pr + `, "version": 2}}`))
+	})
+
+	var violations []error
+	validator, err := NewValidator(handler, func(err error) {
+		violations = append(violations, err)
+	})
+	require.NoError(t, err)
+
+	merge := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", "/pullRequest/merge", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		validator.ServeHTTP(response, request)
+		return response
+	}
+
+	pr = `"createdAt": "2025-11-24T08:00:00Z", "mergedAt": "2025-11-24T09:00:00Z"`
+	response := merge(`{"pull_request_id": "pr-1"}`)
+	assert.Equal(t, 200, response.Code)
+	assert.Contains(t, response.Body.String(), `"version": 2`)
+	assert.Empty(t, violations)
+
+	pr = `"created_at": "2025-11-24T08:00:00Z"`
+	merge(`{"pull_request_id": "pr-1"}`)
+	if assert.Len(t, violations, 1) {
+		assert.Contains(t, violations[0].Error(), "response 200")
+		assert.Contains(t, violations[0].Error(), "createdAt")
+	}
+
+	violations = nil
+	pr = `"createdAt": "2025-11-24T08:00:00Z"`
+	merge(`{"pullRequestId": "pr-1"}`)
+	if assert.Len(t, violations, 1) {
+		assert.Contains(t, violations[0].Error(), "request")
+		assert.Contains(t, violations[0].Error(), "pull_request_id")
+	}
+
+	violations = nil
+	request := httptest.NewRequest("GET", "/pullRequest/list", nil)
+	validator.ServeHTTP(httptest.NewRecorder(), request)
+	assert.Len(t, violations, 1)
+}
+
+var snakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// TestPropertiesAreSnakeCase fails on a property that is not snake_case
+// unless it, or the schema it belongs to, documents why in
+// x-casing-exception. Together with the validator this keeps a casing
+// mismatch from reaching the contract unnoticed.
+func TestPropertiesAreSnakeCase(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+
+	var violations []string
+	var check func(where string, schema *openapi3.SchemaRef, exempt bool)
+	check = func(where string, schema *openapi3.SchemaRef, exempt bool) {
+		// Referenced schemas are checked as components.
+		if schema == nil || schema.Ref != "" || schema.Value == nil {
+			return
+		}
+		value := schema.Value
+		if _, ok := value.Extensions["x-casing-exception"]; ok {
+			exempt = true
+		}
+		for name, property := range value.Properties {
+			documented := exempt
+			if property.Ref == "" && property.Value != nil {
+				_, ok := property.Value.Extensions["x-casing-exception"]
+				documented = documented || ok
+			}
+			if !documented && !snakeCase.MatchString(name) {
+				violations = append(violations, where+"."+name)
+			}
+			check(where+"."+name, property, exempt)
+		}
+		check(where+"[]", value.Items, exempt)
+		for _, schemas := range []openapi3.SchemaRefs{value.AllOf, value.OneOf, value.AnyOf} {
+			for _, nested := range schemas {
+				check(where, nested, exempt)
+			}
+		}
+	}
+
+	for name, schema := range spec.Components.Schemas {
+		check(name, schema, false)
+	}
+	for path, item := range spec.Paths.Map() {
+		for method, operation := range item.Operations() {
+			if operation.RequestBody != nil && operation.RequestBody.Value != nil {
+				for _, media := range operation.RequestBody.Value.Content {
+					check(method+" "+path+" request", media.Schema, false)
+				}
+			}
+			for status, response := range operation.Responses.Map() {
+				for _, media := range response.Value.Content {
+					check(method+" "+path+" "+status, media.Schema, false)
+				}
+			}
+		}
+	}
+
+	sort.Strings(violations)
+	assert.Empty(t, violations, "properties that are neither snake_case nor marked with x-casing-exception")
+}
@@ -0,0 +1,113 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"io"
+	"net/http"
+	"net/http/httptest"
+)
+
+func init() {
+	openapi3filter.RegisterBodyDecoder("application/scim+json", openapi3filter.JSONBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("text/html", openapi3filter.RegisteredBodyDecoder("text/plain"))
+}
+
+// Validator is middleware checking every request and response passing
+// through it against the document. It reports requests to routes the
+// document lacks, requests breaking their operation's parameters or body
+// and responses with a status, header or body the operation does not
+// describe. Violations are only reported; the traffic is left unchanged.
+// Request violations wrap an *openapi3filter.RequestError.
+//
+// Validator buffers every response, so it is meant for tests rather than
+// for serving.
+type Validator struct {
+	router routers.Router
+	next   http.Handler
+	report func(error)
+}
+
+// NewValidator checks the traffic handled by next and calls report with
+// each violation.
+func NewValidator(next http.Handler, report func(error)) (*Validator, error) {
+	spec, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{router: router, next: next, report: report}, nil
+}
+
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route, pathParams, err := v.router.FindRoute(r)
+	if err != nil {
+		v.report(fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+		v.next.ServeHTTP(w, r)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		v.report(fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+		v.next.ServeHTTP(w, r)
+		return
+	}
+
+	request := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+			MultiError:          true,
+			SkipSettingDefaults: true,
+		},
+	}
+	if err := openapi3filter.ValidateRequest(r.Context(), request); err != nil {
+		v.report(fmt.Errorf("%s %s: request: %w", r.Method, r.URL.Path, err))
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	recorder := httptest.NewRecorder()
+	v.next.ServeHTTP(recorder, r)
+
+	response := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: request,
+		Status:                 recorder.Code,
+		Header:                 recorder.Header(),
+		Options: &openapi3filter.Options{
+			IncludeResponseStatus: true,
+			MultiError:            true,
+		},
+	}
+	response.SetBodyBytes(recorder.Body.Bytes())
+	if err := openapi3filter.ValidateResponse(r.Context(), response); err != nil {
+		v.report(fmt.Errorf("%s %s: response %d: %w", r.Method, r.URL.Path, recorder.Code, err))
+	}
+
+	for name, values := range recorder.Header() {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(recorder.Code)
+	w.Write(recorder.Body.Bytes())
+}
+
+// readBody reads the body of r and puts it back for the next reader.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,120 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"gorm.io/gorm"
+	"strings"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidLimit  = errors.New("invalid limit")
+)
+
+// Cursor points just past the last item of a page: the value of the sort
+// column and the id that breaks ties between equal values.
+type Cursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Encode returns the cursor as an opaque URL-safe string.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode parses a cursor produced by Encode. An empty string means the first
+// page and yields nil.
+func Decode(value string) (*Cursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// Limit applies the default page size and rejects sizes outside
+// 1..MaxLimit.
+func Limit(limit int) (int, error) {
+	if limit == 0 {
+		return DefaultLimit, nil
+	}
+	if limit < 0 || limit > MaxLimit {
+		return 0, ErrInvalidLimit
+	}
+	return limit, nil
+}
+
+// Sort is a sort field with its direction.
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses "field" or "-field" for descending order. The field must
+// be one of fields; an empty value sorts by the first of them.
+func ParseSort(value string, fields ...string) (Sort, error) {
+	if value == "" {
+		return Sort{Field: fields[0]}, nil
+	}
+
+	sort := Sort{Field: strings.TrimPrefix(value, "-"), Desc: strings.HasPrefix(value, "-")}
+	for _, field := range fields {
+		if field == sort.Field {
+			return sort, nil
+		}
+	}
+
+	return Sort{}, ErrInvalidSort
+}
+
+// Page orders query by column and then idColumn in the sort direction,
+// continues after cursor and fetches one extra row so the caller can tell
+// whether there is a next page. value is the cursor value converted to the
+// column's type.
+func Page(query *gorm.DB, column string, idColumn string, desc bool, cursor *Cursor, value interface{}, limit int) *gorm.DB {
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+
+	if cursor != nil {
+		if column == idColumn {
+			query = query.Where(idColumn+" "+op+" ?", cursor.ID)
+		} else {
+			query = query.Where("("+column+" "+op+" ? OR ("+column+" = ? AND "+idColumn+" "+op+" ?))", value, value, cursor.ID)
+		}
+	}
+
+	if column != idColumn {
+		query = query.Order(column + " " + direction)
+	}
+
+	return query.Order(idColumn + " " + direction).Limit(limit + 1)
+}
+
+// PrefixPattern returns a LIKE pattern matching values that start with
+// prefix; use it with ESCAPE '\'.
+func PrefixPattern(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(prefix) + "%"
+}
@@ -0,0 +1,57 @@
+package pagination
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := Cursor{Value: "Alice", ID: "u1"}
+
+	decoded, err := Decode(cursor.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, &cursor, decoded)
+
+	decoded, err = Decode("")
+	assert.NoError(t, err)
+	assert.Nil(t, decoded)
+
+	for _, value := range []string{"not base64!", "bm90IGpzb24", Cursor{Value: "x"}.Encode()} {
+		_, err := Decode(value)
+		assert.ErrorIs(t, err, ErrInvalidCursor, value)
+	}
+}
+
+func TestLimit(t *testing.T) {
+	limit, err := Limit(0)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultLimit, limit)
+
+	limit, err = Limit(10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, limit)
+
+	_, err = Limit(-1)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	_, err = Limit(MaxLimit + 1)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
+
+func TestParseSort(t *testing.T) {
+	sort, err := ParseSort("", "user_id", "username")
+	assert.NoError(t, err)
+	assert.Equal(t, Sort{Field: "user_id"}, sort)
+
+	sort, err = ParseSort("-username", "user_id", "username")
+	assert.NoError(t, err)
+	assert.Equal(t, Sort{Field: "username", Desc: true}, sort)
+
+	_, err = ParseSort("password", "user_id", "username")
+	assert.ErrorIs(t, err, ErrInvalidSort)
+}
+
+func TestPrefixPattern(t *testing.T) {
+	assert.Equal(t, "back%", PrefixPattern("back"))
+	assert.Equal(t, `100\%\_off\\%`, PrefixPattern(`100%_off\`))
+}
@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/pagination"
+	"time"
+)
+
+// The column defaults Create fills in for zero fields, as in the tables.
+const (
+	defaultTimezone     = "UTC"
+	defaultWorkStart    = "09:00"
+	defaultWorkEnd      = "18:00"
+	defaultMaxReviewers = 2
+	defaultSLAPolicy    = models.SLAPolicyEscalate
+	defaultStrategy     = models.StrategyRandom
+	defaultStatus       = "OPEN"
+)
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore keeps the data in the database behind db. Open the database
+// with TranslateError set so duplicate keys are reported as ErrDuplicate.
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Users() UserRepository {
+	return &gormUsers{db: s.db}
+}
+
+func (s *gormStore) Teams() TeamRepository {
+	return &gormTeams{db: s.db}
+}
+
+func (s *gormStore) PullRequests() PullRequestRepository {
+	return &gormPullRequests{db: s.db}
+}
+
+func (s *gormStore) IdempotencyKeys() IdempotencyKeyRepository {
+	return &gormIdempotencyKeys{db: s.db}
+}
+
+func (s *gormStore) Transaction(fn func(tx Store) error) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	})
+}
+
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	return err
+}
+
+// updated reports a write that matched no row as ErrNotFound.
+func updated(result *gorm.DB) error {
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// pageQuery orders query by page.Sort within table and applies the cursor,
+// offset and limit of page.
+func pageQuery(query *gorm.DB, page Page, table string, idColumn string) *gorm.DB {
+	column := idColumn
+	if page.Sort.Field != "" {
+		column = page.Sort.Field
+	}
+
+	query = pagination.Page(query, table+"."+column, table+"."+idColumn, page.Sort.Desc, page.Cursor, page.CursorValue, page.Limit)
+	if page.Limit == 0 {
+		query = query.Limit(-1)
+	}
+	if page.Offset > 0 {
+		query = query.Offset(page.Offset)
+	}
+	return query
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) Get(userID string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("user_id = ?", userID).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r *gormUsers) Find(userIDs []string) ([]models.User, error) {
+	users := []models.User{}
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+	if err := r.db.Where("user_id IN ?", userIDs).Order("user_id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUsers) query(query UserQuery) *gorm.DB {
+	db := r.db.Model(&models.User{})
+	if query.Search != "" {
+		pattern := pagination.PrefixPattern(query.Search)
+		db = db.Where(`(LOWER(users.user_id) LIKE LOWER(?) ESCAPE '\' OR LOWER(users.username) LIKE LOWER(?) ESCAPE '\')`, pattern, pattern)
+	}
+	if query.TeamName != "" {
+		db = db.Where("users.user_id IN (?)", r.db.Model(&models.TeamMembership{}).Select("user_id").Where("team_name = ?", query.TeamName))
+	}
+	if query.HomeTeam != "" {
+		db = db.Where("users.team_name = ?", query.HomeTeam)
+	}
+	if query.IsActive != nil {
+		db = db.Where("users.is_active = ?", *query.IsActive)
+	}
+	return db
+}
+
+func (r *gormUsers) List(query UserQuery) ([]models.User, error) {
+	users := []models.User{}
+	if err := pageQuery(r.query(query), query.Page, "users", "user_id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *gormUsers) Count(query UserQuery) (int, error) {
+	var count int64
+	if err := r.query(query).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// Create stores is_active as given; GORM would turn a false one into the
+// column default.
+func (r *gormUsers) Create(user *models.User) error {
+	isActive := user.IsActive
+	if err := r.db.Create(user).Error; err != nil {
+		return translate(err)
+	}
+	if isActive {
+		return nil
+	}
+
+	user.IsActive = false
+	return r.db.Model(user).UpdateColumn("is_active", false).Error
+}
+
+func (r *gormUsers) Update(user *models.User) error {
+	return updated(r.db.Model(user).Select("*").Omit("created_at").Updates(user))
+}
+
+func (r *gormUsers) SetLastDigestAt(userID string, at time.Time) error {
+	return updated(r.db.Model(&models.User{}).Where("user_id = ?", userID).Update("last_digest_at", at))
+}
+
+// activeMembers selects the users who are active members of any of
+// teamNames, both as users and as members of that team. A user in several
+// of the teams comes up once per team.
+func (r *gormUsers) activeMembers(teamNames []string) *gorm.DB {
+	return r.db.Model(&models.User{}).
+		Joins("JOIN team_memberships ON team_memberships.user_id = users.user_id").
+		Where("team_memberships.team_name IN ? AND team_memberships.is_active = ? AND users.is_active = ?", teamNames, true, true).
+		Order("users.user_id")
+}
+
+func (r *gormUsers) ActiveMembers(teamNames []string) ([]models.User, error) {
+	var users []models.User
+	if err := r.activeMembers(teamNames).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return uniqueUsers(users), nil
+}
+
+func (r *gormUsers) Candidates(teamNames []string, excludedUserIDs []string, now time.Time) ([]models.User, error) {
+	query := r.activeMembers(teamNames).
+		Where("team_memberships.role <> ?", models.MembershipRoleObserver).
+		Where("users.ooo_until IS NULL OR users.ooo_until <= ? OR users.ooo_from > ?", now, now).
+		Where("users.capacity = 0 OR users.capacity > (?)", r.openReviewCount())
+	if len(excludedUserIDs) > 0 {
+		query = query.Where("users.user_id NOT IN ?", excludedUserIDs)
+	}
+
+	var users []models.User
+	if err := query.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return uniqueUsers(users), nil
+}
+
+// openReviewCount counts the open pull requests users.user_id is assigned to
+// review, for use as a subquery.
+func (r *gormUsers) openReviewCount() *gorm.DB {
+	return r.db.Session(&gorm.Session{NewDB: true}).Model(&models.ReviewAssignment{}).
+		Select("COUNT(*)").
+		Joins("JOIN pull_requests ON pull_requests.pull_request_id = review_assignments.pull_request_id").
+		Where("review_assignments.reviewer_id = users.user_id AND pull_requests.status = ?", "OPEN")
+}
+
+// uniqueUsers drops repeated users from a list sorted by user id.
+func uniqueUsers(users []models.User) []models.User {
+	unique := []models.User{}
+	for _, user := range users {
+		if len(unique) == 0 || unique[len(unique)-1].UserID != user.UserID {
+			unique = append(unique, user)
+		}
+	}
+	return unique
+}
+
+type gormTeams struct {
+	db *gorm.DB
+}
+
+func (r *gormTeams) Get(teamName string) (*models.TeamDB, error) {
+	var team models.TeamDB
+	if err := r.db.Where("team_name = ?", teamName).First(&team).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &team, nil
+}
+
+func (r *gormTeams) query(query TeamQuery) *gorm.DB {
+	db := r.db.Model(&models.TeamDB{})
+	if query.Search != "" {
+		db = db.Where(`LOWER(team_name) LIKE LOWER(?) ESCAPE '\'`, pagination.PrefixPattern(query.Search))
+	}
+	if query.ParentTeamName != "" {
+		db = db.Where("parent_team_name = ?", query.ParentTeamName)
+	}
+	return db
+}
+
+func (r *gormTeams) List(query TeamQuery) ([]models.TeamDB, error) {
+	teams := []models.TeamDB{}
+	if err := pageQuery(r.query(query), query.Page, "team_dbs", "team_name").Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+func (r *gormTeams) Count(query TeamQuery) (int, error) {
+	var count int64
+	if err := r.query(query).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (r *gormTeams) Create(team *models.TeamDB) error {
+	return translate(r.db.Create(team).Error)
+}
+
+func (r *gormTeams) Update(team *models.TeamDB) error {
+	return updated(r.db.Model(team).Select("*").Omit("created_at").Updates(team))
+}
+
+func (r *gormTeams) Delete(teamName string) error {
+	if err := r.db.Where("team_name = ?", teamName).Delete(&models.TeamMembership{}).Error; err != nil {
+		return err
+	}
+	return updated(r.db.Where("team_name = ?", teamName).Delete(&models.TeamDB{}))
+}
+
+func (r *gormTeams) MoveSubTeams(teamName string, parent string) error {
+	return r.db.Model(&models.TeamDB{}).Where("parent_team_name = ?", teamName).Update("parent_team_name", parent).Error
+}
+
+func (r *gormTeams) Memberships(query MembershipQuery) ([]models.TeamMembership, error) {
+	db := r.db.Model(&models.TeamMembership{})
+	if query.TeamNames != nil {
+		db = db.Where("team_name IN ?", query.TeamNames)
+	}
+	if query.UserIDs != nil {
+		db = db.Where("user_id IN ?", query.UserIDs)
+	}
+
+	memberships := []models.TeamMembership{}
+	if err := db.Order("created_at, team_name, user_id").Find(&memberships).Error; err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+func (r *gormTeams) AddMembership(teamName string, userID string, role string, isActive bool) error {
+	columns := []string{"is_active"}
+	if role == "" {
+		role = models.MembershipRoleMember
+	} else {
+		columns = append(columns, "role")
+	}
+
+	membership := models.TeamMembership{
+		TeamName: teamName,
+		UserID:   userID,
+		Role:     role,
+		IsActive: isActive,
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "team_name"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&membership).Error
+}
+
+func (r *gormTeams) RemoveMembership(teamName string, userID string) error {
+	return r.db.Where("team_name = ? AND user_id = ?", teamName, userID).Delete(&models.TeamMembership{}).Error
+}
+
+type gormPullRequests struct {
+	db *gorm.DB
+}
+
+func (r *gormPullRequests) Get(prID string) (*models.PullRequest, error) {
+	var pr models.PullRequest
+	if err := r.db.Where("pull_request_id = ?", prID).First(&pr).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &pr, nil
+}
+
+func (r *gormPullRequests) Lock(prID string) (*models.PullRequest, error) {
+	var pr models.PullRequest
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("pull_request_id = ?", prID).First(&pr).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &pr, nil
+}
+
+func (r *gormPullRequests) List(query PullRequestQuery) ([]models.PullRequest, error) {
+	db := r.db.Model(&models.PullRequest{})
+	if query.IDs != nil {
+		db = db.Where("pull_request_id IN ?", query.IDs)
+	}
+	if query.TeamNames != nil {
+		db = db.Where("team_name IN ?", query.TeamNames)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.AuthorID != "" {
+		db = db.Where("author_id = ?", query.AuthorID)
+	}
+	if query.ReviewerID != "" {
+		db = db.Where("pull_request_id IN (?)", r.db.Model(&models.ReviewAssignment{}).Select("pull_request_id").Where("reviewer_id = ?", query.ReviewerID))
+	}
+
+	page := query.Page
+	page.Sort.Field = "created_at"
+	prs := []models.PullRequest{}
+	if err := pageQuery(db, page, "pull_requests", "pull_request_id").Find(&prs).Error; err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+func (r *gormPullRequests) Create(pr *models.PullRequest) error {
+	return translate(r.db.Omit(clause.Associations).Create(pr).Error)
+}
+
+func (r *gormPullRequests) Update(pr *models.PullRequest) error {
+	version := pr.Version
+	pr.Version++
+	err := updated(r.db.Model(pr).Where("version = ?", version).Select("*").Omit("created_at", clause.Associations).Updates(pr))
+	if err == nil {
+		return nil
+	}
+	pr.Version = version
+
+	if errors.Is(err, ErrNotFound) {
+		var count int64
+		if err := r.db.Model(&models.PullRequest{}).Where("pull_request_id = ?", pr.PullRequestID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrConflict
+		}
+	}
+	return err
+}
+
+func (r *gormPullRequests) Delete(prID string) error {
+	if err := r.db.Where("pull_request_id = ?", prID).Delete(&models.ReviewAssignment{}).Error; err != nil {
+		return err
+	}
+	if err := r.db.Where("pull_request_id = ?", prID).Delete(&models.ReviewDecline{}).Error; err != nil {
+		return err
+	}
+	return updated(r.db.Where("pull_request_id = ?", prID).Delete(&models.PullRequest{}))
+}
+
+func (r *gormPullRequests) Assignments(prIDs []string) ([]models.ReviewAssignment, error) {
+	db := r.db.Model(&models.ReviewAssignment{})
+	if prIDs != nil {
+		db = db.Where("pull_request_id IN ?", prIDs)
+	}
+
+	assignments := []models.ReviewAssignment{}
+	if err := db.Order("pull_request_id, reviewer_id").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+	return assignments, nil
+}
+
+func (r *gormPullRequests) SaveAssignment(assignment *models.ReviewAssignment) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(assignment).Error
+}
+
+func (r *gormPullRequests) DeleteAssignment(prID string, reviewerID string) error {
+	return r.db.Where("pull_request_id = ? AND reviewer_id = ?", prID, reviewerID).Delete(&models.ReviewAssignment{}).Error
+}
+
+func (r *gormPullRequests) Declines(query DeclineQuery) ([]models.ReviewDecline, error) {
+	db := r.db.Model(&models.ReviewDecline{})
+	if query.PullRequestIDs != nil {
+		db = db.Where("pull_request_id IN ?", query.PullRequestIDs)
+	}
+	if query.TeamNames != nil {
+		db = db.Where("pull_request_id IN (?)", r.db.Model(&models.PullRequest{}).Select("pull_request_id").Where("team_name IN ?", query.TeamNames))
+	}
+
+	declines := []models.ReviewDecline{}
+	if err := db.Order("created_at, pull_request_id, reviewer_id").Find(&declines).Error; err != nil {
+		return nil, err
+	}
+	return declines, nil
+}
+
+func (r *gormPullRequests) CreateDecline(decline *models.ReviewDecline) error {
+	return translate(r.db.Create(decline).Error)
+}
+
+type gormIdempotencyKeys struct {
+	db *gorm.DB
+}
+
+func (r *gormIdempotencyKeys) Get(key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	if err := r.db.Where("idempotency_key = ?", key).First(&record).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &record, nil
+}
+
+func (r *gormIdempotencyKeys) Create(record *models.IdempotencyKey) error {
+	return translate(r.db.Create(record).Error)
+}
+
+func (r *gormIdempotencyKeys) Update(record *models.IdempotencyKey) error {
+	return updated(r.db.Model(record).Select("*").Omit("created_at").Updates(record))
+}
+
+func (r *gormIdempotencyKeys) Delete(key string) error {
+	return updated(r.db.Where("idempotency_key = ?", key).Delete(&models.IdempotencyKey{}))
+}
+
+func (r *gormIdempotencyKeys) DeleteExpired(now time.Time) (int, error) {
+	result := r.db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{})
+	return int(result.RowsAffected), result.Error
+}
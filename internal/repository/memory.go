@@ -0,0 +1,798 @@
+package repository
+
+import (
+	"gorm.io/datatypes"
+	"prReviewerAssignment/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryData is everything a memory store holds. Records are kept by value
+// and copied on the way in and out, so callers never share them.
+type memoryData struct {
+	users        map[string]models.User
+	teams        map[string]models.TeamDB
+	memberships  map[string]models.TeamMembership
+	pullRequests map[string]models.PullRequest
+	assignments  map[string]models.ReviewAssignment
+	declines     map[string]models.ReviewDecline
+	idempotency  map[string]models.IdempotencyKey
+}
+
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		users:        cloneMap(d.users),
+		teams:        cloneMap(d.teams),
+		memberships:  cloneMap(d.memberships),
+		pullRequests: cloneMap(d.pullRequests),
+		assignments:  cloneMap(d.assignments),
+		declines:     cloneMap(d.declines),
+		idempotency:  cloneMap(d.idempotency),
+	}
+}
+
+func cloneMap[T any](records map[string]T) map[string]T {
+	clone := make(map[string]T, len(records))
+	for key, record := range records {
+		clone[key] = record
+	}
+	return clone
+}
+
+func pairKey(a string, b string) string {
+	return a + "\x00" + b
+}
+
+type memoryStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTx is set on the stores passed to Transaction, which already hold mu.
+	inTx bool
+}
+
+// NewMemoryStore keeps the data in memory only; it is lost when the process
+// exits. Transactions are serialized: one holds the store until it ends.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			users:        make(map[string]models.User),
+			teams:        make(map[string]models.TeamDB),
+			memberships:  make(map[string]models.TeamMembership),
+			pullRequests: make(map[string]models.PullRequest),
+			assignments:  make(map[string]models.ReviewAssignment),
+			declines:     make(map[string]models.ReviewDecline),
+			idempotency:  make(map[string]models.IdempotencyKey),
+		},
+	}
+}
+
+func (s *memoryStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *memoryStore) Users() UserRepository {
+	return &memoryUsers{store: s}
+}
+
+func (s *memoryStore) Teams() TeamRepository {
+	return &memoryTeams{store: s}
+}
+
+func (s *memoryStore) PullRequests() PullRequestRepository {
+	return &memoryPullRequests{store: s}
+}
+
+func (s *memoryStore) IdempotencyKeys() IdempotencyKeyRepository {
+	return &memoryIdempotencyKeys{store: s}
+}
+
+func (s *memoryStore) Transaction(fn func(tx Store) error) (err error) {
+	defer s.lock()()
+
+	snapshot := s.data.clone()
+	defer func() {
+		if r := recover(); r != nil {
+			*s.data = *snapshot
+			panic(r)
+		}
+		if err != nil {
+			*s.data = *snapshot
+		}
+	}()
+
+	return fn(&memoryStore{mu: s.mu, data: s.data, inTx: true})
+}
+
+// now drops the monotonic clock reading so stored times compare like the
+// ones read back from a database.
+func now() time.Time {
+	return time.Now().Round(0)
+}
+
+// inFilter reports whether value passes a slice filter: nil lets everything
+// through, an empty slice nothing.
+func inFilter(values []string, value string) bool {
+	if values == nil {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrefixFold(value string, prefix string) bool {
+	return strings.HasPrefix(strings.ToLower(value), strings.ToLower(prefix))
+}
+
+// compareValues orders sort field values, which are strings or times.
+func compareValues(a interface{}, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		b, _ := b.(time.Time)
+		return a.Compare(b)
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	}
+	return 0
+}
+
+// applyPage sorts records and cuts out the page the way pagination.Page
+// does in SQL. key returns the value of a sort field of a record and the
+// record's id, whose field is idField.
+func applyPage[T any](records []T, page Page, idField string, key func(record T, field string) (interface{}, string)) []T {
+	byID := page.Sort.Field == "" || page.Sort.Field == idField
+	compare := func(a T, b T) int {
+		valueA, idA := key(a, page.Sort.Field)
+		valueB, idB := key(b, page.Sort.Field)
+		result := compareValues(valueA, valueB)
+		if result == 0 {
+			result = strings.Compare(idA, idB)
+		}
+		if page.Sort.Desc {
+			result = -result
+		}
+		return result
+	}
+	sort.SliceStable(records, func(i, j int) bool { return compare(records[i], records[j]) < 0 })
+
+	if page.Cursor != nil {
+		kept := records[:0]
+		for _, record := range records {
+			value, id := key(record, page.Sort.Field)
+			result := 0
+			if !byID {
+				result = compareValues(value, page.CursorValue)
+			}
+			if result == 0 {
+				result = strings.Compare(id, page.Cursor.ID)
+			}
+			if page.Sort.Desc {
+				result = -result
+			}
+			if result > 0 {
+				kept = append(kept, record)
+			}
+		}
+		records = kept
+	}
+
+	if page.Offset >= len(records) {
+		return records[:0]
+	}
+	records = records[page.Offset:]
+	if page.Limit > 0 && len(records) > page.Limit+1 {
+		records = records[:page.Limit+1]
+	}
+	return records
+}
+
+type memoryUsers struct {
+	store *memoryStore
+}
+
+func (r *memoryUsers) Get(userID string) (*models.User, error) {
+	defer r.store.lock()()
+
+	user, ok := r.store.data.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUsers) Find(userIDs []string) ([]models.User, error) {
+	defer r.store.lock()()
+
+	users := []models.User{}
+	for _, user := range r.store.data.users {
+		if len(userIDs) > 0 && inFilter(userIDs, user.UserID) {
+			users = append(users, user)
+		}
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+func sortUsers(users []models.User) {
+	sort.Slice(users, func(i, j int) bool { return users[i].UserID < users[j].UserID })
+}
+
+func (r *memoryUsers) matching(query UserQuery) []models.User {
+	users := []models.User{}
+	for _, user := range r.store.data.users {
+		if query.Search != "" && !hasPrefixFold(user.UserID, query.Search) && !hasPrefixFold(user.Username, query.Search) {
+			continue
+		}
+		if _, ok := r.store.data.memberships[pairKey(query.TeamName, user.UserID)]; query.TeamName != "" && !ok {
+			continue
+		}
+		if query.HomeTeam != "" && user.TeamName != query.HomeTeam {
+			continue
+		}
+		if query.IsActive != nil && user.IsActive != *query.IsActive {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+func (r *memoryUsers) List(query UserQuery) ([]models.User, error) {
+	defer r.store.lock()()
+
+	return applyPage(r.matching(query), query.Page, "user_id", func(user models.User, field string) (interface{}, string) {
+		switch field {
+		case "username":
+			return user.Username, user.UserID
+		case "created_at":
+			return user.CreatedAt, user.UserID
+		}
+		return user.UserID, user.UserID
+	}), nil
+}
+
+func (r *memoryUsers) Count(query UserQuery) (int, error) {
+	defer r.store.lock()()
+
+	return len(r.matching(query)), nil
+}
+
+func (r *memoryUsers) Create(user *models.User) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.users[user.UserID]; ok {
+		return ErrDuplicate
+	}
+	if user.Timezone == "" {
+		user.Timezone = defaultTimezone
+	}
+	if user.WorkStart == "" {
+		user.WorkStart = defaultWorkStart
+	}
+	if user.WorkEnd == "" {
+		user.WorkEnd = defaultWorkEnd
+	}
+	if user.CreatedAt.IsZero() {
+		user.CreatedAt = now()
+	}
+	user.UpdatedAt = user.CreatedAt
+	r.store.data.users[user.UserID] = *user
+	return nil
+}
+
+func (r *memoryUsers) Update(user *models.User) error {
+	defer r.store.lock()()
+
+	existing, ok := r.store.data.users[user.UserID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.CreatedAt = existing.CreatedAt
+	user.UpdatedAt = now()
+	r.store.data.users[user.UserID] = *user
+	return nil
+}
+
+func (r *memoryUsers) SetLastDigestAt(userID string, at time.Time) error {
+	defer r.store.lock()()
+
+	user, ok := r.store.data.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.LastDigestAt = &at
+	user.UpdatedAt = now()
+	r.store.data.users[userID] = user
+	return nil
+}
+
+func (r *memoryUsers) activeMembers(teamNames []string) []models.User {
+	users := []models.User{}
+	seen := make(map[string]bool)
+	for _, membership := range r.store.data.memberships {
+		user, ok := r.store.data.users[membership.UserID]
+		if !ok || seen[user.UserID] || !membership.IsActive || !user.IsActive || !inFilter(teamNames, membership.TeamName) {
+			continue
+		}
+		seen[user.UserID] = true
+		users = append(users, user)
+	}
+	sortUsers(users)
+	return users
+}
+
+func (r *memoryUsers) ActiveMembers(teamNames []string) ([]models.User, error) {
+	defer r.store.lock()()
+
+	if teamNames == nil {
+		teamNames = []string{}
+	}
+	return r.activeMembers(teamNames), nil
+}
+
+func (r *memoryUsers) Candidates(teamNames []string, excludedUserIDs []string, now time.Time) ([]models.User, error) {
+	defer r.store.lock()()
+
+	openReviews := make(map[string]int)
+	for _, assignment := range r.store.data.assignments {
+		if pr, ok := r.store.data.pullRequests[assignment.PullRequestID]; ok && pr.Status == "OPEN" {
+			openReviews[assignment.ReviewerID]++
+		}
+	}
+
+	// A user qualifies through any team membership that is not an observer
+	// one.
+	assignable := make(map[string]bool)
+	for _, membership := range r.store.data.memberships {
+		if membership.IsActive && membership.Role != models.MembershipRoleObserver && inFilter(teamNames, membership.TeamName) {
+			assignable[membership.UserID] = true
+		}
+	}
+
+	if teamNames == nil {
+		teamNames = []string{}
+	}
+	users := []models.User{}
+	for _, user := range r.activeMembers(teamNames) {
+		if !assignable[user.UserID] || (len(excludedUserIDs) > 0 && inFilter(excludedUserIDs, user.UserID)) {
+			continue
+		}
+		if user.OOOUntil != nil && user.OOOUntil.After(now) && (user.OOOFrom == nil || !user.OOOFrom.After(now)) {
+			continue
+		}
+		if user.Capacity != 0 && user.Capacity <= openReviews[user.UserID] {
+			continue
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+type memoryTeams struct {
+	store *memoryStore
+}
+
+func (r *memoryTeams) Get(teamName string) (*models.TeamDB, error) {
+	defer r.store.lock()()
+
+	team, ok := r.store.data.teams[teamName]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &team, nil
+}
+
+func (r *memoryTeams) matching(query TeamQuery) []models.TeamDB {
+	teams := []models.TeamDB{}
+	for _, team := range r.store.data.teams {
+		if query.Search != "" && !hasPrefixFold(team.TeamName, query.Search) {
+			continue
+		}
+		if query.ParentTeamName != "" && team.ParentTeamName != query.ParentTeamName {
+			continue
+		}
+		teams = append(teams, team)
+	}
+	return teams
+}
+
+func (r *memoryTeams) List(query TeamQuery) ([]models.TeamDB, error) {
+	defer r.store.lock()()
+
+	return applyPage(r.matching(query), query.Page, "team_name", func(team models.TeamDB, field string) (interface{}, string) {
+		if field == "created_at" {
+			return team.CreatedAt, team.TeamName
+		}
+		return team.TeamName, team.TeamName
+	}), nil
+}
+
+func (r *memoryTeams) Count(query TeamQuery) (int, error) {
+	defer r.store.lock()()
+
+	return len(r.matching(query)), nil
+}
+
+func (r *memoryTeams) Create(team *models.TeamDB) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.teams[team.TeamName]; ok {
+		return ErrDuplicate
+	}
+	if team.MaxReviewers == 0 {
+		team.MaxReviewers = defaultMaxReviewers
+	}
+	if team.SLAPolicy == "" {
+		team.SLAPolicy = defaultSLAPolicy
+	}
+	if team.Strategy == "" {
+		team.Strategy = defaultStrategy
+	}
+	if team.CreatedAt.IsZero() {
+		team.CreatedAt = now()
+	}
+	r.store.data.teams[team.TeamName] = *team
+	return nil
+}
+
+func (r *memoryTeams) Update(team *models.TeamDB) error {
+	defer r.store.lock()()
+
+	existing, ok := r.store.data.teams[team.TeamName]
+	if !ok {
+		return ErrNotFound
+	}
+	team.CreatedAt = existing.CreatedAt
+	r.store.data.teams[team.TeamName] = *team
+	return nil
+}
+
+func (r *memoryTeams) Delete(teamName string) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.teams[teamName]; !ok {
+		return ErrNotFound
+	}
+	for key, membership := range r.store.data.memberships {
+		if membership.TeamName == teamName {
+			delete(r.store.data.memberships, key)
+		}
+	}
+	delete(r.store.data.teams, teamName)
+	return nil
+}
+
+func (r *memoryTeams) MoveSubTeams(teamName string, parent string) error {
+	defer r.store.lock()()
+
+	for name, team := range r.store.data.teams {
+		if team.ParentTeamName == teamName {
+			team.ParentTeamName = parent
+			r.store.data.teams[name] = team
+		}
+	}
+	return nil
+}
+
+func (r *memoryTeams) Memberships(query MembershipQuery) ([]models.TeamMembership, error) {
+	defer r.store.lock()()
+
+	memberships := []models.TeamMembership{}
+	for _, membership := range r.store.data.memberships {
+		if inFilter(query.TeamNames, membership.TeamName) && inFilter(query.UserIDs, membership.UserID) {
+			memberships = append(memberships, membership)
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		a, b := memberships[i], memberships[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.TeamName != b.TeamName {
+			return a.TeamName < b.TeamName
+		}
+		return a.UserID < b.UserID
+	})
+	return memberships, nil
+}
+
+func (r *memoryTeams) AddMembership(teamName string, userID string, role string, isActive bool) error {
+	defer r.store.lock()()
+
+	key := pairKey(teamName, userID)
+	membership, ok := r.store.data.memberships[key]
+	if !ok {
+		membership = models.TeamMembership{
+			TeamName:  teamName,
+			UserID:    userID,
+			Role:      models.MembershipRoleMember,
+			CreatedAt: now(),
+		}
+	}
+	if role != "" {
+		membership.Role = role
+	}
+	membership.IsActive = isActive
+	r.store.data.memberships[key] = membership
+	return nil
+}
+
+func (r *memoryTeams) RemoveMembership(teamName string, userID string) error {
+	defer r.store.lock()()
+
+	delete(r.store.data.memberships, pairKey(teamName, userID))
+	return nil
+}
+
+type memoryPullRequests struct {
+	store *memoryStore
+}
+
+func copyPR(pr models.PullRequest) models.PullRequest {
+	if pr.AssignedReviewers != nil {
+		pr.AssignedReviewers = append(datatypes.JSON{}, pr.AssignedReviewers...)
+	}
+	pr.Author = models.User{}
+	return pr
+}
+
+func (r *memoryPullRequests) Get(prID string) (*models.PullRequest, error) {
+	defer r.store.lock()()
+
+	pr, ok := r.store.data.pullRequests[prID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	pr = copyPR(pr)
+	return &pr, nil
+}
+
+// Lock is Get: transactions are serialized already.
+func (r *memoryPullRequests) Lock(prID string) (*models.PullRequest, error) {
+	return r.Get(prID)
+}
+
+func (r *memoryPullRequests) List(query PullRequestQuery) ([]models.PullRequest, error) {
+	defer r.store.lock()()
+
+	reviewed := make(map[string]bool)
+	for _, assignment := range r.store.data.assignments {
+		if assignment.ReviewerID == query.ReviewerID {
+			reviewed[assignment.PullRequestID] = true
+		}
+	}
+
+	prs := []models.PullRequest{}
+	for _, pr := range r.store.data.pullRequests {
+		if !inFilter(query.IDs, pr.PullRequestID) || !inFilter(query.TeamNames, pr.TeamName) {
+			continue
+		}
+		if query.Status != "" && pr.Status != query.Status {
+			continue
+		}
+		if query.AuthorID != "" && pr.AuthorID != query.AuthorID {
+			continue
+		}
+		if query.ReviewerID != "" && !reviewed[pr.PullRequestID] {
+			continue
+		}
+		prs = append(prs, copyPR(pr))
+	}
+
+	page := query.Page
+	page.Sort.Field = "created_at"
+	return applyPage(prs, page, "pull_request_id", func(pr models.PullRequest, field string) (interface{}, string) {
+		return pr.CreatedAt, pr.PullRequestID
+	}), nil
+}
+
+func (r *memoryPullRequests) Create(pr *models.PullRequest) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.pullRequests[pr.PullRequestID]; ok {
+		return ErrDuplicate
+	}
+	if pr.Status == "" {
+		pr.Status = defaultStatus
+	}
+	if pr.CreatedAt.IsZero() {
+		pr.CreatedAt = now()
+	}
+	if pr.Version == 0 {
+		pr.Version = 1
+	}
+	r.store.data.pullRequests[pr.PullRequestID] = copyPR(*pr)
+	return nil
+}
+
+func (r *memoryPullRequests) Update(pr *models.PullRequest) error {
+	defer r.store.lock()()
+
+	existing, ok := r.store.data.pullRequests[pr.PullRequestID]
+	if !ok {
+		return ErrNotFound
+	}
+	if existing.Version != pr.Version {
+		return ErrConflict
+	}
+	pr.CreatedAt = existing.CreatedAt
+	pr.Version++
+	r.store.data.pullRequests[pr.PullRequestID] = copyPR(*pr)
+	return nil
+}
+
+func (r *memoryPullRequests) Delete(prID string) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.pullRequests[prID]; !ok {
+		return ErrNotFound
+	}
+	for key, assignment := range r.store.data.assignments {
+		if assignment.PullRequestID == prID {
+			delete(r.store.data.assignments, key)
+		}
+	}
+	for key, decline := range r.store.data.declines {
+		if decline.PullRequestID == prID {
+			delete(r.store.data.declines, key)
+		}
+	}
+	delete(r.store.data.pullRequests, prID)
+	return nil
+}
+
+func (r *memoryPullRequests) Assignments(prIDs []string) ([]models.ReviewAssignment, error) {
+	defer r.store.lock()()
+
+	assignments := []models.ReviewAssignment{}
+	for _, assignment := range r.store.data.assignments {
+		if inFilter(prIDs, assignment.PullRequestID) {
+			assignments = append(assignments, assignment)
+		}
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].PullRequestID != assignments[j].PullRequestID {
+			return assignments[i].PullRequestID < assignments[j].PullRequestID
+		}
+		return assignments[i].ReviewerID < assignments[j].ReviewerID
+	})
+	return assignments, nil
+}
+
+func (r *memoryPullRequests) SaveAssignment(assignment *models.ReviewAssignment) error {
+	defer r.store.lock()()
+
+	r.store.data.assignments[pairKey(assignment.PullRequestID, assignment.ReviewerID)] = *assignment
+	return nil
+}
+
+func (r *memoryPullRequests) DeleteAssignment(prID string, reviewerID string) error {
+	defer r.store.lock()()
+
+	delete(r.store.data.assignments, pairKey(prID, reviewerID))
+	return nil
+}
+
+func (r *memoryPullRequests) Declines(query DeclineQuery) ([]models.ReviewDecline, error) {
+	defer r.store.lock()()
+
+	declines := []models.ReviewDecline{}
+	for _, decline := range r.store.data.declines {
+		if !inFilter(query.PullRequestIDs, decline.PullRequestID) {
+			continue
+		}
+		if pr, ok := r.store.data.pullRequests[decline.PullRequestID]; query.TeamNames != nil && (!ok || !inFilter(query.TeamNames, pr.TeamName)) {
+			continue
+		}
+		declines = append(declines, decline)
+	}
+	sort.Slice(declines, func(i, j int) bool {
+		a, b := declines[i], declines[j]
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		if a.PullRequestID != b.PullRequestID {
+			return a.PullRequestID < b.PullRequestID
+		}
+		return a.ReviewerID < b.ReviewerID
+	})
+	return declines, nil
+}
+
+func (r *memoryPullRequests) CreateDecline(decline *models.ReviewDecline) error {
+	defer r.store.lock()()
+
+	key := pairKey(decline.PullRequestID, decline.ReviewerID)
+	if _, ok := r.store.data.declines[key]; ok {
+		return ErrDuplicate
+	}
+	if decline.CreatedAt.IsZero() {
+		decline.CreatedAt = now()
+	}
+	r.store.data.declines[key] = *decline
+	return nil
+}
+
+type memoryIdempotencyKeys struct {
+	store *memoryStore
+}
+
+func copyIdempotencyKey(record models.IdempotencyKey) models.IdempotencyKey {
+	if record.ResponseHeaders != nil {
+		record.ResponseHeaders = append(datatypes.JSON{}, record.ResponseHeaders...)
+	}
+	if record.ResponseBody != nil {
+		record.ResponseBody = append([]byte{}, record.ResponseBody...)
+	}
+	return record
+}
+
+func (r *memoryIdempotencyKeys) Get(key string) (*models.IdempotencyKey, error) {
+	defer r.store.lock()()
+
+	record, ok := r.store.data.idempotency[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	record = copyIdempotencyKey(record)
+	return &record, nil
+}
+
+func (r *memoryIdempotencyKeys) Create(record *models.IdempotencyKey) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.idempotency[record.Key]; ok {
+		return ErrDuplicate
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = now()
+	}
+	r.store.data.idempotency[record.Key] = copyIdempotencyKey(*record)
+	return nil
+}
+
+func (r *memoryIdempotencyKeys) Update(record *models.IdempotencyKey) error {
+	defer r.store.lock()()
+
+	existing, ok := r.store.data.idempotency[record.Key]
+	if !ok {
+		return ErrNotFound
+	}
+	record.CreatedAt = existing.CreatedAt
+	r.store.data.idempotency[record.Key] = copyIdempotencyKey(*record)
+	return nil
+}
+
+func (r *memoryIdempotencyKeys) Delete(key string) error {
+	defer r.store.lock()()
+
+	if _, ok := r.store.data.idempotency[key]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.data.idempotency, key)
+	return nil
+}
+
+func (r *memoryIdempotencyKeys) DeleteExpired(now time.Time) (int, error) {
+	defer r.store.lock()()
+
+	deleted := 0
+	for key, record := range r.store.data.idempotency {
+		if record.ExpiresAt.Before(now) {
+			delete(r.store.data.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"errors"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/pagination"
+	"time"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("record already exists")
+	ErrConflict  = errors.New("record was changed concurrently")
+)
+
+// Store gives access to the repositories of one backend. The stores passed
+// to Transaction see the changes made inside it, which are committed when
+// fn returns nil and discarded otherwise.
+type Store interface {
+	Users() UserRepository
+	Teams() TeamRepository
+	PullRequests() PullRequestRepository
+	IdempotencyKeys() IdempotencyKeyRepository
+	Transaction(fn func(tx Store) error) error
+}
+
+// Page selects a slice of an ordered result. Records are sorted by Sort and
+// then by their id, which is also the default sort. With Cursor set the page
+// starts after it; CursorValue is the cursor value converted to the type of
+// the sort field. Offset skips further records. With Limit set at most
+// Limit+1 records are returned, the extra one telling the caller that there
+// is a next page.
+type Page struct {
+	Sort        pagination.Sort
+	Cursor      *pagination.Cursor
+	CursorValue interface{}
+	Offset      int
+	Limit       int
+}
+
+// UserQuery filters users; zero fields do not filter. Users can be sorted by
+// user_id, username and created_at.
+type UserQuery struct {
+	// Search matches a case-insensitive prefix of the user id or username.
+	Search string
+	// TeamName keeps members of the team, HomeTeam users whose home team it
+	// is.
+	TeamName string
+	HomeTeam string
+	IsActive *bool
+	Page     Page
+}
+
+// TeamQuery filters teams; zero fields do not filter. Teams can be sorted by
+// team_name and created_at.
+type TeamQuery struct {
+	// Search matches a case-insensitive prefix of the team name.
+	Search         string
+	ParentTeamName string
+	Page           Page
+}
+
+// MembershipQuery filters memberships. A nil slice does not filter, an empty
+// one matches nothing.
+type MembershipQuery struct {
+	TeamNames []string
+	UserIDs   []string
+}
+
+// PullRequestQuery filters pull requests. A nil slice or an empty string does
+// not filter, an empty slice matches nothing. Pull requests are sorted by
+// created_at, oldest first, and paged in that order.
+type PullRequestQuery struct {
+	IDs       []string
+	TeamNames []string
+	Status    string
+	AuthorID  string
+	// ReviewerID keeps the pull requests the user is assigned to review.
+	ReviewerID string
+	Page       Page
+}
+
+// DeclineQuery filters review declines by pull request or by the team of the
+// pull request. A nil slice does not filter, an empty one matches nothing.
+type DeclineQuery struct {
+	PullRequestIDs []string
+	TeamNames      []string
+}
+
+type UserRepository interface {
+	Get(userID string) (*models.User, error)
+	// Find returns the existing users among userIDs, sorted by user id.
+	Find(userIDs []string) ([]models.User, error)
+	List(query UserQuery) ([]models.User, error)
+	Count(query UserQuery) (int, error)
+	// Create fills in the column defaults of zero profile fields; IsActive
+	// is stored as given.
+	Create(user *models.User) error
+	// Update writes every field of an existing user but CreatedAt.
+	Update(user *models.User) error
+	// SetLastDigestAt writes LastDigestAt alone, leaving changes made to the
+	// rest of the user since it was read in place.
+	SetLastDigestAt(userID string, at time.Time) error
+	// ActiveMembers returns the users who are active members of any of
+	// teamNames, both as users and as members of that team, sorted by user
+	// id.
+	ActiveMembers(teamNames []string) ([]models.User, error)
+	// Candidates narrows ActiveMembers down to the users that may be
+	// assigned automatically: everyone but observers, excludedUserIDs, users
+	// out of office at now and users whose open reviews already fill their
+	// capacity.
+	Candidates(teamNames []string, excludedUserIDs []string, now time.Time) ([]models.User, error)
+}
+
+type TeamRepository interface {
+	Get(teamName string) (*models.TeamDB, error)
+	List(query TeamQuery) ([]models.TeamDB, error)
+	Count(query TeamQuery) (int, error)
+	// Create fills in the column defaults of zero MaxReviewers, SLAPolicy
+	// and Strategy.
+	Create(team *models.TeamDB) error
+	// Update writes every field of an existing team but CreatedAt.
+	Update(team *models.TeamDB) error
+	// Delete removes the team together with its memberships.
+	Delete(teamName string) error
+	// MoveSubTeams makes the sub-teams of teamName sub-teams of parent.
+	MoveSubTeams(teamName string, parent string) error
+
+	// Memberships are sorted by creation time, then by team name and user
+	// id.
+	Memberships(query MembershipQuery) ([]models.TeamMembership, error)
+	// AddMembership makes userID a member of teamName or updates an existing
+	// membership. An empty role keeps the role of an existing membership and
+	// makes new members plain MEMBERs.
+	AddMembership(teamName string, userID string, role string, isActive bool) error
+	RemoveMembership(teamName string, userID string) error
+}
+
+type PullRequestRepository interface {
+	Get(prID string) (*models.PullRequest, error)
+	// Lock is Get that also keeps other transactions from changing the pull
+	// request until the current one ends.
+	Lock(prID string) (*models.PullRequest, error)
+	// List sorts pull requests by creation time and id.
+	List(query PullRequestQuery) ([]models.PullRequest, error)
+	// Create makes an empty Status OPEN and starts Version at 1.
+	Create(pr *models.PullRequest) error
+	// Update writes every field of an existing pull request but CreatedAt
+	// and increments Version. It fails with ErrConflict unless the stored
+	// Version is still the one in pr.
+	Update(pr *models.PullRequest) error
+	// Delete removes the pull request together with its review assignments
+	// and declines.
+	Delete(prID string) error
+
+	Assignments(prIDs []string) ([]models.ReviewAssignment, error)
+	// SaveAssignment creates the assignment or overwrites an existing one.
+	SaveAssignment(assignment *models.ReviewAssignment) error
+	DeleteAssignment(prID string, reviewerID string) error
+
+	Declines(query DeclineQuery) ([]models.ReviewDecline, error)
+	CreateDecline(decline *models.ReviewDecline) error
+}
+
+type IdempotencyKeyRepository interface {
+	Get(key string) (*models.IdempotencyKey, error)
+	// Create fails with ErrDuplicate when the key is taken, expired or not.
+	Create(record *models.IdempotencyKey) error
+	// Update writes every field of an existing key but CreatedAt.
+	Update(record *models.IdempotencyKey) error
+	Delete(key string) error
+	// DeleteExpired removes the keys that expired before now and returns
+	// how many there were.
+	DeleteExpired(now time.Time) (int, error)
+}
@@ -0,0 +1,602 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/db/migrations"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/pagination"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testStore runs the behaviour every Store has to share against stores made
+// by newStore, each test getting an empty one.
+func testStore(t *testing.T, newStore func(t *testing.T) Store) {
+	tests := map[string]func(t *testing.T, store Store){
+		"Users":             testUsers,
+		"ListUsers":         testListUsers,
+		"Teams":             testTeams,
+		"Memberships":       testMemberships,
+		"Candidates":        testCandidates,
+		"PullRequests":      testPullRequests,
+		"Declines":          testDeclines,
+		"Transaction":       testTransaction,
+		"NestedTransaction": testNestedTransaction,
+		"ConcurrentUpdates": testConcurrentUpdates,
+		"IdempotencyKeys":   testIdempotencyKeys,
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test(t, newStore(t))
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store { return NewMemoryStore() })
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, func(t *testing.T) Store {
+		return newGormStore(t, sqlite.Open(":memory:?_pragma=foreign_keys(1)"))
+	})
+}
+
+// TestPostgresStore runs against the database TEST_POSTGRES_DSN points to,
+// dropping its tables before every test.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	testStore(t, func(t *testing.T) Store {
+		return newGormStore(t, postgres.Open(dsn))
+	})
+}
+
+func newGormStore(t *testing.T, dialector gorm.Dialector) Store {
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent), TranslateError: true})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: opens a database of its own.
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.Migrator().DropTable("idempotency_keys", "review_assignments", "review_declines", "pull_requests", "team_memberships", "users", "team_dbs", "schema_migrations"))
+	_, err = migrations.Up(db)
+	require.NoError(t, err)
+	return NewGormStore(db)
+}
+
+func createUsers(t *testing.T, store Store, users ...models.User) {
+	for _, user := range users {
+		require.NoError(t, store.Users().Create(&user))
+	}
+}
+
+func createTeams(t *testing.T, store Store, teamNames ...string) {
+	for _, teamName := range teamNames {
+		require.NoError(t, store.Teams().Create(&models.TeamDB{TeamName: teamName}))
+	}
+}
+
+func userIDs(users []models.User) []string {
+	ids := []string{}
+	for _, user := range users {
+		ids = append(ids, user.UserID)
+	}
+	return ids
+}
+
+func reviewersJSON(reviewers ...string) []byte {
+	data, _ := json.Marshal(reviewers)
+	return data
+}
+
+func testUsers(t *testing.T, store Store) {
+	users := store.Users()
+
+	user := models.User{UserID: "u1", Username: "Alice", TeamName: "backend"}
+	require.NoError(t, users.Create(&user))
+	assert.Equal(t, "UTC", user.Timezone)
+	assert.ErrorIs(t, users.Create(&models.User{UserID: "u1", Username: "Again"}), ErrDuplicate)
+
+	got, err := users.Get("u1")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", got.Username)
+	assert.False(t, got.IsActive)
+	assert.Equal(t, "09:00", got.WorkStart)
+	assert.Equal(t, "18:00", got.WorkEnd)
+	assert.False(t, got.CreatedAt.IsZero())
+
+	_, err = users.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	got.IsActive = true
+	got.Email = "alice@example.org"
+	require.NoError(t, users.Update(got))
+	got.IsActive = false
+	got.Email = ""
+	require.NoError(t, users.Update(got))
+	got, err = users.Get("u1")
+	require.NoError(t, err)
+	assert.False(t, got.IsActive)
+	assert.Empty(t, got.Email)
+
+	assert.ErrorIs(t, users.Update(&models.User{UserID: "missing"}), ErrNotFound)
+
+	digestAt := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	stale := *got
+	got.IsActive = true
+	require.NoError(t, users.Update(got))
+	require.NoError(t, users.SetLastDigestAt(stale.UserID, digestAt))
+	got, err = users.Get("u1")
+	require.NoError(t, err)
+	assert.True(t, got.IsActive)
+	if assert.NotNil(t, got.LastDigestAt) {
+		assert.True(t, got.LastDigestAt.Equal(digestAt))
+	}
+	assert.ErrorIs(t, users.SetLastDigestAt("missing", digestAt), ErrNotFound)
+
+	createUsers(t, store, models.User{UserID: "u0", Username: "Zed"})
+	found, err := users.Find([]string{"u1", "u0", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"u0", "u1"}, userIDs(found))
+	found, err = users.Find(nil)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func testListUsers(t *testing.T, store Store) {
+	createUsers(t, store,
+		models.User{UserID: "u1", Username: "Carol", TeamName: "backend", IsActive: true},
+		models.User{UserID: "u2", Username: "Alice", TeamName: "backend", IsActive: true},
+		models.User{UserID: "u3", Username: "Bob", TeamName: "frontend"},
+		models.User{UserID: "al_1", Username: "Dave", TeamName: "frontend", IsActive: true},
+	)
+	createTeams(t, store, "frontend")
+	require.NoError(t, store.Teams().AddMembership("frontend", "u1", "", true))
+	require.NoError(t, store.Teams().AddMembership("frontend", "u3", "", true))
+
+	list := func(query UserQuery) []string {
+		users, err := store.Users().List(query)
+		require.NoError(t, err)
+		return userIDs(users)
+	}
+	count := func(query UserQuery) int {
+		count, err := store.Users().Count(query)
+		require.NoError(t, err)
+		return count
+	}
+
+	assert.Equal(t, []string{"al_1", "u1", "u2", "u3"}, list(UserQuery{}))
+	assert.Equal(t, []string{"al_1", "u2"}, list(UserQuery{Search: "AL"}))
+	assert.Equal(t, []string{"al_1"}, list(UserQuery{Search: "al_"}))
+	assert.Equal(t, []string{"u1", "u3"}, list(UserQuery{TeamName: "frontend"}))
+	assert.Equal(t, []string{"al_1", "u3"}, list(UserQuery{HomeTeam: "frontend"}))
+	inactive := false
+	assert.Equal(t, []string{"u3"}, list(UserQuery{IsActive: &inactive}))
+	assert.Equal(t, 2, count(UserQuery{Search: "al"}))
+	assert.Equal(t, 4, count(UserQuery{}))
+
+	byName := pagination.Sort{Field: "username"}
+	assert.Equal(t, []string{"u2", "u3", "u1"}, list(UserQuery{Page: Page{Sort: byName, Limit: 2}}))
+	assert.Equal(t, []string{"al_1"}, list(UserQuery{Page: Page{
+		Sort:        byName,
+		Cursor:      &pagination.Cursor{Value: "Carol", ID: "u1"},
+		CursorValue: "Carol",
+		Limit:       2,
+	}}))
+	assert.Equal(t, []string{"u2", "u1"}, list(UserQuery{Page: Page{Sort: pagination.Sort{Field: "user_id", Desc: true}, Cursor: &pagination.Cursor{ID: "u3"}, Limit: 1}}))
+	assert.Equal(t, []string{"u2", "u3"}, list(UserQuery{Page: Page{Offset: 2, Limit: 5}}))
+}
+
+func testTeams(t *testing.T, store Store) {
+	teams := store.Teams()
+
+	for _, team := range []models.TeamDB{
+		{TeamName: "platform", MaxReviewers: 2},
+		{TeamName: "backend", ParentTeamName: "platform", MaxReviewers: 2},
+		{TeamName: "frontend", ParentTeamName: "platform"},
+	} {
+		require.NoError(t, teams.Create(&team))
+	}
+	assert.ErrorIs(t, teams.Create(&models.TeamDB{TeamName: "backend"}), ErrDuplicate)
+
+	team, err := teams.Get("frontend")
+	require.NoError(t, err)
+	assert.Equal(t, 2, team.MaxReviewers)
+	assert.Equal(t, models.SLAPolicyEscalate, team.SLAPolicy)
+	assert.Equal(t, models.StrategyRandom, team.Strategy)
+	_, err = teams.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	team.SLAHours = 4
+	team.ChatWebhook = "https://chat.example.org/hook"
+	require.NoError(t, teams.Update(team))
+	team, err = teams.Get("frontend")
+	require.NoError(t, err)
+	assert.Equal(t, 4, team.SLAHours)
+	assert.Equal(t, "https://chat.example.org/hook", team.ChatWebhook)
+	assert.ErrorIs(t, teams.Update(&models.TeamDB{TeamName: "missing"}), ErrNotFound)
+
+	list, err := teams.List(TeamQuery{ParentTeamName: "platform"})
+	require.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "backend", list[0].TeamName)
+		assert.Equal(t, "frontend", list[1].TeamName)
+	}
+	count, err := teams.Count(TeamQuery{Search: "BACK"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	createUsers(t, store, models.User{UserID: "u1", Username: "Alice"})
+	require.NoError(t, teams.AddMembership("platform", "u1", "", true))
+	require.NoError(t, teams.MoveSubTeams("platform", ""))
+	require.NoError(t, teams.Delete("platform"))
+	assert.ErrorIs(t, teams.Delete("platform"), ErrNotFound)
+
+	list, err = teams.List(TeamQuery{})
+	require.NoError(t, err)
+	for _, team := range list {
+		assert.Empty(t, team.ParentTeamName)
+	}
+	memberships, err := teams.Memberships(MembershipQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, memberships)
+}
+
+func testMemberships(t *testing.T, store Store) {
+	createTeams(t, store, "backend", "frontend")
+	createUsers(t, store, models.User{UserID: "u1", Username: "Alice"}, models.User{UserID: "u2", Username: "Bob"})
+	teams := store.Teams()
+
+	require.NoError(t, teams.AddMembership("frontend", "u1", models.MembershipRoleLead, true))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, teams.AddMembership("backend", "u1", "", true))
+	require.NoError(t, teams.AddMembership("backend", "u2", "", false))
+
+	// An empty role keeps the current one.
+	require.NoError(t, teams.AddMembership("frontend", "u1", "", false))
+
+	memberships, err := teams.Memberships(MembershipQuery{UserIDs: []string{"u1"}})
+	require.NoError(t, err)
+	if assert.Len(t, memberships, 2) {
+		assert.Equal(t, "frontend", memberships[0].TeamName)
+		assert.Equal(t, models.MembershipRoleLead, memberships[0].Role)
+		assert.False(t, memberships[0].IsActive)
+		assert.Equal(t, "backend", memberships[1].TeamName)
+		assert.Equal(t, models.MembershipRoleMember, memberships[1].Role)
+	}
+
+	memberships, err = teams.Memberships(MembershipQuery{TeamNames: []string{"backend"}, UserIDs: []string{"u2"}})
+	require.NoError(t, err)
+	assert.Len(t, memberships, 1)
+	memberships, err = teams.Memberships(MembershipQuery{TeamNames: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, memberships)
+
+	require.NoError(t, teams.RemoveMembership("frontend", "u1"))
+	memberships, err = teams.Memberships(MembershipQuery{})
+	require.NoError(t, err)
+	assert.Len(t, memberships, 2)
+}
+
+func testCandidates(t *testing.T, store Store) {
+	now := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	createUsers(t, store,
+		models.User{UserID: "author", Username: "Author", IsActive: true},
+		models.User{UserID: "busy", Username: "Busy", IsActive: true, Capacity: 1},
+		models.User{UserID: "free", Username: "Free", IsActive: true, Capacity: 2},
+		models.User{UserID: "gone", Username: "Gone", IsActive: false},
+		models.User{UserID: "observer", Username: "Observer", IsActive: true},
+		models.User{UserID: "ooo", Username: "OOO", IsActive: true, OOOFrom: &past, OOOUntil: &future},
+		models.User{UserID: "back", Username: "Back", IsActive: true, OOOFrom: &past, OOOUntil: &past},
+		models.User{UserID: "later", Username: "Later", IsActive: true, OOOFrom: &future, OOOUntil: &future},
+		models.User{UserID: "paused", Username: "Paused", IsActive: true},
+		models.User{UserID: "other", Username: "Other", IsActive: true},
+	)
+	createTeams(t, store, "backend", "frontend")
+	teams := store.Teams()
+	for _, userID := range []string{"author", "busy", "free", "gone", "ooo", "back", "later"} {
+		require.NoError(t, teams.AddMembership("backend", userID, "", true))
+	}
+	require.NoError(t, teams.AddMembership("backend", "observer", models.MembershipRoleObserver, true))
+	require.NoError(t, teams.AddMembership("backend", "paused", "", false))
+	require.NoError(t, teams.AddMembership("frontend", "other", "", true))
+	require.NoError(t, teams.AddMembership("frontend", "free", "", true))
+
+	prs := store.PullRequests()
+	for _, pr := range []models.PullRequest{
+		{PullRequestID: "open", PullRequestName: "Open", AuthorID: "author", TeamName: "backend", Status: "OPEN", AssignedReviewers: reviewersJSON("busy", "free")},
+		{PullRequestID: "merged", PullRequestName: "Merged", AuthorID: "author", TeamName: "backend", Status: "MERGED", AssignedReviewers: reviewersJSON("free")},
+	} {
+		require.NoError(t, prs.Create(&pr))
+	}
+	for _, assignment := range []models.ReviewAssignment{
+		{PullRequestID: "open", ReviewerID: "busy", AssignedAt: now},
+		{PullRequestID: "open", ReviewerID: "free", AssignedAt: now},
+		{PullRequestID: "merged", ReviewerID: "free", AssignedAt: now},
+	} {
+		require.NoError(t, prs.SaveAssignment(&assignment))
+	}
+
+	candidates, err := store.Users().Candidates([]string{"backend"}, []string{"author"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"back", "free", "later"}, userIDs(candidates))
+
+	candidates, err = store.Users().Candidates([]string{"backend", "frontend"}, nil, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"author", "back", "free", "later", "other"}, userIDs(candidates))
+
+	members, err := store.Users().ActiveMembers([]string{"backend", "frontend"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"author", "back", "busy", "free", "later", "observer", "ooo", "other"}, userIDs(members))
+}
+
+func testPullRequests(t *testing.T, store Store) {
+	createUsers(t, store,
+		models.User{UserID: "u1", Username: "Alice"},
+		models.User{UserID: "u2", Username: "Bob"},
+		models.User{UserID: "u3", Username: "Carol"},
+	)
+	prs := store.PullRequests()
+	createdAt := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	for i, pr := range []models.PullRequest{
+		{PullRequestID: "pr-2", PullRequestName: "Second", AuthorID: "u1", TeamName: "backend", Status: "OPEN", AssignedReviewers: reviewersJSON("u2")},
+		{PullRequestID: "pr-1", PullRequestName: "First", AuthorID: "u1", TeamName: "backend", Status: "OPEN", AssignedReviewers: reviewersJSON()},
+		{PullRequestID: "pr-3", PullRequestName: "Third", AuthorID: "u2", TeamName: "frontend", Status: "MERGED", AssignedReviewers: reviewersJSON("u1")},
+	} {
+		pr.CreatedAt = createdAt.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, prs.Create(&pr))
+	}
+	assert.ErrorIs(t, prs.Create(&models.PullRequest{PullRequestID: "pr-1", Status: "OPEN"}), ErrDuplicate)
+
+	pr, err := prs.Get("pr-2")
+	require.NoError(t, err)
+	assert.JSONEq(t, `["u2"]`, string(pr.AssignedReviewers))
+	assert.True(t, pr.CreatedAt.Equal(createdAt))
+	assert.Equal(t, 1, pr.Version)
+	_, err = prs.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	mergedAt := createdAt.Add(time.Hour)
+	pr.Status = "MERGED"
+	pr.MergedAt = &mergedAt
+	pr.AssignedReviewers = reviewersJSON("u2", "u3")
+	require.NoError(t, prs.Update(pr))
+	assert.Equal(t, 2, pr.Version)
+	stale := *pr
+	stale.Version = 1
+	assert.ErrorIs(t, prs.Update(&stale), ErrConflict)
+	assert.Equal(t, 1, stale.Version)
+	pr, err = prs.Lock("pr-2")
+	require.NoError(t, err)
+	assert.Equal(t, 2, pr.Version)
+	assert.Equal(t, "MERGED", pr.Status)
+	assert.True(t, pr.MergedAt.Equal(mergedAt))
+	assert.JSONEq(t, `["u2","u3"]`, string(pr.AssignedReviewers))
+	assert.ErrorIs(t, prs.Update(&models.PullRequest{PullRequestID: "missing", Status: "OPEN"}), ErrNotFound)
+
+	ids := func(query PullRequestQuery) []string {
+		list, err := prs.List(query)
+		require.NoError(t, err)
+		ids := []string{}
+		for _, pr := range list {
+			ids = append(ids, pr.PullRequestID)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"pr-2", "pr-1", "pr-3"}, ids(PullRequestQuery{}))
+	assert.Equal(t, []string{"pr-1"}, ids(PullRequestQuery{Status: "OPEN"}))
+	assert.Equal(t, []string{"pr-2", "pr-1"}, ids(PullRequestQuery{TeamNames: []string{"backend"}}))
+	assert.Equal(t, []string{"pr-3"}, ids(PullRequestQuery{IDs: []string{"pr-3", "missing"}}))
+	assert.Empty(t, ids(PullRequestQuery{IDs: []string{}}))
+
+	assignedAt := createdAt.Add(time.Minute)
+	require.NoError(t, prs.SaveAssignment(&models.ReviewAssignment{PullRequestID: "pr-1", ReviewerID: "u2", AssignedAt: createdAt}))
+	require.NoError(t, prs.SaveAssignment(&models.ReviewAssignment{PullRequestID: "pr-1", ReviewerID: "u3", AssignedAt: createdAt}))
+	require.NoError(t, prs.SaveAssignment(&models.ReviewAssignment{PullRequestID: "pr-1", ReviewerID: "u2", AssignedAt: createdAt, EscalatedAt: &assignedAt}))
+	require.NoError(t, prs.SaveAssignment(&models.ReviewAssignment{PullRequestID: "pr-2", ReviewerID: "u2", AssignedAt: createdAt}))
+	require.NoError(t, prs.DeleteAssignment("pr-1", "u3"))
+
+	assert.Equal(t, []string{"pr-2", "pr-1"}, ids(PullRequestQuery{ReviewerID: "u2"}))
+	assert.Equal(t, []string{"pr-3"}, ids(PullRequestQuery{AuthorID: "u2"}))
+	assert.Equal(t, []string{"pr-1"}, ids(PullRequestQuery{AuthorID: "u1", ReviewerID: "u2", Status: "OPEN"}))
+	assert.Equal(t, []string{"pr-2", "pr-1"}, ids(PullRequestQuery{Page: Page{Limit: 1}}))
+	assert.Equal(t, []string{"pr-3"}, ids(PullRequestQuery{Page: Page{
+		Cursor:      &pagination.Cursor{Value: createdAt.Add(time.Minute).Format(time.RFC3339Nano), ID: "pr-1"},
+		CursorValue: createdAt.Add(time.Minute),
+		Limit:       1,
+	}}))
+
+	assignments, err := prs.Assignments([]string{"pr-1"})
+	require.NoError(t, err)
+	if assert.Len(t, assignments, 1) {
+		assert.Equal(t, "u2", assignments[0].ReviewerID)
+		if assert.NotNil(t, assignments[0].EscalatedAt) {
+			assert.True(t, assignments[0].EscalatedAt.Equal(assignedAt))
+		}
+	}
+
+	require.NoError(t, prs.CreateDecline(&models.ReviewDecline{PullRequestID: "pr-1", ReviewerID: "u3", Reason: models.DeclineReasonBusy}))
+	require.NoError(t, prs.Delete("pr-1"))
+	assert.ErrorIs(t, prs.Delete("pr-1"), ErrNotFound)
+
+	assignments, err = prs.Assignments(nil)
+	require.NoError(t, err)
+	if assert.Len(t, assignments, 1) {
+		assert.Equal(t, "pr-2", assignments[0].PullRequestID)
+	}
+	declines, err := prs.Declines(DeclineQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, declines)
+}
+
+func testDeclines(t *testing.T, store Store) {
+	createUsers(t, store, models.User{UserID: "u1", Username: "Alice"}, models.User{UserID: "u2", Username: "Bob"})
+	prs := store.PullRequests()
+	for _, pr := range []models.PullRequest{
+		{PullRequestID: "pr-1", PullRequestName: "First", AuthorID: "u1", TeamName: "backend", Status: "OPEN"},
+		{PullRequestID: "pr-2", PullRequestName: "Second", AuthorID: "u1", TeamName: "frontend", Status: "OPEN"},
+	} {
+		require.NoError(t, prs.Create(&pr))
+	}
+	for _, decline := range []models.ReviewDecline{
+		{PullRequestID: "pr-1", ReviewerID: "u2", Reason: models.DeclineReasonBusy, ReplacedBy: "u3"},
+		{PullRequestID: "pr-2", ReviewerID: "u2", Reason: models.DeclineReasonLacksContext},
+	} {
+		require.NoError(t, prs.CreateDecline(&decline))
+	}
+	assert.ErrorIs(t, prs.CreateDecline(&models.ReviewDecline{PullRequestID: "pr-1", ReviewerID: "u2", Reason: models.DeclineReasonBusy}), ErrDuplicate)
+
+	declines, err := prs.Declines(DeclineQuery{PullRequestIDs: []string{"pr-1"}})
+	require.NoError(t, err)
+	if assert.Len(t, declines, 1) {
+		assert.Equal(t, "u3", declines[0].ReplacedBy)
+	}
+
+	declines, err = prs.Declines(DeclineQuery{TeamNames: []string{"frontend"}})
+	require.NoError(t, err)
+	if assert.Len(t, declines, 1) {
+		assert.Equal(t, "pr-2", declines[0].PullRequestID)
+	}
+
+	declines, err = prs.Declines(DeclineQuery{})
+	require.NoError(t, err)
+	assert.Len(t, declines, 2)
+}
+
+func testTransaction(t *testing.T, store Store) {
+	createTeams(t, store, "backend")
+	failure := errors.New("failure")
+	err := store.Transaction(func(tx Store) error {
+		createUsers(t, tx, models.User{UserID: "u1", Username: "Alice"})
+		require.NoError(t, tx.Teams().AddMembership("backend", "u1", "", true))
+
+		_, err := tx.Users().Get("u1")
+		require.NoError(t, err)
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+
+	_, err = store.Users().Get("u1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	memberships, err := store.Teams().Memberships(MembershipQuery{})
+	require.NoError(t, err)
+	assert.Empty(t, memberships)
+
+	require.NoError(t, store.Transaction(func(tx Store) error {
+		createUsers(t, tx, models.User{UserID: "u1", Username: "Alice"})
+		return nil
+	}))
+	_, err = store.Users().Get("u1")
+	assert.NoError(t, err)
+}
+
+func testNestedTransaction(t *testing.T, store Store) {
+	failure := errors.New("failure")
+	err := store.Transaction(func(tx Store) error {
+		createUsers(t, tx, models.User{UserID: "u1", Username: "Alice"})
+
+		err := tx.Transaction(func(nested Store) error {
+			createUsers(t, nested, models.User{UserID: "u2", Username: "Bob"})
+			return failure
+		})
+		assert.ErrorIs(t, err, failure)
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, err = store.Users().Get("u1")
+	assert.NoError(t, err)
+	_, err = store.Users().Get("u2")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// testConcurrentUpdates appends to the reviewers of one pull request from
+// many transactions at once; locking the row must keep every append.
+func testConcurrentUpdates(t *testing.T, store Store) {
+	createUsers(t, store, models.User{UserID: "u1", Username: "Alice"})
+	require.NoError(t, store.PullRequests().Create(&models.PullRequest{PullRequestID: "pr-1", PullRequestName: "First", AuthorID: "u1", AssignedReviewers: reviewersJSON()}))
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Transaction(func(tx Store) error {
+				pr, err := tx.PullRequests().Lock("pr-1")
+				if err != nil {
+					return err
+				}
+				var reviewers []string
+				if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
+					return err
+				}
+				pr.AssignedReviewers = reviewersJSON(append(reviewers, fmt.Sprintf("r%d", i))...)
+				return tx.PullRequests().Update(pr)
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	pr, err := store.PullRequests().Get("pr-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1+writers, pr.Version)
+	var reviewers []string
+	require.NoError(t, json.Unmarshal(pr.AssignedReviewers, &reviewers))
+	assert.Len(t, reviewers, writers)
+}
+
+func testIdempotencyKeys(t *testing.T, store Store) {
+	keys := store.IdempotencyKeys()
+	now := time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, keys.Create(&models.IdempotencyKey{Key: "k1", Fingerprint: "f1", ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, keys.Create(&models.IdempotencyKey{Key: "k2", Fingerprint: "f2", ExpiresAt: now.Add(-time.Hour)}))
+	assert.ErrorIs(t, keys.Create(&models.IdempotencyKey{Key: "k1", Fingerprint: "other", ExpiresAt: now}), ErrDuplicate)
+
+	record, err := keys.Get("k1")
+	require.NoError(t, err)
+	assert.Equal(t, "f1", record.Fingerprint)
+	assert.Zero(t, record.StatusCode)
+	assert.False(t, record.CreatedAt.IsZero())
+
+	record.StatusCode = 201
+	record.ResponseHeaders = []byte(`{"Content-Type":"application/json"}`)
+	record.ResponseBody = []byte(`{"ok":true}`)
+	require.NoError(t, keys.Update(record))
+	record, err = keys.Get("k1")
+	require.NoError(t, err)
+	assert.Equal(t, 201, record.StatusCode)
+	assert.Equal(t, `{"ok":true}`, string(record.ResponseBody))
+	assert.JSONEq(t, `{"Content-Type":"application/json"}`, string(record.ResponseHeaders))
+	assert.ErrorIs(t, keys.Update(&models.IdempotencyKey{Key: "missing", ExpiresAt: now}), ErrNotFound)
+
+	deleted, err := keys.DeleteExpired(now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	_, err = keys.Get("k2")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, keys.Delete("k1"))
+	assert.ErrorIs(t, keys.Delete("k1"), ErrNotFound)
+}
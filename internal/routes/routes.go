@@ -5,17 +5,68 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter(teamHandler *handlers.TeamHandler, userHandler *handlers.UserHandler, prHandler *handlers.PRHandler, statsHandler *handlers.StatsHandler) *gin.Engine {
-	router := gin.Default()
+func SetupRouter(teamHandler *handlers.TeamHandler, userHandler *handlers.UserHandler, prHandler *handlers.PRHandler, statsHandler *handlers.StatsHandler, adminHandler *handlers.AdminHandler, scimHandler *handlers.SCIMHandler, idempotencyHandler *handlers.IdempotencyHandler, docsHandler *handlers.DocsHandler, v2Handler *handlers.V2Handler) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Logger(), gin.CustomRecovery(handlers.RecoverInternal))
+	router.Use(idempotencyHandler.Replay, handlers.RenderErrors)
 
 	router.POST("/team/add", teamHandler.AddTeam)
 	router.GET("/team/get", teamHandler.GetTeam)
+	router.GET("/team/list", teamHandler.ListTeams)
+	router.POST("/team/update", teamHandler.UpdateTeam)
+	router.POST("/team/delete", teamHandler.DeleteTeam)
 	router.POST("/users/setIsActive", userHandler.SetUserActive)
 	router.GET("/users/getReview", userHandler.GetUserReviews)
+	router.GET("/users/list", userHandler.ListUsers)
+	router.POST("/users/moveTeam", userHandler.MoveUser)
 	router.POST("/pullRequest/create", prHandler.CreatePullRequest)
 	router.POST("/pullRequest/merge", prHandler.MergePullRequest)
 	router.POST("/pullRequest/reassign", prHandler.ReassignReviewer)
+	router.POST("/pullRequest/addReviewer", prHandler.AddReviewer)
+	router.POST("/pullRequest/removeReviewer", prHandler.RemoveReviewer)
+	router.POST("/pullRequest/decline", prHandler.DeclineReview)
 	router.GET("/stats/reviewers", statsHandler.GetReviewerStats)
+	router.POST("/admin/import", adminHandler.Import)
+	router.POST("/admin/reconcile", adminHandler.Reconcile)
+
+	scim := router.Group("/scim/v2", scimHandler.Authenticate)
+	scim.GET("/Users", scimHandler.ListUsers)
+	scim.POST("/Users", scimHandler.CreateUser)
+	scim.GET("/Users/:id", scimHandler.GetUser)
+	scim.PUT("/Users/:id", scimHandler.ReplaceUser)
+	scim.PATCH("/Users/:id", scimHandler.PatchUser)
+	scim.DELETE("/Users/:id", scimHandler.DeleteUser)
+	scim.GET("/Groups", scimHandler.ListGroups)
+	scim.POST("/Groups", scimHandler.CreateGroup)
+	scim.GET("/Groups/:id", scimHandler.GetGroup)
+	scim.PUT("/Groups/:id", scimHandler.ReplaceGroup)
+	scim.PATCH("/Groups/:id", scimHandler.PatchGroup)
+	scim.DELETE("/Groups/:id", scimHandler.DeleteGroup)
+
+	v2 := router.Group("/v2")
+	v2.GET("/teams", v2Handler.ListTeams)
+	v2.POST("/teams", v2Handler.CreateTeam)
+	v2.GET("/teams/:name", v2Handler.GetTeam)
+	v2.PATCH("/teams/:name", v2Handler.UpdateTeam)
+	v2.DELETE("/teams/:name", v2Handler.DeleteTeam)
+	v2.GET("/users", v2Handler.ListUsers)
+	v2.GET("/users/:id", v2Handler.GetUser)
+	v2.PATCH("/users/:id", v2Handler.UpdateUser)
+	v2.PUT("/users/:id/team", v2Handler.SetUserTeam)
+	v2.GET("/users/:id/reviews", v2Handler.ListUserReviews)
+	v2.GET("/pull-requests", v2Handler.ListPullRequests)
+	v2.POST("/pull-requests", v2Handler.CreatePullRequest)
+	v2.GET("/pull-requests/:id", v2Handler.GetPullRequest)
+	v2.PATCH("/pull-requests/:id", v2Handler.UpdatePullRequest)
+	v2.GET("/pull-requests/:id/reviewers", v2Handler.ListReviewers)
+	v2.POST("/pull-requests/:id/reviewers", v2Handler.AddReviewer)
+	v2.DELETE("/pull-requests/:id/reviewers/:user_id", v2Handler.RemoveReviewer)
+	v2.POST("/pull-requests/:id/reviewers/:user_id/reassign", v2Handler.ReassignReviewer)
+	v2.POST("/pull-requests/:id/reviewers/:user_id/decline", v2Handler.DeclineReview)
+	v2.GET("/stats/reviewers", v2Handler.GetReviewerStats)
+
+	router.GET("/openapi.json", docsHandler.Spec)
+	router.GET("/docs", docsHandler.Page)
 
 	return router
 }
\ No newline at end of file
@@ -0,0 +1,459 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/handlers"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/openapi"
+	"prReviewerAssignment/internal/repository"
+
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SCIM_BEARER_TOKEN", "")
+
+	store, notifier := db.Store, notify.Default
+	db.Store, notify.Default = repository.NewMemoryStore(), notify.Multi{}
+	t.Cleanup(func() {
+		db.Store, notify.Default = store, notifier
+	})
+
+	docsHandler, err := handlers.NewDocsHandler()
+	require.NoError(t, err)
+	return SetupRouter(handlers.NewTeamHandler(), handlers.NewUserHandler(), handlers.NewPRHandler(), handlers.NewStatsHandler(),
+		handlers.NewAdminHandler(), handlers.NewSCIMHandler(), handlers.NewIdempotencyHandler(time.Hour), docsHandler, handlers.NewV2Handler())
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func TestEveryRouteIsDocumented(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	for _, route := range newRouter(t).Routes() {
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		pathItem := spec.Paths.Find(path)
+		if assert.NotNil(t, pathItem, "%s %s", route.Method, route.Path) {
+			assert.NotNil(t, pathItem.GetOperation(route.Method), "%s %s", route.Method, route.Path)
+		}
+	}
+}
+
+// contractClient sends requests through an openapi.Validator, failing the
+// test on every request or response breaking the OpenAPI document. Requests
+// sent with expectInvalid have to break it.
+type contractClient struct {
+	t        *testing.T
+	handler  http.Handler
+	invalid  bool
+	rejected bool
+}
+
+func newContractClient(t *testing.T) *contractClient {
+	c := &contractClient{t: t}
+	validator, err := openapi.NewValidator(newRouter(t), func(err error) {
+		var requestError *openapi3filter.RequestError
+		if c.invalid && errors.As(err, &requestError) {
+			c.rejected = true
+			return
+		}
+		t.Error(err)
+	})
+	require.NoError(t, err)
+	c.handler = validator
+	return c
+}
+
+func (c *contractClient) do(method, path, contentType, body string, headers ...string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+
+	response := httptest.NewRecorder()
+	c.handler.ServeHTTP(response, request)
+	return response
+}
+
+func (c *contractClient) expect(status int, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	response := c.do(method, path, "application/json", body, headers...)
+	assert.Equal(c.t, status, response.Code, "%s %s: %s", method, path, response.Body.String())
+	return response
+}
+
+// expectInvalid sends a request the document rejects, checking that the
+// validator does and that the service answers with status.
+func (c *contractClient) expectInvalid(status int, method, path, body string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	c.invalid, c.rejected = true, false
+	defer func() { c.invalid = false }()
+
+	response := c.expect(status, method, path, body)
+	assert.True(c.t, c.rejected, "%s %s: request passed validation", method, path)
+	return response
+}
+
+func (c *contractClient) expectSCIM(status int, method, path, body string) *httptest.ResponseRecorder {
+	c.t.Helper()
+	response := c.do(method, path, "application/scim+json", body)
+	assert.Equal(c.t, status, response.Code, "%s %s: %s", method, path, response.Body.String())
+	return response
+}
+
+func scimRequest(t *testing.T, name string) string {
+	data, err := os.ReadFile("../services/testdata/scim/" + name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRoutesHonourContract(t *testing.T) {
+	c := newContractClient(t)
+
+	// Teams
+	c.expect(201, "POST", "/team/add", `{"team_name": "backend", "min_reviewers": 1, "max_reviewers": 2, "members": [
+		{"user_id": "alice", "username": "Alice", "is_active": true, "role": "LEAD"},
+		{"user_id": "bob", "username": "Bob", "is_active": true},
+		{"user_id": "carol", "username": "Carol", "is_active": true, "timezone": "Europe/Berlin"},
+		{"user_id": "dave", "username": "Dave", "is_active": true},
+		{"user_id": "erin", "username": "Erin", "is_active": true}]}`)
+	c.expect(409, "POST", "/team/add", `{"team_name": "backend", "members": [{"user_id": "alice", "username": "Alice", "is_active": true}]}`)
+	c.expect(400, "POST", "/team/add", `{"team_name": "broken", "min_reviewers": 3, "max_reviewers": 1, "members": [{"user_id": "zed", "username": "Zed", "is_active": true}]}`)
+	c.expect(404, "POST", "/team/add", `{"team_name": "orphan", "parent_team_name": "missing", "members": [{"user_id": "zed", "username": "Zed", "is_active": true}]}`)
+	c.expect(201, "POST", "/team/add", `{"team_name": "frontend", "parent_team_name": "backend", "members": [
+		{"user_id": "frank", "username": "Frank", "is_active": true},
+		{"user_id": "grace", "username": "Grace", "is_active": true}]}`)
+	c.expect(200, "GET", "/team/get?team_name=backend&include_descendants=true", "")
+	c.expectInvalid(400, "GET", "/team/get", "")
+	c.expect(404, "GET", "/team/get?team_name=missing", "")
+	c.expect(200, "GET", "/team/list?limit=1&sort=-team_name", "")
+	c.expectInvalid(400, "GET", "/team/list?limit=500", "")
+	c.expect(200, "GET", "/team/list?search=nothing", "")
+	c.expect(200, "GET", "/stats/reviewers?team_name=frontend", "")
+	c.expect(200, "GET", "/users/getReview?user_id=grace", "")
+
+	// Users
+	c.expect(200, "GET", "/users/list?team_name=backend&is_active=true&sort=username", "")
+	c.expect(200, "GET", "/users/list?team_name=nothing", "")
+	c.expect(400, "GET", "/users/list?sort=password", "")
+	c.expect(200, "POST", "/users/setIsActive", `{"user_id": "erin", "is_active": false}`)
+	c.expect(404, "POST", "/users/setIsActive", `{"user_id": "nobody", "is_active": true}`)
+
+	// Pull requests
+	created := c.expect(201, "POST", "/pullRequest/create", `{"pull_request_id": "pr-1", "pull_request_name": "Add search", "author_id": "alice"}`,
+		"Idempotency-Key", "create-pr-1")
+	c.expect(201, "POST", "/pullRequest/create", `{"pull_request_id": "pr-1", "pull_request_name": "Add search", "author_id": "alice"}`,
+		"Idempotency-Key", "create-pr-1")
+	c.expect(422, "POST", "/pullRequest/create", `{"pull_request_id": "pr-2", "pull_request_name": "Add search", "author_id": "alice"}`,
+		"Idempotency-Key", "create-pr-1")
+	c.expect(409, "POST", "/pullRequest/create", `{"pull_request_id": "pr-1", "pull_request_name": "Add search", "author_id": "alice"}`)
+	c.expect(404, "POST", "/pullRequest/create", `{"pull_request_id": "pr-3", "pull_request_name": "Ghost", "author_id": "nobody"}`)
+	c.expectInvalid(400, "POST", "/pullRequest/create", `{"pull_request_id": "pr-3"}`)
+
+	var response struct {
+		PR struct {
+			AssignedReviewers []string `json:"assigned_reviewers"`
+		} `json:"pr"`
+	}
+	require.NoError(t, json.Unmarshal(created.Body.Bytes(), &response))
+	reviewers := response.PR.AssignedReviewers
+	require.Len(t, reviewers, 2)
+	var spare string
+	for _, userID := range []string{"bob", "carol", "dave"} {
+		if userID != reviewers[0] && userID != reviewers[1] {
+			spare = userID
+		}
+	}
+
+	addSpare := fmt.Sprintf(`{"pull_request_id": "pr-1", "reviewer_id": %q}`, spare)
+	c.expect(409, "POST", "/pullRequest/addReviewer", addSpare)
+	c.expect(403, "POST", "/pullRequest/addReviewer", fmt.Sprintf(`{"pull_request_id": "pr-1", "reviewer_id": %q, "override_approved_by": %q}`, spare, reviewers[0]))
+	c.expect(200, "POST", "/pullRequest/addReviewer", fmt.Sprintf(`{"pull_request_id": "pr-1", "reviewer_id": %q, "override_approved_by": "alice"}`, spare))
+	c.expect(200, "POST", "/pullRequest/removeReviewer", addSpare)
+	c.expect(412, "POST", "/pullRequest/reassign", fmt.Sprintf(`{"pull_request_id": "pr-1", "old_reviewer_id": %q}`, reviewers[0]),
+		"If-Match", `"1"`)
+	c.expect(200, "POST", "/pullRequest/reassign", fmt.Sprintf(`{"pull_request_id": "pr-1", "old_reviewer_id": %q}`, reviewers[0]))
+	c.expect(409, "POST", "/pullRequest/reassign", fmt.Sprintf(`{"pull_request_id": "pr-1", "old_reviewer_id": %q}`, reviewers[0]))
+	c.expectInvalid(400, "POST", "/pullRequest/decline", fmt.Sprintf(`{"pull_request_id": "pr-1", "reviewer_id": %q, "reason": "BORED"}`, reviewers[1]))
+	c.expect(200, "POST", "/pullRequest/decline", fmt.Sprintf(`{"pull_request_id": "pr-1", "reviewer_id": %q, "reason": "BUSY"}`, reviewers[1]))
+
+	c.expect(200, "GET", "/users/getReview?user_id="+spare, "")
+	c.expectInvalid(400, "GET", "/users/getReview", "")
+	c.expect(404, "GET", "/users/getReview?user_id=nobody", "")
+	c.expect(200, "GET", "/stats/reviewers", "")
+	c.expect(200, "GET", "/stats/reviewers?team_name=backend", "")
+	c.expect(404, "GET", "/stats/reviewers?team_name=missing", "")
+
+	c.expect(200, "POST", "/pullRequest/merge", `{"pull_request_id": "pr-1"}`)
+	c.expect(200, "POST", "/pullRequest/merge", `{"pull_request_id": "pr-1"}`)
+	c.expect(404, "POST", "/pullRequest/merge", `{"pull_request_id": "pr-404"}`)
+	c.expect(409, "POST", "/pullRequest/decline", fmt.Sprintf(`{"pull_request_id": "pr-1", "reviewer_id": %q, "reason": "BUSY"}`, spare))
+
+	// Roster changes
+	c.expect(200, "POST", "/users/moveTeam", `{"user_id": "dave", "team_name": "frontend", "hand_off_reviews": true}`)
+	c.expect(404, "POST", "/users/moveTeam", `{"user_id": "dave", "team_name": "missing"}`)
+	c.expect(200, "POST", "/team/update", `{"team_name": "backend",
+		"add_members": [{"user_id": "heidi", "username": "Heidi", "is_active": true}], "remove_members": ["carol"]}`)
+	c.expect(409, "POST", "/team/update", `{"team_name": "backend", "remove_members": ["grace"]}`)
+
+	roster := `
+teams:
+  - team_name: platform
+    parent_team_name: backend
+    members:
+      - {user_id: ivan, username: Ivan}
+      - {user_id: judy, username: Judy, is_active: false, role: OBSERVER}
+`
+	response2 := c.do("POST", "/admin/import?dry_run=true", "application/yaml", roster)
+	assert.Equal(t, 200, response2.Code, response2.Body.String())
+	response2 = c.do("POST", "/admin/import", "application/yaml", roster)
+	assert.Equal(t, 200, response2.Code, response2.Body.String())
+	response2 = c.do("POST", "/admin/import?format=csv", "text/csv", "team_name,user_id,username,role\nplatform,kim,Kim,CAPTAIN\n")
+	assert.Equal(t, 400, response2.Code, response2.Body.String())
+	response2 = c.do("POST", "/admin/reconcile", "application/yaml", "teams:\n  - team_name: platform\n    members:\n      - {user_id: ivan, username: Ivan}\n")
+	assert.Equal(t, 200, response2.Code, response2.Body.String())
+	response2 = c.do("POST", "/admin/reconcile?apply=true", "application/yaml", "teams:\n  - team_name: platform\n    members:\n      - {user_id: ivan, username: Ivan}\n")
+	assert.Equal(t, 200, response2.Code, response2.Body.String())
+
+	c.expect(400, "POST", "/team/delete", `{"team_name": "platform", "policy": "REASSIGN"}`)
+	c.expect(200, "POST", "/team/delete", `{"team_name": "platform"}`)
+	c.expect(404, "POST", "/team/delete", `{"team_name": "platform"}`)
+	c.expect(200, "GET", "/users/getReview?user_id=ivan", "")
+
+	// SCIM
+	c.expectSCIM(201, "POST", "/scim/v2/Users", scimRequest(t, "okta_create_user.json"))
+	c.expectSCIM(201, "POST", "/scim/v2/Users", scimRequest(t, "azure_create_user.json"))
+	c.expectSCIM(409, "POST", "/scim/v2/Users", scimRequest(t, "okta_create_user.json"))
+	c.expectSCIM(200, "GET", "/scim/v2/Users?startIndex=1&count=10", "")
+	c.expectSCIM(200, "GET", `/scim/v2/Users?filter=userName+eq+%22alice@example.com%22`, "")
+	c.expectSCIM(400, "GET", "/scim/v2/Users?filter=active+pr", "")
+	c.expectSCIM(200, "GET", "/scim/v2/Users/alice@example.com", "")
+	c.expectSCIM(404, "GET", "/scim/v2/Users/nobody", "")
+	c.expectSCIM(200, "PATCH", "/scim/v2/Users/alice@example.com", scimRequest(t, "azure_patch_user_attributes.json"))
+	c.expectSCIM(200, "PUT", "/scim/v2/Users/alice@example.com", scimRequest(t, "okta_create_user.json"))
+	c.expectSCIM(201, "POST", "/scim/v2/Users", `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "carol@example.com"}`)
+	group := `{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"], "displayName": "identity", "members": [{"value": "alice@example.com"}]}`
+	c.expectSCIM(201, "POST", "/scim/v2/Groups", group)
+	c.expectSCIM(409, "POST", "/scim/v2/Groups", scimRequest(t, "okta_create_group.json"))
+	c.expectSCIM(200, "GET", "/scim/v2/Groups?excludedAttributes=members", "")
+	c.expectSCIM(200, "GET", "/scim/v2/Groups/identity", "")
+	c.expectSCIM(200, "PATCH", "/scim/v2/Groups/identity", scimRequest(t, "okta_patch_group_members.json"))
+	c.expectSCIM(200, "PATCH", "/scim/v2/Groups/identity", scimRequest(t, "azure_patch_group_remove_members.json"))
+	c.expectSCIM(200, "PUT", "/scim/v2/Groups/identity", group)
+	c.expectSCIM(204, "DELETE", "/scim/v2/Users/bob@contoso.com", "")
+	c.expectSCIM(204, "DELETE", "/scim/v2/Groups/identity", "")
+	c.expectSCIM(404, "DELETE", "/scim/v2/Groups/identity", "")
+
+	// Docs
+	c.expect(200, "GET", "/openapi.json", "")
+	c.expect(200, "GET", "/docs", "")
+}
+
+// decodeV2 reads a v2 response body into value.
+func decodeV2(t *testing.T, response *httptest.ResponseRecorder, value interface{}) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), value), response.Body.String())
+}
+
+type pageV2 struct {
+	Items      []json.RawMessage `json:"items"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+func decodePage(t *testing.T, response *httptest.ResponseRecorder) pageV2 {
+	t.Helper()
+	var page pageV2
+	decodeV2(t, response, &page)
+	return page
+}
+
+func TestV2RoutesHonourContract(t *testing.T) {
+	c := newContractClient(t)
+
+	// Teams
+	created := c.expect(201, "POST", "/v2/teams", `{"team_name": "backend", "min_reviewers": 1, "max_reviewers": 2, "members": [
+		{"user_id": "alice", "username": "Alice", "is_active": true, "role": "LEAD"},
+		{"user_id": "bob", "username": "Bob", "is_active": true},
+		{"user_id": "carol", "username": "Carol", "is_active": true},
+		{"user_id": "dave", "username": "Dave", "is_active": true},
+		{"user_id": "erin", "username": "Erin", "is_active": true}]}`)
+	assert.Equal(t, "/v2/teams/backend", created.Header().Get("Location"))
+	c.expect(409, "POST", "/v2/teams", `{"team_name": "backend", "members": [{"user_id": "alice", "username": "Alice", "is_active": true}]}`)
+	c.expect(400, "POST", "/v2/teams", `{"team_name": "empty", "members": []}`)
+	c.expectInvalid(400, "POST", "/v2/teams", `{"team_name": "nameless"}`)
+	c.expect(201, "POST", "/v2/teams", `{"team_name": "frontend", "parent_team_name": "backend", "members": [
+		{"user_id": "frank", "username": "Frank", "is_active": true},
+		{"user_id": "grace", "username": "Grace", "is_active": true}]}`)
+	c.expect(200, "GET", "/v2/teams/backend?include_descendants=true", "")
+	c.expect(404, "GET", "/v2/teams/missing", "")
+	c.expect(200, "GET", "/team/get?team_name=frontend", "")
+
+	teams := decodePage(t, c.expect(200, "GET", "/v2/teams?limit=1", ""))
+	require.Len(t, teams.Items, 1)
+	require.NotEmpty(t, teams.NextCursor)
+	teams = decodePage(t, c.expect(200, "GET", "/v2/teams?limit=1&cursor="+teams.NextCursor, ""))
+	assert.Len(t, teams.Items, 1)
+	assert.Empty(t, teams.NextCursor)
+	c.expectInvalid(400, "GET", "/v2/teams?limit=500", "")
+
+	// Users
+	users := decodePage(t, c.expect(200, "GET", "/v2/users?team_name=backend&limit=3&sort=username", ""))
+	assert.Len(t, users.Items, 3)
+	assert.NotEmpty(t, users.NextCursor)
+	c.expect(400, "GET", "/v2/users?sort=password", "")
+	assert.Contains(t, c.expectInvalid(400, "GET", "/v2/users?is_active=maybe", "").Body.String(), `"field":"is_active"`)
+	assert.Contains(t, c.expectInvalid(400, "GET", "/v2/users?limit=ten", "").Body.String(), `"field":"limit"`)
+	c.expect(200, "GET", "/v2/users/alice", "")
+	c.expect(404, "GET", "/v2/users/nobody", "")
+	c.expect(200, "PATCH", "/v2/users/erin", `{"is_active": false}`)
+	c.expectInvalid(400, "PATCH", "/v2/users/erin", `{}`)
+	c.expect(404, "PATCH", "/v2/users/nobody", `{"is_active": true}`)
+
+	// Pull requests
+	created = c.expect(201, "POST", "/v2/pull-requests", `{"pull_request_id": "pr-1", "pull_request_name": "Add search", "author_id": "alice"}`,
+		"Idempotency-Key", "create-pr-1")
+	assert.Equal(t, "/v2/pull-requests/pr-1", created.Header().Get("Location"))
+	assert.Equal(t, `"1"`, created.Header().Get("ETag"))
+	replayed := c.expect(201, "POST", "/v2/pull-requests", `{"pull_request_id": "pr-1", "pull_request_name": "Add search", "author_id": "alice"}`,
+		"Idempotency-Key", "create-pr-1")
+	assert.Equal(t, created.Body.String(), replayed.Body.String())
+	c.expect(409, "POST", "/v2/pull-requests", `{"pull_request_id": "pr-1", "pull_request_name": "Add search", "author_id": "alice"}`)
+	c.expect(404, "POST", "/v2/pull-requests", `{"pull_request_id": "pr-2", "pull_request_name": "Ghost", "author_id": "nobody"}`)
+
+	var pr struct {
+		AssignedReviewers []string `json:"assigned_reviewers"`
+		CreatedAt         string   `json:"created_at"`
+		MergedAt          string   `json:"merged_at"`
+	}
+	decodeV2(t, c.expect(200, "GET", "/v2/pull-requests/pr-1", ""), &pr)
+	assert.NotEmpty(t, pr.CreatedAt)
+	reviewers := pr.AssignedReviewers
+	require.Len(t, reviewers, 2)
+	var spare string
+	for _, userID := range []string{"bob", "carol", "dave"} {
+		if userID != reviewers[0] && userID != reviewers[1] {
+			spare = userID
+		}
+	}
+	c.expect(404, "GET", "/v2/pull-requests/pr-404", "")
+
+	assignments := decodePage(t, c.expect(200, "GET", "/v2/pull-requests/pr-1/reviewers", ""))
+	assert.Len(t, assignments.Items, 2)
+	assert.Empty(t, assignments.NextCursor)
+	c.expect(404, "GET", "/v2/pull-requests/pr-404/reviewers", "")
+
+	c.expect(409, "POST", "/v2/pull-requests/pr-1/reviewers", fmt.Sprintf(`{"reviewer_id": %q}`, spare))
+	c.expect(403, "POST", "/v2/pull-requests/pr-1/reviewers", fmt.Sprintf(`{"reviewer_id": %q, "override_approved_by": %q}`, spare, reviewers[0]))
+	c.expect(200, "POST", "/v2/pull-requests/pr-1/reviewers", fmt.Sprintf(`{"reviewer_id": %q, "override_approved_by": "alice"}`, spare))
+	c.expectInvalid(400, "POST", "/v2/pull-requests/pr-1/reviewers", `{}`)
+	c.expect(200, "DELETE", "/v2/pull-requests/pr-1/reviewers/"+spare, "")
+	c.expect(409, "DELETE", "/v2/pull-requests/pr-1/reviewers/"+spare, "")
+	c.expect(412, "POST", "/v2/pull-requests/pr-1/reviewers/"+reviewers[0]+"/reassign", "", "If-Match", `"1"`)
+
+	var replacement struct {
+		ReplacedBy string `json:"replaced_by"`
+	}
+	decodeV2(t, c.expect(200, "POST", "/v2/pull-requests/pr-1/reviewers/"+reviewers[0]+"/reassign", ""), &replacement)
+	assert.Equal(t, spare, replacement.ReplacedBy)
+	c.expect(409, "POST", "/v2/pull-requests/pr-1/reviewers/"+reviewers[0]+"/reassign", "")
+	c.expectInvalid(400, "POST", "/v2/pull-requests/pr-1/reviewers/"+reviewers[1]+"/decline", `{"reason": "BORED"}`)
+	c.expect(200, "POST", "/v2/pull-requests/pr-1/reviewers/"+reviewers[1]+"/decline", `{"reason": "BUSY"}`)
+
+	reviews := decodePage(t, c.expect(200, "GET", "/v2/users/"+spare+"/reviews?status=OPEN", ""))
+	assert.Len(t, reviews.Items, 1)
+	c.expect(404, "GET", "/v2/users/nobody/reviews", "")
+	c.expect(200, "GET", "/users/getReview?user_id="+spare, "")
+
+	c.expectInvalid(400, "PATCH", "/v2/pull-requests/pr-1", `{"status": "OPEN"}`)
+	c.expect(412, "PATCH", "/v2/pull-requests/pr-1", `{"status": "MERGED"}`, "If-Match", `"1"`)
+	decodeV2(t, c.expect(200, "PATCH", "/v2/pull-requests/pr-1", `{"status": "MERGED"}`), &pr)
+	assert.NotEmpty(t, pr.MergedAt)
+	c.expect(200, "PATCH", "/v2/pull-requests/pr-1", `{"status": "MERGED"}`)
+	c.expect(404, "PATCH", "/v2/pull-requests/pr-404", `{"status": "MERGED"}`)
+	c.expect(200, "POST", "/pullRequest/merge", `{"pull_request_id": "pr-1"}`)
+
+	for _, id := range []string{"pr-2", "pr-3"} {
+		c.expect(201, "POST", "/pullRequest/create", fmt.Sprintf(`{"pull_request_id": %q, "pull_request_name": "Fix", "author_id": "bob"}`, id))
+	}
+	prs := decodePage(t, c.expect(200, "GET", "/v2/pull-requests?limit=2", ""))
+	assert.Len(t, prs.Items, 2)
+	require.NotEmpty(t, prs.NextCursor)
+	prs = decodePage(t, c.expect(200, "GET", "/v2/pull-requests?limit=2&cursor="+prs.NextCursor, ""))
+	assert.Len(t, prs.Items, 1)
+	assert.Empty(t, prs.NextCursor)
+	prs = decodePage(t, c.expect(200, "GET", "/v2/pull-requests?status=OPEN&team_name=backend&author_id=bob", ""))
+	assert.Len(t, prs.Items, 2)
+	prs = decodePage(t, c.expect(200, "GET", "/v2/pull-requests?status=MERGED", ""))
+	assert.Len(t, prs.Items, 1)
+	c.expectInvalid(400, "GET", "/v2/pull-requests?status=CLOSED", "")
+	assert.Contains(t, c.expectInvalid(400, "GET", "/v2/pull-requests?limit=ten", "").Body.String(), `"field":"limit"`)
+	c.expect(400, "GET", "/v2/pull-requests?cursor=garbage", "")
+
+	// Roster changes
+	c.expect(200, "PUT", "/v2/users/dave/team", `{"team_name": "frontend", "hand_off_reviews": true}`)
+	c.expect(404, "PUT", "/v2/users/dave/team", `{"team_name": "missing"}`)
+	c.expect(409, "PUT", "/v2/users/dave/team", `{"team_name": "frontend"}`)
+	c.expect(200, "PATCH", "/v2/teams/backend", `{"add_members": [{"user_id": "heidi", "username": "Heidi", "is_active": true}], "remove_members": ["carol"]}`)
+	c.expect(409, "PATCH", "/v2/teams/backend", `{"remove_members": ["grace"]}`)
+	c.expect(404, "PATCH", "/v2/teams/missing", `{"remove_members": ["grace"]}`)
+	c.expect(200, "GET", "/v2/stats/reviewers", "")
+	c.expect(200, "GET", "/v2/stats/reviewers?team_name=backend", "")
+	c.expect(404, "GET", "/v2/stats/reviewers?team_name=missing", "")
+
+	c.expect(400, "DELETE", "/v2/teams/frontend?policy=REASSIGN", "")
+	c.expectInvalid(400, "DELETE", "/v2/teams/frontend?policy=ARCHIVE", "")
+	c.expect(200, "DELETE", "/v2/teams/frontend?policy=REASSIGN&transfer_to=backend", "")
+	c.expect(404, "DELETE", "/v2/teams/frontend", "")
+	c.expect(404, "GET", "/team/get?team_name=frontend", "")
+}
+
+// TestIdempotencyKeyReleasedOnPanic checks that a handler panicking under an
+// Idempotency-Key does not leave the key claimed.
+func TestIdempotencyKeyReleasedOnPanic(t *testing.T) {
+	router := newRouter(t)
+	calls := 0
+	router.POST("/test/panic", func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		c.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/test/panic", strings.NewReader("{}"))
+		request.Header.Set("Idempotency-Key", "panic-1")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	assert.Equal(t, http.StatusInternalServerError, send().Code)
+	retried := send()
+	assert.Equal(t, http.StatusCreated, retried.Code)
+	assert.Empty(t, retried.Header().Get("Idempotent-Replayed"))
+	replayed := send()
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,56 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"prReviewerAssignment/internal/clock"
+	"sync"
+	"time"
+)
+
+type Job func(ctx context.Context) error
+
+type entry struct {
+	name     string
+	interval time.Duration
+	job      Job
+}
+
+type Scheduler struct {
+	clock   clock.Clock
+	entries []entry
+}
+
+func New(c clock.Clock) *Scheduler {
+	return &Scheduler{clock: c}
+}
+
+func (s *Scheduler) Every(name string, interval time.Duration, job Job) {
+	s.entries = append(s.entries, entry{name: name, interval: interval, job: job})
+}
+
+// Run starts every registered job and blocks until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, e := range s.entries {
+		wg.Add(1)
+		go func(e entry) {
+			defer wg.Done()
+			s.loop(ctx, e)
+		}(e)
+	}
+	wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, e entry) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(e.interval):
+			if err := e.job(ctx); err != nil {
+				log.Printf("scheduled job %s failed: %v", e.name, err)
+			}
+		}
+	}
+}
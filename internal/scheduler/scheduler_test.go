@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/clock"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerRunsJobOnInterval(t *testing.T) {
+	fake := clock.NewFake(time.Date(2025, 11, 24, 10, 0, 0, 0, time.UTC))
+	runs := make(chan time.Time, 10)
+
+	s := New(fake)
+	s.Every("test", time.Minute, func(ctx context.Context) error {
+		runs <- fake.Now()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	fake.BlockUntil(1)
+	fake.Advance(30 * time.Second)
+	select {
+	case <-runs:
+		t.Fatal("job ran before its interval elapsed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	fake.Advance(30 * time.Second)
+	assert.Equal(t, time.Date(2025, 11, 24, 10, 1, 0, 0, time.UTC), <-runs)
+
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	assert.Equal(t, time.Date(2025, 11, 24, 10, 2, 0, 0, time.UTC), <-runs)
+
+	cancel()
+	<-done
+}
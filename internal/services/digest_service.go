@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"prReviewerAssignment/internal/clock"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/repository"
+	"prReviewerAssignment/internal/workhours"
+	"time"
+)
+
+type DigestService struct {
+	store    repository.Store
+	notifier notify.Notifier
+	clock    clock.Clock
+}
+
+func NewDigestService() *DigestService {
+	return &DigestService{
+		store:    db.Store,
+		notifier: notify.Default,
+		clock:    clock.Real{},
+	}
+}
+
+// SendDigests delivers a digest of pending reviews to every active member of
+// a team with a digest schedule once their local digest time has passed and
+// they are outside the team's quiet hours. Users without OPEN reviews get
+// nothing, and members of several teams get a single digest a day.
+func (s *DigestService) SendDigests(ctx context.Context) error {
+	allTeams, err := s.store.Teams().List(repository.TeamQuery{})
+	if err != nil {
+		return err
+	}
+	var teams []models.TeamDB
+	for _, team := range allTeams {
+		if team.DigestTime != "" {
+			teams = append(teams, team)
+		}
+	}
+	if len(teams) == 0 {
+		return nil
+	}
+
+	openPRs, err := s.store.PullRequests().List(repository.PullRequestQuery{Status: "OPEN"})
+	if err != nil {
+		return err
+	}
+	reviews := reviewsByUser(openPRs)
+
+	now := s.clock.Now()
+	var errs []error
+	for _, team := range teams {
+		users, err := s.store.Users().ActiveMembers([]string{team.TeamName})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, user := range users {
+			if len(reviews[user.UserID]) == 0 || !digestDue(now, team, user) {
+				continue
+			}
+			if err := s.send(ctx, team, user, reviews[user.UserID], now); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *DigestService) send(ctx context.Context, team models.TeamDB, user models.User, prs []models.PullRequest, now time.Time) error {
+	event := notify.Event{
+		Type:       notify.EventReviewDigest,
+		TeamName:   team.TeamName,
+		Recipients: []string{user.UserID},
+		OccurredAt: now,
+	}
+	for _, pr := range prs {
+		event.Digest = append(event.Digest, notify.DigestEntry{
+			PullRequestID:   pr.PullRequestID,
+			PullRequestName: pr.PullRequestName,
+			AuthorID:        pr.AuthorID,
+			Age:             now.Sub(pr.CreatedAt),
+		})
+	}
+
+	if err := s.notifier.Notify(ctx, event); err != nil {
+		return err
+	}
+
+	// Only the digest time is written: the user may have changed since it
+	// was read.
+	return s.store.Users().SetLastDigestAt(user.UserID, now)
+}
+
+// digestDue reports whether the user should get a digest at now. Digest and
+// quiet hours are interpreted in the user's own timezone; quiet hours may
+// wrap around midnight.
+func digestDue(now time.Time, team models.TeamDB, user models.User) bool {
+	digestAt, err := workhours.ParseClock(team.DigestTime)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(userWindow(user).Location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	scheduled := midnight.Add(digestAt)
+	if local.Before(scheduled) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	if user.LastDigestAt != nil && !user.LastDigestAt.Before(scheduled) {
+		return false
+	}
+
+	return !inQuietHours(local.Sub(midnight), team)
+}
+
+func inQuietHours(offset time.Duration, team models.TeamDB) bool {
+	if team.QuietStart == "" || team.QuietEnd == "" {
+		return false
+	}
+
+	start, err := workhours.ParseClock(team.QuietStart)
+	if err != nil {
+		return false
+	}
+	end, err := workhours.ParseClock(team.QuietEnd)
+	if err != nil {
+		return false
+	}
+
+	if start <= end {
+		return offset >= start && offset < end
+	}
+	return offset >= start || offset < end
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/clock"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDigestDue(t *testing.T) {
+	// Monday 2025-11-24 05:00 UTC is 08:00 in Moscow.
+	fake := clock.NewFake(time.Date(2025, 11, 24, 5, 0, 0, 0, time.UTC))
+	team := models.TeamDB{TeamName: "backend", DigestTime: "09:00"}
+	user := models.User{UserID: "u1", Timezone: "Europe/Moscow"}
+
+	lastSent := time.Date(2025, 11, 23, 6, 0, 0, 0, time.UTC)
+	user.LastDigestAt = &lastSent
+	assert.False(t, digestDue(fake.Now(), team, user))
+
+	fake.Advance(time.Hour)
+	assert.True(t, digestDue(fake.Now(), team, user))
+
+	sent := fake.Now()
+	user.LastDigestAt = &sent
+	fake.Advance(3 * time.Hour)
+	assert.False(t, digestDue(fake.Now(), team, user))
+
+	fake.Advance(21 * time.Hour)
+	assert.True(t, digestDue(fake.Now(), team, user))
+}
+
+func TestDigestDeferredByQuietHours(t *testing.T) {
+	// 23:30 in Moscow, quiet hours wrap around midnight.
+	fake := clock.NewFake(time.Date(2025, 11, 24, 20, 30, 0, 0, time.UTC))
+	team := models.TeamDB{TeamName: "backend", DigestTime: "23:00", QuietStart: "22:00", QuietEnd: "08:00"}
+	user := models.User{UserID: "u1", Timezone: "Europe/Moscow"}
+
+	assert.False(t, digestDue(fake.Now(), team, user))
+
+	fake.Advance(8 * time.Hour)
+	assert.False(t, digestDue(fake.Now(), team, user))
+
+	fake.Advance(time.Hour)
+	assert.True(t, digestDue(fake.Now(), team, user))
+}
+
+func TestDigestKeepsConcurrentUserChanges(t *testing.T) {
+	useMemoryStore(t)
+	createTestTeam(t, "backend", "u1", "u2")
+	user, err := db.Store.Users().Get("u2")
+	require.NoError(t, err)
+
+	// u2 is deactivated after the digest run read them.
+	_, err = NewUserService().SetUserActive("u2", false)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	notifier := &recordingNotifier{}
+	service := &DigestService{store: db.Store, notifier: notifier, clock: clock.NewFake(now)}
+	require.NoError(t, service.send(context.Background(), models.TeamDB{TeamName: "backend"}, *user, nil, now))
+	assert.Len(t, notifier.events, 1)
+
+	user, err = db.Store.Users().Get("u2")
+	require.NoError(t, err)
+	assert.False(t, user.IsActive)
+	if assert.NotNil(t, user.LastDigestAt) {
+		assert.True(t, user.LastDigestAt.Equal(now))
+	}
+}
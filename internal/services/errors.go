@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"prReviewerAssignment/internal/models"
+	"strings"
+)
+
+// Codes of the error catalog. Every error a service reports to API clients
+// is an *Error with one of these codes; anything else is an internal error.
+const (
+	CodeValidation            = "VALIDATION_ERROR"
+	CodeInvalidQuery          = "INVALID_QUERY"
+	CodeInvalidDocument       = "INVALID_DOCUMENT"
+	CodeInvalidReason         = "INVALID_REASON"
+	CodeInvalidIdempotencyKey = "INVALID_IDEMPOTENCY_KEY"
+	CodeNotTeamLead           = "NOT_TEAM_LEAD"
+	CodeNotFound              = "NOT_FOUND"
+	CodeTeamExists            = "TEAM_EXISTS"
+	CodePRExists              = "PR_EXISTS"
+	CodeUserExists            = "USER_EXISTS"
+	CodePRMerged              = "PR_MERGED"
+	CodeNotAssigned           = "NOT_ASSIGNED"
+	CodeNoCandidate           = "NO_CANDIDATE"
+	CodeAlreadyAssigned       = "ALREADY_ASSIGNED"
+	CodeInvalidReviewer       = "INVALID_REVIEWER"
+	CodeNotTeamMember         = "NOT_TEAM_MEMBER"
+	CodeReviewerLimit         = "REVIEWER_LIMIT"
+	CodeReviewDeclined        = "REVIEW_DECLINED"
+	CodeTeamHasOpenPRs        = "TEAM_HAS_OPEN_PRS"
+	CodeAlreadyMember         = "ALREADY_MEMBER"
+	CodeIdempotencyInProgress = "IDEMPOTENCY_IN_PROGRESS"
+	CodeVersionMismatch       = "VERSION_MISMATCH"
+	CodeIdempotencyKeyReused  = "IDEMPOTENCY_KEY_REUSED"
+	CodeInternal              = "INTERNAL"
+)
+
+// Error is an error reported to API clients. Details name the offending
+// fields of an invalid request.
+type Error struct {
+	Code    string
+	Message string
+	Details []models.FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// InvalidField reports a problem with one field of a request, such as
+// "must not be empty", under code.
+func InvalidField(code, field, problem string) *Error {
+	return Invalid(code, []models.FieldError{{Field: field, Message: problem}})
+}
+
+// Invalid reports the problems with the fields of a request under code. The
+// message lists them all.
+func Invalid(code string, details []models.FieldError) *Error {
+	problems := make([]string, 0, len(details))
+	for _, detail := range details {
+		problems = append(problems, strings.TrimSpace(detail.Field+" "+detail.Message))
+	}
+	return &Error{Code: code, Message: strings.Join(problems, "; "), Details: details}
+}
+
+// fieldErrors collects the problems found validating a request.
+type fieldErrors []models.FieldError
+
+func (e *fieldErrors) add(field, problem string) {
+	*e = append(*e, models.FieldError{Field: field, Message: problem})
+}
+
+// err reports the problems as a VALIDATION_ERROR, or returns nil when there
+// are none.
+func (e fieldErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return Invalid(CodeValidation, e)
+}
+
+// HasCode reports whether err is an *Error with code.
+func HasCode(err error, code string) bool {
+	var serviceError *Error
+	return errors.As(err, &serviceError) && serviceError.Code == code
+}
+
+var (
+	ErrPRNotFound            = newError(CodeNotFound, "PR not found")
+	ErrTeamNotFound          = newError(CodeNotFound, "team not found")
+	ErrUserNotFound          = newError(CodeNotFound, "user not found")
+	ErrParentTeamNotFound    = newError(CodeNotFound, "parent team not found")
+	ErrTransferTeamNotFound  = newError(CodeNotFound, "transfer_to team not found")
+	ErrAuthorNotFound        = newError(CodeNotFound, "author not found or inactive")
+	ErrReviewerNotFound      = newError(CodeNotFound, "reviewer not found or inactive")
+	ErrTeamExists            = newError(CodeTeamExists, "team already exists")
+	ErrPRExists              = newError(CodePRExists, "PR already exists")
+	ErrUserExists            = newError(CodeUserExists, "user already exists")
+	ErrPRMerged              = newError(CodePRMerged, "cannot change a merged PR")
+	ErrNotAssigned           = newError(CodeNotAssigned, "reviewer is not assigned to this PR")
+	ErrNoCandidate           = newError(CodeNoCandidate, "no active replacement candidate in team")
+	ErrAlreadyAssigned       = newError(CodeAlreadyAssigned, "reviewer is already assigned to this PR")
+	ErrAuthorIsReviewer      = newError(CodeInvalidReviewer, "author cannot review own PR")
+	ErrReviewDeclined        = newError(CodeReviewDeclined, "reviewer has declined this PR")
+	ErrAuthorNotTeamMember   = newError(CodeNotTeamMember, "author is not a member of the team")
+	ErrReviewerNotTeamMember = newError(CodeNotTeamMember, "reviewer is not a member of the PR team")
+	ErrNotTeamMember         = newError(CodeNotTeamMember, "user is not a member of the team")
+	ErrMaxReviewers          = newError(CodeReviewerLimit, "team maximum reviewers reached")
+	ErrMinReviewers          = newError(CodeReviewerLimit, "team minimum reviewers reached")
+	ErrNotTeamLead           = newError(CodeNotTeamLead, "override_approved_by must be an active lead of the PR team")
+	ErrTeamHasOpenPRs        = newError(CodeTeamHasOpenPRs, "team members still take part in open pull requests")
+	ErrAlreadyMember         = newError(CodeAlreadyMember, "user is already a member of the team")
+	ErrVersionMismatch       = newError(CodeVersionMismatch, "PR was changed since the version in If-Match")
+	ErrIdempotencyKeyReused  = newError(CodeIdempotencyKeyReused, "Idempotency-Key was already used for a different request")
+	ErrIdempotencyInProgress = newError(CodeIdempotencyInProgress, "a request with this Idempotency-Key is still being handled")
+
+	ErrInvalidDeclineReason = InvalidField(CodeInvalidReason, "reason", "must be one of BUSY, CONFLICT_OF_INTEREST, LACKS_CONTEXT")
+	ErrInvalidDeletePolicy  = InvalidField(CodeValidation, "policy", "must be BLOCK, REASSIGN with transfer_to, or CASCADE")
+
+	ErrInvalidFilter        = InvalidField(CodeInvalidQuery, "filter", `must be attribute eq "value" on userName or displayName`)
+	ErrInvalidPatch         = InvalidField(CodeValidation, "Operations", "contain an unsupported or malformed operation")
+	ErrUserNameRequired     = InvalidField(CodeValidation, "userName", "is required")
+	ErrUserNameImmutable    = InvalidField(CodeValidation, "userName", "cannot be changed")
+	ErrDisplayNameRequired  = InvalidField(CodeValidation, "displayName", "is required")
+	ErrDisplayNameImmutable = InvalidField(CodeValidation, "displayName", "cannot be changed")
+	ErrInvalidTimezone      = InvalidField(CodeValidation, "timezone", "must be an IANA name")
+	ErrUnknownMember        = InvalidField(CodeValidation, "members", "must all be provisioned users")
+)
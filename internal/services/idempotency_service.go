@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/repository"
+	"time"
+)
+
+// pendingTimeout is how long a claim on a key lasts unless Hold renews it.
+// A request keeps its key claimed for as long as it runs, however long that
+// is; the timeout only frees keys claimed by a process that died while
+// handling the request, so that a retry may claim them again.
+const pendingTimeout = time.Minute
+
+// IdempotencyService remembers the responses to requests sent with an
+// Idempotency-Key so that retries get the first response back instead of
+// repeating the change.
+type IdempotencyService struct {
+	store          repository.Store
+	ttl            time.Duration
+	pendingTimeout time.Duration
+}
+
+func NewIdempotencyService(ttl time.Duration) *IdempotencyService {
+	return &IdempotencyService{store: db.Store, ttl: ttl, pendingTimeout: pendingTimeout}
+}
+
+// Begin claims key for the request identified by fingerprint. It returns the
+// stored response when the key was used for the same request before, and
+// nil when the caller is to handle the request and report its response to
+// Finish or Abandon.
+func (s *IdempotencyService) Begin(key string, fingerprint string) (*models.IdempotencyKey, error) {
+	var replay *models.IdempotencyKey
+	err := s.store.Transaction(func(tx repository.Store) error {
+		now := time.Now()
+		record, err := tx.IdempotencyKeys().Get(key)
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+		case err != nil:
+			return err
+		case record.ExpiresAt.Before(now):
+			if err := tx.IdempotencyKeys().Delete(key); err != nil {
+				return err
+			}
+		case record.Fingerprint != fingerprint:
+			return ErrIdempotencyKeyReused
+		case record.StatusCode == 0:
+			return ErrIdempotencyInProgress
+		default:
+			replay = record
+			return nil
+		}
+
+		err = tx.IdempotencyKeys().Create(&models.IdempotencyKey{
+			Key:         key,
+			Fingerprint: fingerprint,
+			ExpiresAt:   now.Add(s.pendingTimeout),
+		})
+		if errors.Is(err, repository.ErrDuplicate) {
+			return ErrIdempotencyInProgress
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return replay, nil
+}
+
+// Hold keeps key claimed by renewing the claim well before it times out,
+// until the returned function is called. Callers hold the key while they
+// handle the request and release it before Finish or Abandon.
+func (s *IdempotencyService) Hold(key string) (release func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(s.pendingTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := s.renew(key); err != nil {
+					log.Printf("failed to renew the claim on idempotency key %q: %v", key, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// renew pushes back the timeout of the claim on key while no response is
+// stored for it.
+func (s *IdempotencyService) renew(key string) error {
+	return s.store.Transaction(func(tx repository.Store) error {
+		record, err := tx.IdempotencyKeys().Get(key)
+		if err != nil {
+			return err
+		}
+		if record.StatusCode != 0 {
+			return nil
+		}
+		record.ExpiresAt = time.Now().Add(s.pendingTimeout)
+		return tx.IdempotencyKeys().Update(record)
+	})
+}
+
+// Finish stores the response to the request that claimed key for replay
+// until the TTL runs out.
+func (s *IdempotencyService) Finish(key string, statusCode int, headers []byte, body []byte) error {
+	record, err := s.store.IdempotencyKeys().Get(key)
+	if err != nil {
+		return err
+	}
+
+	record.StatusCode = statusCode
+	record.ResponseHeaders = headers
+	record.ResponseBody = body
+	record.ExpiresAt = time.Now().Add(s.ttl)
+	return s.store.IdempotencyKeys().Update(record)
+}
+
+// Abandon releases key without storing a response, so that a retry is
+// handled afresh.
+func (s *IdempotencyService) Abandon(key string) error {
+	err := s.store.IdempotencyKeys().Delete(key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// PurgeExpired deletes the keys whose responses are no longer replayed.
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) error {
+	deleted, err := s.store.IdempotencyKeys().DeleteExpired(time.Now())
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		log.Printf("purged %d expired idempotency keys", deleted)
+	}
+	return nil
+}
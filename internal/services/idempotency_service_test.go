@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	useMemoryStore(t)
+	service := NewIdempotencyService(time.Hour)
+
+	record, err := service.Begin("k1", "create pr-1")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	_, err = service.Begin("k1", "create pr-1")
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+
+	require.NoError(t, service.Finish("k1", 201, []byte(`{"ETag":"\"1\""}`), []byte(`{"pr":{}}`)))
+	record, err = service.Begin("k1", "create pr-1")
+	require.NoError(t, err)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, 201, record.StatusCode)
+		assert.Equal(t, `{"pr":{}}`, string(record.ResponseBody))
+		assert.True(t, record.ExpiresAt.After(time.Now().Add(59*time.Minute)))
+	}
+
+	_, err = service.Begin("k1", "create pr-2")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	// Abandoned and expired keys can be claimed again, even for another
+	// request.
+	record, err = service.Begin("k2", "reassign")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+	require.NoError(t, service.Abandon("k2"))
+	record, err = service.Begin("k2", "reassign")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	require.NoError(t, db.Store.IdempotencyKeys().Create(&models.IdempotencyKey{
+		Key:         "k3",
+		Fingerprint: "merge",
+		StatusCode:  200,
+		ExpiresAt:   time.Now().Add(-time.Second),
+	}))
+	record, err = service.Begin("k3", "decline")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	require.NoError(t, db.Store.IdempotencyKeys().Create(&models.IdempotencyKey{Key: "k4", Fingerprint: "old", ExpiresAt: time.Now().Add(-time.Second)}))
+	require.NoError(t, service.PurgeExpired(context.Background()))
+	_, err = db.Store.IdempotencyKeys().Get("k4")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	_, err = db.Store.IdempotencyKeys().Get("k1")
+	assert.NoError(t, err)
+}
+
+func TestIdempotencyKeyHeldWhileHandled(t *testing.T) {
+	useMemoryStore(t)
+	service := NewIdempotencyService(time.Hour)
+	service.pendingTimeout = 30 * time.Millisecond
+
+	record, err := service.Begin("k1", "create pr-1")
+	require.NoError(t, err)
+	require.Nil(t, record)
+
+	// The first request is still running long after the claim would have
+	// timed out on its own, so the retry must not be handled again.
+	release := service.Hold("k1")
+	time.Sleep(5 * service.pendingTimeout)
+	_, err = service.Begin("k1", "create pr-1")
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+
+	release()
+	require.NoError(t, service.Finish("k1", 201, []byte(`{}`), []byte(`{"pr":{}}`)))
+	record, err = service.Begin("k1", "create pr-1")
+	require.NoError(t, err)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, 201, record.StatusCode)
+	}
+
+	// A claim nobody holds any more, as when the process died while handling
+	// the request, times out and can be claimed again.
+	record, err = service.Begin("k2", "reassign")
+	require.NoError(t, err)
+	require.Nil(t, record)
+	time.Sleep(2 * service.pendingTimeout)
+	record, err = service.Begin("k2", "reassign")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
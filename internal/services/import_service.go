@@ -0,0 +1,693 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImportFormatCSV  = "csv"
+	ImportFormatYAML = "yaml"
+)
+
+var importCSVColumns = []string{
+	"team_name", "parent_team_name", "user_id", "username", "is_active", "role",
+	"email", "chat_handle", "timezone", "work_start", "work_end",
+}
+
+type ImportService struct {
+	store       repository.Store
+	teamService *TeamService
+}
+
+func NewImportService() *ImportService {
+	return &ImportService{store: db.Store, teamService: NewTeamService()}
+}
+
+// importPlan is a validated import document: the teams in the order they
+// have to be written, parents first, and the changes writing them makes.
+type importPlan struct {
+	teams    []models.Team
+	existing map[string]bool
+	changes  []models.RosterChange
+}
+
+// Import validates the whole document against the current teams and users
+// and returns the changes it makes. Unless dryRun is set the changes are
+// applied in a single transaction. Import only creates and updates: teams,
+// members and fields missing from the document are left as they are.
+func (s *ImportService) Import(format string, data []byte, dryRun bool) (*models.ImportResponse, error) {
+	teams, err := parseImport(format, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *models.ImportResponse
+	err = s.store.Transaction(func(tx repository.Store) error {
+		plan, err := s.plan(tx, teams)
+		if err != nil {
+			return err
+		}
+
+		response = &models.ImportResponse{DryRun: dryRun, Changes: plan.changes}
+		if response.Changes == nil {
+			response.Changes = []models.RosterChange{}
+		}
+		if dryRun {
+			return nil
+		}
+
+		return s.apply(tx, plan)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// IsInvalidRoster reports whether err describes a problem with an import or
+// reconcile document rather than a failure to apply it.
+func IsInvalidRoster(err error) bool {
+	return HasCode(err, CodeInvalidDocument)
+}
+
+// invalidRoster reports the problems found in a document, one detail each.
+func invalidRoster(problems []string) error {
+	details := make([]models.FieldError, 0, len(problems))
+	for _, problem := range problems {
+		details = append(details, models.FieldError{Message: problem})
+	}
+	err := Invalid(CodeInvalidDocument, details)
+	err.Message = "invalid roster document: " + err.Message
+	return err
+}
+
+// importTeam is a team of an import document. The reviewer and SLA settings
+// are also kept as pointers, nil when the document leaves them out.
+type importTeam struct {
+	models.Team
+	minReviewers, maxReviewers, slaHours *int
+}
+
+// parseImport decodes a YAML or CSV document into teams. Members without
+// is_active come out active.
+func parseImport(format string, data []byte) ([]importTeam, error) {
+	var document models.ImportDocument
+	var err error
+	switch format {
+	case ImportFormatYAML:
+		document, err = parseImportYAML(data)
+	case ImportFormatCSV:
+		document, err = parseImportCSV(data)
+	default:
+		return nil, invalidRoster([]string{"unsupported format " + strconv.Quote(format)})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var teams []importTeam
+	for _, documentTeam := range document.Teams {
+		team := importTeam{
+			Team:         documentTeam.Team,
+			minReviewers: documentTeam.MinReviewers,
+			maxReviewers: documentTeam.MaxReviewers,
+			slaHours:     documentTeam.SLAHours,
+		}
+		team.MinReviewers = valueOrZero(documentTeam.MinReviewers)
+		team.MaxReviewers = valueOrZero(documentTeam.MaxReviewers)
+		team.SLAHours = valueOrZero(documentTeam.SLAHours)
+		team.Members = nil
+		for _, importMember := range documentTeam.Members {
+			member := importMember.TeamMember
+			member.IsActive = importMember.IsActive == nil || *importMember.IsActive
+			team.Members = append(team.Members, member)
+		}
+		teams = append(teams, team)
+	}
+
+	return teams, nil
+}
+
+func valueOrZero(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// documentTeams drops what importTeam adds to the teams of a document.
+func documentTeams(teams []importTeam) []models.Team {
+	result := make([]models.Team, len(teams))
+	for i, team := range teams {
+		result[i] = team.Team
+	}
+	return result
+}
+
+// parseImportYAML decodes YAML through JSON so the document uses the same
+// field names as the API.
+func parseImportYAML(data []byte) (models.ImportDocument, error) {
+	var document models.ImportDocument
+
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return document, invalidRoster([]string{err.Error()})
+	}
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return document, invalidRoster([]string{err.Error()})
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&document); err != nil {
+		return document, invalidRoster([]string{strings.TrimPrefix(err.Error(), "json: ")})
+	}
+
+	return document, nil
+}
+
+// parseImportCSV reads one member per row. The header names the columns;
+// team_name, user_id and username are required. Rows of the same team are
+// grouped in the order the teams first appear.
+func parseImportCSV(data []byte) (models.ImportDocument, error) {
+	var document models.ImportDocument
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return document, nil
+	} else if err != nil {
+		return document, invalidRoster([]string{err.Error()})
+	}
+
+	columns := make(map[string]int)
+	var problems []string
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, column := range importCSVColumns {
+			known = known || column == name
+		}
+		if !known {
+			problems = append(problems, "unknown column "+strconv.Quote(name))
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"team_name", "user_id", "username"} {
+		if _, ok := columns[name]; !ok {
+			problems = append(problems, "missing column "+strconv.Quote(name))
+		}
+	}
+	if len(problems) > 0 {
+		return document, invalidRoster(problems)
+	}
+
+	teamIndex := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return document, invalidRoster([]string{err.Error()})
+		}
+		line, _ := reader.FieldPos(0)
+
+		value := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		member := models.ImportMember{
+			TeamMember: models.TeamMember{
+				UserID:     value("user_id"),
+				Username:   value("username"),
+				Role:       value("role"),
+				Email:      value("email"),
+				ChatHandle: value("chat_handle"),
+				Timezone:   value("timezone"),
+				WorkStart:  value("work_start"),
+				WorkEnd:    value("work_end"),
+			},
+		}
+		if raw := value("is_active"); raw != "" {
+			isActive, err := strconv.ParseBool(raw)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("line %d: invalid is_active %q", line, raw))
+				continue
+			}
+			member.IsActive = &isActive
+		}
+
+		teamName := value("team_name")
+		i, ok := teamIndex[teamName]
+		if !ok {
+			i = len(document.Teams)
+			teamIndex[teamName] = i
+			document.Teams = append(document.Teams, models.ImportTeam{Team: models.Team{TeamName: teamName}})
+		}
+
+		team := &document.Teams[i]
+		if parent := value("parent_team_name"); parent != "" {
+			if team.ParentTeamName != "" && team.ParentTeamName != parent {
+				problems = append(problems, fmt.Sprintf("line %d: conflicting parent_team_name for team %q", line, teamName))
+				continue
+			}
+			team.ParentTeamName = parent
+		}
+		team.Members = append(team.Members, member)
+	}
+	if len(problems) > 0 {
+		return document, invalidRoster(problems)
+	}
+
+	return document, nil
+}
+
+// plan validates every team of the document as it would look after the
+// import and collects the changes. All problems are reported together.
+func (s *ImportService) plan(tx repository.Store, teams []importTeam) (*importPlan, error) {
+	order, current, problems, err := orderTeams(tx, documentTeams(teams))
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, invalidRoster(problems)
+	}
+
+	plan := &importPlan{existing: make(map[string]bool)}
+	users, memberships, err := importState(tx, documentTeams(teams))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range order {
+		team := teams[i].Team
+		existing, exists := current[team.TeamName]
+		var problem error
+		if exists {
+			members, err := teamMembers(tx, team.TeamName)
+			if err != nil {
+				return nil, err
+			}
+			plan.existing[team.TeamName] = true
+			team, problem = planTeamUpdate(plan, existing, members, teams[i])
+		} else {
+			applyTeamDefaults(&team)
+			problem = validateTeam(team)
+			plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterCreateTeam, TeamName: team.TeamName})
+		}
+		if problem != nil {
+			problems = append(problems, fmt.Sprintf("teams[%d] (%s): %s", i, team.TeamName, problem.Error()))
+			continue
+		}
+
+		plan.changes = append(plan.changes, planMembers(team, users, memberships)...)
+		plan.teams = append(plan.teams, team)
+	}
+	if len(problems) > 0 {
+		return nil, invalidRoster(problems)
+	}
+
+	return plan, nil
+}
+
+// orderTeams checks the names and parents of a document's teams against the
+// current teams. It returns the document indexes with every parent ahead of
+// its sub-teams, the current teams by name and the problems found.
+func orderTeams(tx repository.Store, teams []models.Team) ([]int, map[string]models.TeamDB, []string, error) {
+	var problems []string
+	byName := make(map[string]int)
+	for i, team := range teams {
+		switch _, duplicate := byName[team.TeamName]; {
+		case team.TeamName == "":
+			problems = append(problems, fmt.Sprintf("teams[%d]: missing team_name", i))
+		case duplicate:
+			problems = append(problems, fmt.Sprintf("teams[%d]: duplicate team %q", i, team.TeamName))
+		default:
+			byName[team.TeamName] = i
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, problems, nil
+	}
+
+	existingTeams, err := tx.Teams().List(repository.TeamQuery{})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	current := make(map[string]models.TeamDB)
+	parents := make(map[string]string)
+	for _, team := range existingTeams {
+		current[team.TeamName] = team
+		parents[team.TeamName] = team.ParentTeamName
+	}
+	for i, team := range teams {
+		if team.ParentTeamName == "" {
+			continue
+		}
+		_, inDocument := byName[team.ParentTeamName]
+		if _, exists := current[team.ParentTeamName]; !exists && !inDocument {
+			problems = append(problems, fmt.Sprintf("teams[%d] (%s): parent team %q not found", i, team.TeamName, team.ParentTeamName))
+			continue
+		}
+		parents[team.TeamName] = team.ParentTeamName
+	}
+	for i, team := range teams {
+		visited := map[string]bool{team.TeamName: true}
+		for parent := parents[team.TeamName]; parent != ""; parent = parents[parent] {
+			if visited[parent] {
+				problems = append(problems, fmt.Sprintf("teams[%d] (%s): parent_team_name creates a cycle", i, team.TeamName))
+				break
+			}
+			visited[parent] = true
+		}
+	}
+	if len(problems) > 0 {
+		return nil, nil, problems, nil
+	}
+
+	var order []int
+	ordered := make(map[string]bool)
+	var visit func(i int)
+	visit = func(i int) {
+		team := teams[i]
+		if ordered[team.TeamName] {
+			return
+		}
+		ordered[team.TeamName] = true
+		if j, ok := byName[team.ParentTeamName]; ok {
+			visit(j)
+		}
+		order = append(order, i)
+	}
+	for i := range teams {
+		visit(i)
+	}
+
+	return order, current, nil, nil
+}
+
+// planTeamUpdate merges the document into an existing team with its current
+// members, validates the result and records the changed settings. The
+// returned team holds the merged reviewer and SLA settings and only the
+// document's members, with lead_user_id folded into their roles.
+func planTeamUpdate(plan *importPlan, existing models.TeamDB, members []models.TeamMember, document importTeam) (models.Team, error) {
+	team := document.Team
+	merged := models.Team{
+		TeamName:       existing.TeamName,
+		ParentTeamName: existing.ParentTeamName,
+		MinReviewers:   existing.MinReviewers,
+		MaxReviewers:   existing.MaxReviewers,
+		SLAHours:       existing.SLAHours,
+		SLAPolicy:      existing.SLAPolicy,
+		Strategy:       existing.Strategy,
+		DigestTime:     existing.DigestTime,
+		QuietStart:     existing.QuietStart,
+		QuietEnd:       existing.QuietEnd,
+		ChatWebhook:    existing.ChatWebhook,
+		Members:        members,
+	}
+
+	var fields []string
+	set := func(field string, target *string, value string) {
+		if value != "" && value != *target {
+			*target = value
+			fields = append(fields, field)
+		}
+	}
+	setInt := func(field string, target *int, value *int) {
+		if value != nil && *value != *target {
+			*target = *value
+			fields = append(fields, field)
+		}
+	}
+	set("parent_team_name", &merged.ParentTeamName, team.ParentTeamName)
+	setInt("min_reviewers", &merged.MinReviewers, document.minReviewers)
+	setInt("max_reviewers", &merged.MaxReviewers, document.maxReviewers)
+	setInt("sla_hours", &merged.SLAHours, document.slaHours)
+	set("sla_policy", &merged.SLAPolicy, team.SLAPolicy)
+	set("assignment_strategy", &merged.Strategy, team.Strategy)
+	set("digest_time", &merged.DigestTime, team.DigestTime)
+	set("quiet_hours_start", &merged.QuietStart, team.QuietStart)
+	set("quiet_hours_end", &merged.QuietEnd, team.QuietEnd)
+	set("chat_webhook_url", &merged.ChatWebhook, team.ChatWebhook)
+
+	for i, member := range team.Members {
+		if member.UserID == team.LeadUserID {
+			team.Members[i].Role = models.MembershipRoleLead
+		}
+	}
+	for _, member := range team.Members {
+		found := false
+		for j, currentMember := range merged.Members {
+			if currentMember.UserID == member.UserID {
+				found = true
+				if member.Role != "" {
+					merged.Members[j].Role = member.Role
+				}
+				if member.Timezone != "" {
+					merged.Members[j].Timezone = member.Timezone
+				}
+				if member.WorkStart != "" {
+					merged.Members[j].WorkStart = member.WorkStart
+				}
+				if member.WorkEnd != "" {
+					merged.Members[j].WorkEnd = member.WorkEnd
+				}
+				if member.Capacity != 0 {
+					merged.Members[j].Capacity = member.Capacity
+				}
+				if member.OOOFrom != nil {
+					merged.Members[j].OOOFrom = member.OOOFrom
+				}
+				if member.OOOUntil != nil {
+					merged.Members[j].OOOUntil = member.OOOUntil
+				}
+			}
+		}
+		if !found {
+			if member.Role == "" {
+				member.Role = models.MembershipRoleMember
+			}
+			merged.Members = append(merged.Members, member)
+		}
+	}
+	merged.LeadUserID = team.LeadUserID
+	team.MinReviewers = merged.MinReviewers
+	team.MaxReviewers = merged.MaxReviewers
+	team.SLAHours = merged.SLAHours
+
+	if err := validateTeam(merged); err != nil {
+		return team, err
+	}
+
+	if len(fields) > 0 {
+		plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterUpdateTeam, TeamName: team.TeamName, Fields: fields})
+	}
+
+	return team, nil
+}
+
+// importState loads the users and memberships the document refers to.
+func importState(tx repository.Store, teams []models.Team) (map[string]models.User, map[string]models.TeamMembership, error) {
+	var userIDs []string
+	for _, team := range teams {
+		for _, member := range team.Members {
+			userIDs = append(userIDs, member.UserID)
+		}
+	}
+	users := make(map[string]models.User)
+	memberships := make(map[string]models.TeamMembership)
+	if len(userIDs) == 0 {
+		return users, memberships, nil
+	}
+
+	existingUsers, err := tx.Users().Find(userIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, user := range existingUsers {
+		users[user.UserID] = user
+	}
+
+	existingMemberships, err := tx.Teams().Memberships(repository.MembershipQuery{UserIDs: userIDs})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, membership := range existingMemberships {
+		memberships[membership.TeamName+"/"+membership.UserID] = membership
+	}
+
+	return users, memberships, nil
+}
+
+// planMembers records the user and membership changes of one team and
+// updates users and memberships as if they were applied, so a user listed
+// in several teams is created once.
+func planMembers(team models.Team, users map[string]models.User, memberships map[string]models.TeamMembership) []models.RosterChange {
+	var changes []models.RosterChange
+	for _, member := range team.Members {
+		user, exists := users[member.UserID]
+		if !exists {
+			changes = append(changes, models.RosterChange{Action: models.RosterCreateUser, TeamName: team.TeamName, UserID: member.UserID})
+			user = models.User{UserID: member.UserID, IsActive: member.IsActive}
+		}
+
+		var fields []string
+		set := func(field string, target *string, value string) {
+			if value != "" && value != *target {
+				*target = value
+				fields = append(fields, field)
+			}
+		}
+		set("username", &user.Username, member.Username)
+		set("email", &user.Email, member.Email)
+		set("chat_handle", &user.ChatHandle, member.ChatHandle)
+		set("timezone", &user.Timezone, member.Timezone)
+		set("work_start", &user.WorkStart, member.WorkStart)
+		set("work_end", &user.WorkEnd, member.WorkEnd)
+		if member.Capacity != 0 && member.Capacity != user.Capacity {
+			user.Capacity = member.Capacity
+			fields = append(fields, "capacity")
+		}
+		if member.OOOFrom != nil && !sameTime(member.OOOFrom, user.OOOFrom) {
+			user.OOOFrom = member.OOOFrom
+			fields = append(fields, "ooo_from")
+		}
+		if member.OOOUntil != nil && !sameTime(member.OOOUntil, user.OOOUntil) {
+			user.OOOUntil = member.OOOUntil
+			fields = append(fields, "ooo_until")
+		}
+		if member.IsActive && !user.IsActive {
+			user.IsActive = true
+			fields = append(fields, "is_active")
+		}
+		if exists && len(fields) > 0 {
+			changes = append(changes, models.RosterChange{Action: models.RosterUpdateUser, TeamName: team.TeamName, UserID: member.UserID, Fields: fields})
+		}
+		users[member.UserID] = user
+
+		key := team.TeamName + "/" + member.UserID
+		membership, exists := memberships[key]
+		if !exists {
+			membership = models.TeamMembership{TeamName: team.TeamName, UserID: member.UserID, Role: member.Role, IsActive: member.IsActive}
+			if membership.Role == "" {
+				membership.Role = models.MembershipRoleMember
+			}
+			changes = append(changes, models.RosterChange{Action: models.RosterAddMember, TeamName: team.TeamName, UserID: member.UserID})
+		} else {
+			fields = nil
+			if member.Role != "" && member.Role != membership.Role {
+				membership.Role = member.Role
+				fields = append(fields, "role")
+			}
+			if member.IsActive != membership.IsActive {
+				membership.IsActive = member.IsActive
+				fields = append(fields, "is_active")
+			}
+			if len(fields) > 0 {
+				changes = append(changes, models.RosterChange{Action: models.RosterUpdateMember, TeamName: team.TeamName, UserID: member.UserID, Fields: fields})
+			}
+		}
+		memberships[key] = membership
+	}
+
+	return changes
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// apply writes the planned teams, parents first, and upserts their members
+// the same way creating a team does.
+func (s *ImportService) apply(tx repository.Store, plan *importPlan) error {
+	for _, team := range plan.teams {
+		if plan.existing[team.TeamName] {
+			existing, err := tx.Teams().Get(team.TeamName)
+			if err != nil {
+				return err
+			}
+			mergeTeamSettings(existing, team)
+			if err := tx.Teams().Update(existing); err != nil {
+				return err
+			}
+		} else {
+			teamDB := models.TeamDB{
+				TeamName:       team.TeamName,
+				ParentTeamName: team.ParentTeamName,
+				MinReviewers:   team.MinReviewers,
+				MaxReviewers:   team.MaxReviewers,
+				SLAHours:       team.SLAHours,
+				SLAPolicy:      team.SLAPolicy,
+				Strategy:       team.Strategy,
+				DigestTime:     team.DigestTime,
+				QuietStart:     team.QuietStart,
+				QuietEnd:       team.QuietEnd,
+				ChatWebhook:    team.ChatWebhook,
+			}
+			if err := tx.Teams().Create(&teamDB); err != nil {
+				return err
+			}
+		}
+
+		for _, member := range team.Members {
+			if err := s.teamService.upsertUser(tx, member, team.TeamName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// mergeTeamSettings copies the settings the document sets onto an existing
+// team; settings left empty keep their stored value. The reviewer and SLA
+// settings come already merged from planTeamUpdate.
+func mergeTeamSettings(existing *models.TeamDB, team models.Team) {
+	if team.ParentTeamName != "" {
+		existing.ParentTeamName = team.ParentTeamName
+	}
+	existing.MinReviewers = team.MinReviewers
+	existing.MaxReviewers = team.MaxReviewers
+	existing.SLAHours = team.SLAHours
+	if team.SLAPolicy != "" {
+		existing.SLAPolicy = team.SLAPolicy
+	}
+	if team.Strategy != "" {
+		existing.Strategy = team.Strategy
+	}
+	if team.DigestTime != "" {
+		existing.DigestTime = team.DigestTime
+	}
+	if team.QuietStart != "" {
+		existing.QuietStart = team.QuietStart
+	}
+	if team.QuietEnd != "" {
+		existing.QuietEnd = team.QuietEnd
+	}
+	if team.ChatWebhook != "" {
+		existing.ChatWebhook = team.ChatWebhook
+	}
+}
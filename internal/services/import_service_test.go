@@ -0,0 +1,142 @@
+package services
+
+import (
+	"testing"
+
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportYAML(t *testing.T) {
+	document := `
+teams:
+  - team_name: backend
+    parent_team_name: engineering
+    sla_hours: 8
+    members:
+      - user_id: u1
+        username: Alice
+        role: LEAD
+        work_start: "09:00"
+      - user_id: u2
+        username: Bob
+        is_active: false
+`
+	teams, err := parseImport(ImportFormatYAML, []byte(document))
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Team{{
+		TeamName:       "backend",
+		ParentTeamName: "engineering",
+		SLAHours:       8,
+		Members: []models.TeamMember{
+			{UserID: "u1", Username: "Alice", IsActive: true, Role: models.MembershipRoleLead, WorkStart: "09:00"},
+			{UserID: "u2", Username: "Bob", IsActive: false},
+		},
+	}}, documentTeams(teams))
+	assert.Equal(t, 8, *teams[0].slaHours)
+	assert.Nil(t, teams[0].minReviewers)
+
+	_, err = parseImport(ImportFormatYAML, []byte("teams:\n  - team_nmae: backend\n"))
+	assert.Error(t, err)
+	assert.True(t, IsInvalidRoster(err))
+}
+
+func TestParseImportCSV(t *testing.T) {
+	document := "team_name,parent_team_name,user_id,username,is_active,role\n" +
+		"backend,engineering,u1,Alice,,LEAD\n" +
+		"frontend,,u3,Carol,true,\n" +
+		"backend,,u2,Bob,false,OBSERVER\n"
+
+	teams, err := parseImport(ImportFormatCSV, []byte(document))
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Team{
+		{
+			TeamName:       "backend",
+			ParentTeamName: "engineering",
+			Members: []models.TeamMember{
+				{UserID: "u1", Username: "Alice", IsActive: true, Role: models.MembershipRoleLead},
+				{UserID: "u2", Username: "Bob", IsActive: false, Role: models.MembershipRoleObserver},
+			},
+		},
+		{
+			TeamName: "frontend",
+			Members:  []models.TeamMember{{UserID: "u3", Username: "Carol", IsActive: true}},
+		},
+	}, documentTeams(teams))
+
+	for _, invalid := range []string{
+		"team_name,user_id\nbackend,u1\n",
+		"team_name,user_id,username,shoe_size\nbackend,u1,Alice,42\n",
+		"team_name,user_id,username,is_active\nbackend,u1,Alice,maybe\n",
+		"team_name,parent_team_name,user_id,username\nbackend,a,u1,Alice\nbackend,b,u2,Bob\n",
+	} {
+		_, err := parseImport(ImportFormatCSV, []byte(invalid))
+		assert.Error(t, err, invalid)
+		assert.True(t, IsInvalidRoster(err), invalid)
+	}
+}
+
+func TestPlanMembers(t *testing.T) {
+	users := map[string]models.User{
+		"u1": {UserID: "u1", Username: "Alice", IsActive: true},
+	}
+	memberships := map[string]models.TeamMembership{
+		"backend/u1": {TeamName: "backend", UserID: "u1", Role: models.MembershipRoleMember, IsActive: true},
+	}
+	team := models.Team{TeamName: "backend", Members: []models.TeamMember{
+		{UserID: "u1", Username: "Alice A.", IsActive: true, Role: models.MembershipRoleLead},
+		{UserID: "u2", Username: "Bob", IsActive: true},
+	}}
+
+	changes := planMembers(team, users, memberships)
+	assert.Equal(t, []models.RosterChange{
+		{Action: models.RosterUpdateUser, TeamName: "backend", UserID: "u1", Fields: []string{"username"}},
+		{Action: models.RosterUpdateMember, TeamName: "backend", UserID: "u1", Fields: []string{"role"}},
+		{Action: models.RosterCreateUser, TeamName: "backend", UserID: "u2"},
+		{Action: models.RosterAddMember, TeamName: "backend", UserID: "u2"},
+	}, changes)
+
+	assert.Empty(t, planMembers(team, users, memberships))
+}
+
+func TestImportSetsSettingsToZero(t *testing.T) {
+	useMemoryStore(t)
+	_, err := NewTeamService().CreateTeam(models.Team{
+		TeamName:     "backend",
+		MinReviewers: 1,
+		MaxReviewers: 2,
+		SLAHours:     8,
+		Members:      []models.TeamMember{{UserID: "u1", Username: "u1", IsActive: true}},
+	})
+	require.NoError(t, err)
+	service := NewImportService()
+
+	document := []byte("teams:\n  - team_name: backend\n    min_reviewers: 0\n    sla_hours: 0\n")
+	response, err := service.Import(ImportFormatYAML, document, true)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RosterChange{
+		{Action: models.RosterUpdateTeam, TeamName: "backend", Fields: []string{"min_reviewers", "sla_hours"}},
+	}, response.Changes)
+
+	_, err = service.Import(ImportFormatYAML, document, false)
+	require.NoError(t, err)
+	team, err := db.Store.Teams().Get("backend")
+	require.NoError(t, err)
+	assert.Equal(t, 0, team.MinReviewers)
+	assert.Equal(t, 2, team.MaxReviewers)
+	assert.Equal(t, 0, team.SLAHours)
+
+	response, err = service.Import(ImportFormatYAML, []byte("teams:\n  - team_name: backend\n    max_reviewers: 3\n"), false)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RosterChange{
+		{Action: models.RosterUpdateTeam, TeamName: "backend", Fields: []string{"max_reviewers"}},
+	}, response.Changes)
+	team, err = db.Store.Teams().Get("backend")
+	require.NoError(t, err)
+	assert.Equal(t, 0, team.MinReviewers)
+	assert.Equal(t, 3, team.MaxReviewers)
+	assert.Equal(t, 0, team.SLAHours)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"prReviewerAssignment/internal/directory"
+	"prReviewerAssignment/internal/models"
+	"sort"
+)
+
+type LDAPSyncService struct {
+	teamService *TeamService
+	userService *UserService
+	config      directory.Config
+	fetch       func(directory.Config) (*directory.Snapshot, error)
+}
+
+func NewLDAPSyncService(config directory.Config) *LDAPSyncService {
+	return &LDAPSyncService{
+		teamService: NewTeamService(),
+		userService: NewUserService(),
+		config:      config,
+		fetch:       directory.Fetch,
+	}
+}
+
+// ldapTeamChange is what has to happen to one team to match its directory
+// group: members to add or refresh and members to remove.
+type ldapTeamChange struct {
+	TeamName string
+	Create   bool
+	Add      []models.TeamMember
+	Remove   []string
+}
+
+// Sync makes every team named after a directory group match the group: group
+// members become active members of the team with the directory's display name
+// and email, and everyone else is removed from it, handing off their open
+// reviews. Members of these teams who are gone from the directory altogether
+// are also deactivated. Teams without a group are left alone.
+func (s *LDAPSyncService) Sync(ctx context.Context) error {
+	snapshot, err := s.fetch(s.config)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string][]models.TeamMember)
+	for _, group := range snapshot.Groups {
+		team, err := s.teamService.GetTeam(group.Name, false)
+		if errors.Is(err, ErrTeamNotFound) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		current[group.Name] = team.Members
+	}
+
+	changes, departed := planLDAPSync(snapshot, current)
+
+	var errs []error
+	for _, change := range changes {
+		if err := s.applyTeamChange(change); err != nil {
+			errs = append(errs, fmt.Errorf("team %s: %w", change.TeamName, err))
+		}
+	}
+
+	for _, userID := range departed {
+		if _, err := s.userService.SetUserActive(userID, false); err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", userID, err))
+			continue
+		}
+		log.Printf("LDAP sync: deactivated %s, no longer in the directory", userID)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *LDAPSyncService) applyTeamChange(change ldapTeamChange) error {
+	if change.Create {
+		if _, err := s.teamService.CreateTeam(models.Team{TeamName: change.TeamName, Members: change.Add}); err != nil {
+			return err
+		}
+		log.Printf("LDAP sync: created team %s with %d members", change.TeamName, len(change.Add))
+		return nil
+	}
+
+	_, handoffs, err := s.teamService.UpdateTeam(models.UpdateTeamRequest{
+		TeamName:      change.TeamName,
+		AddMembers:    change.Add,
+		RemoveMembers: change.Remove,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("LDAP sync: team %s: %d members added or updated, %d removed, %d reviews handed off",
+		change.TeamName, len(change.Add), len(change.Remove), len(handoffs))
+	return nil
+}
+
+// planLDAPSync compares the directory with the current members of the teams
+// that exist; a group missing from current becomes a new team. It returns
+// the team changes and the sorted ids of members to deactivate because they
+// are no longer directory users.
+func planLDAPSync(snapshot *directory.Snapshot, current map[string][]models.TeamMember) ([]ldapTeamChange, []string) {
+	users := make(map[string]directory.User)
+	for _, user := range snapshot.Users {
+		users[user.UserID] = user
+	}
+
+	var changes []ldapTeamChange
+	departed := make(map[string]bool)
+	for _, group := range snapshot.Groups {
+		members, exists := current[group.Name]
+		change := ldapTeamChange{TeamName: group.Name, Create: !exists}
+
+		existing := make(map[string]models.TeamMember)
+		for _, member := range members {
+			existing[member.UserID] = member
+		}
+
+		inGroup := make(map[string]bool)
+		for _, userID := range group.Members {
+			inGroup[userID] = true
+			desired := directoryMember(users[userID])
+
+			member, ok := existing[userID]
+			if ok && member.IsActive && member.Username == desired.Username && (desired.Email == "" || member.Email == desired.Email) {
+				continue
+			}
+			change.Add = append(change.Add, desired)
+		}
+
+		for _, member := range members {
+			if inGroup[member.UserID] {
+				continue
+			}
+			change.Remove = append(change.Remove, member.UserID)
+			if _, ok := users[member.UserID]; !ok {
+				departed[member.UserID] = true
+			}
+		}
+
+		if change.Create || len(change.Add) > 0 || len(change.Remove) > 0 {
+			changes = append(changes, change)
+		}
+	}
+
+	deactivate := make([]string, 0, len(departed))
+	for userID := range departed {
+		deactivate = append(deactivate, userID)
+	}
+	sort.Strings(deactivate)
+
+	return changes, deactivate
+}
+
+func directoryMember(user directory.User) models.TeamMember {
+	username := user.DisplayName
+	if username == "" {
+		username = user.UserID
+	}
+	return models.TeamMember{
+		UserID:   user.UserID,
+		Username: username,
+		Email:    user.Email,
+		IsActive: true,
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"testing"
+
+	"prReviewerAssignment/internal/directory"
+	"prReviewerAssignment/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanLDAPSync(t *testing.T) {
+	snapshot := &directory.Snapshot{
+		Users: []directory.User{
+			{UserID: "alice", DisplayName: "Alice", Email: "alice@example.org"},
+			{UserID: "bob", DisplayName: "Bob B."},
+			{UserID: "carol"},
+			{UserID: "dave", DisplayName: "Dave"},
+		},
+		Groups: []directory.Group{
+			{Name: "backend", Members: []string{"alice", "bob", "carol"}},
+			{Name: "frontend", Members: []string{"dave"}},
+			{Name: "platform", Members: []string{"alice"}},
+		},
+	}
+	current := map[string][]models.TeamMember{
+		"backend": {
+			{UserID: "alice", Username: "Alice", Email: "alice@example.org", IsActive: true, Role: models.MembershipRoleLead},
+			{UserID: "bob", Username: "Bob", IsActive: true},
+			{UserID: "dave", Username: "Dave", IsActive: true},
+			{UserID: "erin", Username: "Erin", IsActive: true},
+		},
+		"frontend": {
+			{UserID: "dave", Username: "Dave", IsActive: false},
+			{UserID: "erin", Username: "Erin", IsActive: true},
+		},
+	}
+
+	changes, departed := planLDAPSync(snapshot, current)
+
+	assert.Equal(t, []ldapTeamChange{
+		{
+			TeamName: "backend",
+			Add: []models.TeamMember{
+				{UserID: "bob", Username: "Bob B.", IsActive: true},
+				{UserID: "carol", Username: "carol", IsActive: true},
+			},
+			Remove: []string{"dave", "erin"},
+		},
+		{
+			TeamName: "frontend",
+			Add:      []models.TeamMember{{UserID: "dave", Username: "Dave", IsActive: true}},
+			Remove:   []string{"erin"},
+		},
+		{
+			TeamName: "platform",
+			Create:   true,
+			Add:      []models.TeamMember{{UserID: "alice", Username: "Alice", Email: "alice@example.org", IsActive: true}},
+		},
+	}, changes)
+	assert.Equal(t, []string{"erin"}, departed)
+}
+
+func TestPlanLDAPSyncUpToDate(t *testing.T) {
+	snapshot := &directory.Snapshot{
+		Users:  []directory.User{{UserID: "alice", DisplayName: "Alice"}},
+		Groups: []directory.Group{{Name: "backend", Members: []string{"alice"}}},
+	}
+	current := map[string][]models.TeamMember{
+		"backend": {{UserID: "alice", Username: "Alice", Email: "alice@corp.example.org", IsActive: true}},
+	}
+
+	changes, departed := planLDAPSync(snapshot, current)
+	assert.Empty(t, changes)
+	assert.Empty(t, departed)
+}
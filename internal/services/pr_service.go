@@ -1,108 +1,131 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"gorm.io/datatypes"
-	"gorm.io/gorm"
+	"log"
 	"math/rand"
+	"prReviewerAssignment/internal/clock"
 	"prReviewerAssignment/internal/db"
 	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/repository"
+	"prReviewerAssignment/internal/workhours"
+	"sort"
 	"time"
 )
 
 type PRService struct {
-	db *gorm.DB
+	store    repository.Store
+	notifier notify.Notifier
+	clock    clock.Clock
 }
 
 func NewPRService() *PRService {
-	return &PRService{db: db.DB}
+	return &PRService{store: db.Store, notifier: notify.Default, clock: clock.Real{}}
 }
 
 func (s *PRService) CreatePullRequest(request models.CreatePRRequest) (*models.PullRequest, error) {
-	tx := s.db.Begin()
-	if tx.Error != nil {
-		return nil, tx.Error
-	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	var pr models.PullRequest
+	var reviewers []string
+	err := s.store.Transaction(func(tx repository.Store) error {
+		_, err := tx.PullRequests().Get(request.PullRequestID)
+		if err == nil {
+			return ErrPRExists
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
 		}
-	}()
 
-	var existingPR models.PullRequest
-	result := tx.Where("pull_request_id = ?", request.PullRequestID).First(&existingPR)
-	if result.Error == nil {
-		tx.Rollback()
-		return nil, errors.New("PR already exists")
-	} else if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		tx.Rollback()
-		return nil, result.Error
-	}
+		author, err := activeUser(tx, request.AuthorID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrAuthorNotFound
+		} else if err != nil {
+			return err
+		}
 
-	var author models.User
-	result = tx.Where("user_id = ? AND is_active = ?", request.AuthorID, true).First(&author)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		tx.Rollback()
-		return nil, errors.New("author not found or inactive")
-	} else if result.Error != nil {
-		tx.Rollback()
-		return nil, result.Error
-	}
+		teamName := request.TeamName
+		if teamName == "" {
+			teamName = author.TeamName
+		}
 
-	reviewers, err := s.selectReviewers(tx, author.TeamName, author.UserID)
-	if err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+		team, err := s.loadTeam(tx, teamName)
+		if err != nil {
+			return err
+		}
 
-	reviewersJSON, err := json.Marshal(reviewers)
-	if err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+		member, err := isTeamMember(tx, team.TeamName, author.UserID, false)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return ErrAuthorNotTeamMember
+		}
 
-	pr := models.PullRequest{
-		PullRequestID:     request.PullRequestID,
-		PullRequestName:   request.PullRequestName,
-		AuthorID:          request.AuthorID,
-		Status:            "OPEN",
-		AssignedReviewers: datatypes.JSON(reviewersJSON),
-	}
+		reviewers, err = s.selectReviewers(tx, team, author.UserID)
+		if err != nil {
+			return err
+		}
+
+		reviewersJSON, err := json.Marshal(reviewers)
+		if err != nil {
+			return err
+		}
+
+		pr = models.PullRequest{
+			PullRequestID:     request.PullRequestID,
+			PullRequestName:   request.PullRequestName,
+			AuthorID:          request.AuthorID,
+			TeamName:          team.TeamName,
+			Status:            "OPEN",
+			AssignedReviewers: datatypes.JSON(reviewersJSON),
+		}
 
-	if err := tx.Create(&pr).Error; err != nil {
-		tx.Rollback()
+		if err := tx.PullRequests().Create(&pr); err != nil {
+			return err
+		}
+
+		return s.syncAssignments(tx, pr.PullRequestID, reviewers)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
+	for _, reviewer := range reviewers {
+		s.publish(prEvent(notify.EventReviewerAssigned, &pr, reviewer))
 	}
 
 	return &pr, nil
 }
 
-func (s *PRService) selectReviewers(tx *gorm.DB, teamName string, excludeUserID string) ([]string, error) {
-	var availableUsers []models.User
-	result := tx.Where("team_name = ? AND is_active = ? AND user_id != ?", teamName, true, excludeUserID).Find(&availableUsers)
-	if result.Error != nil {
-		return nil, result.Error
+// activeUser loads userID, reporting an inactive user as not found.
+func activeUser(tx repository.Store, userID string) (*models.User, error) {
+	user, err := tx.Users().Get(userID)
+	if err == nil && !user.IsActive {
+		return nil, repository.ErrNotFound
+	}
+	return user, err
+}
+
+func (s *PRService) selectReviewers(tx repository.Store, team *models.TeamDB, excludeUserID string) ([]string, error) {
+	availableUsers, err := s.findCandidates(tx, team, []string{excludeUserID})
+	if err != nil {
+		return nil, err
 	}
 
 	if len(availableUsers) == 0 {
 		return []string{}, nil
 	}
 
-	rand.Shuffle(len(availableUsers), func(i, j int) {
-		availableUsers[i], availableUsers[j] = availableUsers[j], availableUsers[i]
-	})
+	rankCandidates(availableUsers, team.Strategy, s.clock.Now())
 
-	maxReviewers := 2
+	maxReviewers := team.MaxReviewers
 	if len(availableUsers) < maxReviewers {
 		maxReviewers = len(availableUsers)
 	}
 
-	var reviewers []string
+	reviewers := []string{}
 	for i := 0; i < maxReviewers; i++ {
 		reviewers = append(reviewers, availableUsers[i].UserID)
 	}
@@ -110,146 +133,729 @@ func (s *PRService) selectReviewers(tx *gorm.DB, teamName string, excludeUserID
 	return reviewers, nil
 }
 
-func (s *PRService) MergePullRequest(prID string) (*models.PullRequest, error) {
-	var pr models.PullRequest
-	result := s.db.Where("pull_request_id = ?", prID).First(&pr)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("PR not found")
-	} else if result.Error != nil {
-		return nil, result.Error
+func (s *PRService) GetPullRequest(prID string) (*models.PullRequest, error) {
+	pr, err := s.store.PullRequests().Get(prID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPRNotFound
 	}
+	return pr, err
+}
 
-	if pr.Status == "MERGED" {
-		return &pr, nil
+// ListPullRequests returns a page of pull requests sorted by creation time
+// and id, and the cursor of the next page. team_name keeps pull requests of
+// that team only, not of its sub-teams.
+func (s *PRService) ListPullRequests(request models.ListPullRequestsRequest) ([]models.PullRequest, string, error) {
+	page, err := parsePage(request.Limit, "", request.Cursor, "created_at")
+	if err != nil {
+		return nil, "", err
+	}
+	if request.Status != "" && request.Status != "OPEN" && request.Status != "MERGED" {
+		return nil, "", InvalidField(CodeInvalidQuery, "status", "must be OPEN or MERGED")
 	}
 
-	now := time.Now()
-	pr.Status = "MERGED"
-	pr.MergedAt = &now
+	query := repository.PullRequestQuery{
+		Status:     request.Status,
+		AuthorID:   request.AuthorID,
+		ReviewerID: request.ReviewerID,
+		Page:       page,
+	}
+	if request.TeamName != "" {
+		query.TeamNames = []string{request.TeamName}
+	}
+	prs, err := s.store.PullRequests().List(query)
+	if err != nil {
+		return nil, "", err
+	}
 
-	result = s.db.Save(&pr)
-	if result.Error != nil {
-		return nil, result.Error
+	next := ""
+	if len(prs) > page.Limit {
+		prs = prs[:page.Limit]
+		last := prs[page.Limit-1]
+		next = nextCursor(page.Sort, last.PullRequestID, "", last.CreatedAt)
 	}
 
-	return &pr, nil
+	return prs, next, nil
 }
 
-func (s *PRService) ReassignReviewer(prID string, oldReviewerID string) (*models.PullRequest, string, error) {
-	tx := s.db.Begin()
-	if tx.Error != nil {
-		return nil, "", tx.Error
+// Reviewers returns the review assignments of the reviewers of prID in the
+// order of assigned_reviewers.
+func (s *PRService) Reviewers(prID string) ([]models.ReviewAssignment, error) {
+	pr, err := s.GetPullRequest(prID)
+	if err != nil {
+		return nil, err
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+
+	var reviewers []string
+	if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
+		return nil, err
+	}
+
+	assignments, err := s.store.PullRequests().Assignments([]string{prID})
+	if err != nil {
+		return nil, err
+	}
+	byReviewer := make(map[string]models.ReviewAssignment)
+	for _, assignment := range assignments {
+		byReviewer[assignment.ReviewerID] = assignment
+	}
+
+	result := []models.ReviewAssignment{}
+	for _, reviewer := range reviewers {
+		assignment, ok := byReviewer[reviewer]
+		if !ok {
+			assignment = models.ReviewAssignment{PullRequestID: prID, ReviewerID: reviewer}
 		}
-	}()
+		result = append(result, assignment)
+	}
 
-	var pr models.PullRequest
-	result := tx.Where("pull_request_id = ?", prID).First(&pr)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		tx.Rollback()
-		return nil, "", errors.New("PR not found")
-	} else if result.Error != nil {
-		tx.Rollback()
-		return nil, "", result.Error
+	return result, nil
+}
+
+// MergePullRequest marks the pull request MERGED; merging it again changes
+// nothing. Here and in the other changes of a pull request a non-zero
+// version must match the current one, else the change fails with
+// ErrVersionMismatch.
+func (s *PRService) MergePullRequest(prID string, version int) (*models.PullRequest, error) {
+	var pr *models.PullRequest
+	merged := false
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		pr, err = s.lockPR(tx, prID, version)
+		if err != nil {
+			return err
+		}
+
+		if pr.Status == "MERGED" {
+			return nil
+		}
+
+		now := time.Now()
+		pr.Status = "MERGED"
+		pr.MergedAt = &now
+		merged = true
+
+		return updatePR(tx, pr)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if pr.Status == "MERGED" {
-		tx.Rollback()
-		return nil, "", errors.New("cannot reassign on merged PR")
+	if merged {
+		event := prEvent(notify.EventPRMerged, pr, "")
+		event.Recipients = []string{pr.AuthorID}
+		s.publish(event)
 	}
 
-	var reviewers []string
-	if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
-		tx.Rollback()
+	return pr, nil
+}
+
+func (s *PRService) ReassignReviewer(prID string, oldReviewerID string, version int) (*models.PullRequest, string, error) {
+	var pr *models.PullRequest
+	var newReviewer string
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		pr, err = s.lockPR(tx, prID, version)
+		if err != nil {
+			return err
+		}
+
+		if pr.Status == "MERGED" {
+			return ErrPRMerged
+		}
+
+		var reviewers []string
+		if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
+			return err
+		}
+
+		found := false
+		for _, reviewer := range reviewers {
+			if reviewer == oldReviewerID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return ErrNotAssigned
+		}
+
+		_, err = activeUser(tx, oldReviewerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrReviewerNotFound
+		} else if err != nil {
+			return err
+		}
+
+		declined, err := s.declinedReviewers(tx, pr.PullRequestID)
+		if err != nil {
+			return err
+		}
+
+		team, err := s.loadTeam(tx, pr.TeamName)
+		if err != nil {
+			return err
+		}
+
+		excluded := append(append([]string{}, reviewers...), declined...)
+		newReviewer, err = s.findReplacementCandidate(tx, team, pr.AuthorID, excluded)
+		if err != nil {
+			return err
+		}
+
+		for i, reviewer := range reviewers {
+			if reviewer == oldReviewerID {
+				reviewers[i] = newReviewer
+				break
+			}
+		}
+
+		return s.saveReviewers(tx, pr, reviewers)
+	})
+	if err != nil {
 		return nil, "", err
 	}
 
-	found := false
-	for _, reviewer := range reviewers {
-		if reviewer == oldReviewerID {
-			found = true
-			break
+	event := prEvent(notify.EventReviewerReplaced, pr, newReviewer)
+	event.PreviousReviewerID = oldReviewerID
+	s.publish(event)
+
+	return pr, newReviewer, nil
+}
+
+func (s *PRService) findReplacementCandidate(tx repository.Store, team *models.TeamDB, authorID string, excludedUserIDs []string) (string, error) {
+	excluded := append([]string{authorID}, excludedUserIDs...)
+	availableUsers, err := s.findCandidates(tx, team, excluded)
+	if err != nil {
+		return "", err
+	}
+
+	if len(availableUsers) == 0 {
+		return "", ErrNoCandidate
+	}
+
+	rankCandidates(availableUsers, team.Strategy, s.clock.Now())
+
+	return availableUsers[0].UserID, nil
+}
+
+// findCandidates returns the users that may be assigned automatically to a
+// pull request of team. A team without any falls back to its sibling teams,
+// walking up the hierarchy until some level has candidates.
+func (s *PRService) findCandidates(tx repository.Store, team *models.TeamDB, excludedUserIDs []string) ([]models.User, error) {
+	now := s.clock.Now()
+	users, err := tx.Users().Candidates([]string{team.TeamName}, excludedUserIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) > 0 || team.ParentTeamName == "" {
+		return users, nil
+	}
+
+	hierarchy, err := loadTeamHierarchy(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, siblings := range hierarchy.siblingLevels(team.TeamName) {
+		users, err = tx.Users().Candidates(siblings, excludedUserIDs, now)
+		if err != nil {
+			return nil, err
+		}
+		if len(users) > 0 {
+			return users, nil
+		}
+	}
+
+	return users, nil
+}
+
+// rankCandidates shuffles the candidates and, for the WORKING_HOURS strategy,
+// moves reviewers who are at work right now to the front, followed by those
+// whose next working window opens soonest.
+func rankCandidates(users []models.User, strategy string, now time.Time) {
+	rand.Shuffle(len(users), func(i, j int) {
+		users[i], users[j] = users[j], users[i]
+	})
+
+	if strategy != models.StrategyWorkingHours {
+		return
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return userWindow(users[i]).NextStart(now).Before(userWindow(users[j]).NextStart(now))
+	})
+}
+
+func userWindow(user models.User) workhours.Window {
+	window, err := workhours.Parse(user.Timezone, user.WorkStart, user.WorkEnd)
+	if err != nil {
+		return workhours.Default
+	}
+	return window
+}
+
+// AddReviewer assigns reviewerID by hand. Any member of the PR team may be
+// added, observers included. A team lead named in approvedBy may exceed the
+// team's maximum.
+func (s *PRService) AddReviewer(prID string, reviewerID string, approvedBy string, version int) (*models.PullRequest, error) {
+	var pr *models.PullRequest
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var reviewers []string
+		var err error
+		pr, reviewers, err = s.loadOpenPR(tx, prID, version)
+		if err != nil {
+			return err
+		}
+
+		for _, reviewer := range reviewers {
+			if reviewer == reviewerID {
+				return ErrAlreadyAssigned
+			}
+		}
+
+		if reviewerID == pr.AuthorID {
+			return ErrAuthorIsReviewer
+		}
+
+		declined, err := s.declinedReviewers(tx, pr.PullRequestID)
+		if err != nil {
+			return err
 		}
+		for _, decliner := range declined {
+			if decliner == reviewerID {
+				return ErrReviewDeclined
+			}
+		}
+
+		reviewer, err := activeUser(tx, reviewerID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrReviewerNotFound
+		} else if err != nil {
+			return err
+		}
+
+		member, err := isTeamMember(tx, pr.TeamName, reviewer.UserID, false)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return ErrReviewerNotTeamMember
+		}
+
+		team, err := s.loadTeam(tx, pr.TeamName)
+		if err != nil {
+			return err
+		}
+
+		override, err := s.overrideApproved(tx, team.TeamName, approvedBy)
+		if err != nil {
+			return err
+		}
+
+		if len(reviewers) >= team.MaxReviewers && !override {
+			return ErrMaxReviewers
+		}
+
+		return s.saveReviewers(tx, pr, append(reviewers, reviewerID))
+	})
+	if err != nil {
+		return nil, err
 	}
-	if !found {
-		tx.Rollback()
-		return nil, "", errors.New("reviewer is not assigned to this PR")
+
+	s.publish(prEvent(notify.EventReviewerAssigned, pr, reviewerID))
+
+	return pr, nil
+}
+
+// RemoveReviewer unassigns reviewerID. A team lead named in approvedBy may go
+// below the team's minimum.
+func (s *PRService) RemoveReviewer(prID string, reviewerID string, approvedBy string, version int) (*models.PullRequest, error) {
+	var pr *models.PullRequest
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var reviewers []string
+		var err error
+		pr, reviewers, err = s.loadOpenPR(tx, prID, version)
+		if err != nil {
+			return err
+		}
+
+		remaining := []string{}
+		for _, reviewer := range reviewers {
+			if reviewer != reviewerID {
+				remaining = append(remaining, reviewer)
+			}
+		}
+		if len(remaining) == len(reviewers) {
+			return ErrNotAssigned
+		}
+
+		team, err := s.loadTeam(tx, pr.TeamName)
+		if err != nil {
+			return err
+		}
+
+		override, err := s.overrideApproved(tx, team.TeamName, approvedBy)
+		if err != nil {
+			return err
+		}
+
+		if len(remaining) < team.MinReviewers && !override {
+			return ErrMinReviewers
+		}
+
+		return s.saveReviewers(tx, pr, remaining)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var oldReviewer models.User
-	result = tx.Where("user_id = ? AND is_active = ?", oldReviewerID, true).First(&oldReviewer)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		tx.Rollback()
-		return nil, "", errors.New("old reviewer not found or inactive")
-	} else if result.Error != nil {
-		tx.Rollback()
-		return nil, "", result.Error
+	return pr, nil
+}
+
+func (s *PRService) DeclineReview(prID string, reviewerID string, reason string, version int) (*models.PullRequest, string, error) {
+	if !isValidDeclineReason(reason) {
+		return nil, "", ErrInvalidDeclineReason
 	}
 
-	newReviewer, err := s.findReplacementCandidate(tx, oldReviewer.TeamName, pr.AuthorID, reviewers)
+	var pr *models.PullRequest
+	var newReviewer string
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var reviewers []string
+		var err error
+		pr, reviewers, err = s.loadOpenPR(tx, prID, version)
+		if err != nil {
+			return err
+		}
+
+		position := -1
+		for i, reviewer := range reviewers {
+			if reviewer == reviewerID {
+				position = i
+				break
+			}
+		}
+		if position < 0 {
+			return ErrNotAssigned
+		}
+
+		declined, err := s.declinedReviewers(tx, pr.PullRequestID)
+		if err != nil {
+			return err
+		}
+
+		// The decliner stays excluded from re-selection on this PR for good,
+		// including later reassignments.
+		team, err := s.loadTeam(tx, pr.TeamName)
+		if err != nil {
+			return err
+		}
+
+		excluded := append(append([]string{}, reviewers...), declined...)
+		newReviewer, err = s.findReplacementCandidate(tx, team, pr.AuthorID, excluded)
+		if err != nil && !errors.Is(err, ErrNoCandidate) {
+			return err
+		}
+
+		if newReviewer != "" {
+			reviewers[position] = newReviewer
+		} else {
+			if len(reviewers)-1 < team.MinReviewers {
+				return ErrNoCandidate
+			}
+			reviewers = append(reviewers[:position], reviewers[position+1:]...)
+		}
+
+		if err := s.saveReviewers(tx, pr, reviewers); err != nil {
+			return err
+		}
+
+		decline := models.ReviewDecline{
+			PullRequestID: pr.PullRequestID,
+			ReviewerID:    reviewerID,
+			Reason:        reason,
+			ReplacedBy:    newReviewer,
+		}
+		return tx.PullRequests().CreateDecline(&decline)
+	})
 	if err != nil {
-		tx.Rollback()
 		return nil, "", err
 	}
 
-	for i, reviewer := range reviewers {
-		if reviewer == oldReviewerID {
-			reviewers[i] = newReviewer
-			break
+	if newReviewer != "" {
+		event := prEvent(notify.EventReviewerReplaced, pr, newReviewer)
+		event.PreviousReviewerID = reviewerID
+		s.publish(event)
+	}
+
+	return pr, newReviewer, nil
+}
+
+// overrideApproved reports whether approvedBy may override the team's
+// reviewer limits; only active leads of the team may.
+func (s *PRService) overrideApproved(tx repository.Store, teamName string, approvedBy string) (bool, error) {
+	if approvedBy == "" {
+		return false, nil
+	}
+
+	leads, err := teamLeads(tx, teamName)
+	if err != nil {
+		return false, err
+	}
+	for _, lead := range leads {
+		if lead == approvedBy {
+			return true, nil
 		}
 	}
 
+	return false, ErrNotTeamLead
+}
+
+func isValidDeclineReason(reason string) bool {
+	switch reason {
+	case models.DeclineReasonBusy, models.DeclineReasonConflictOfInterest, models.DeclineReasonLacksContext:
+		return true
+	}
+	return false
+}
+
+func (s *PRService) declinedReviewers(tx repository.Store, prID string) ([]string, error) {
+	declines, err := tx.PullRequests().Declines(repository.DeclineQuery{PullRequestIDs: []string{prID}})
+	if err != nil {
+		return nil, err
+	}
+
+	var declined []string
+	for _, decline := range declines {
+		declined = append(declined, decline.ReviewerID)
+	}
+
+	return declined, nil
+}
+
+// lockPR loads prID for a change and checks that it is still at version
+// unless that is zero.
+func (s *PRService) lockPR(tx repository.Store, prID string, version int) (*models.PullRequest, error) {
+	pr, err := tx.PullRequests().Lock(prID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPRNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && pr.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	return pr, nil
+}
+
+func (s *PRService) loadOpenPR(tx repository.Store, prID string, version int) (*models.PullRequest, []string, error) {
+	pr, err := s.lockPR(tx, prID, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pr.Status == "MERGED" {
+		return nil, nil, ErrPRMerged
+	}
+
+	var reviewers []string
+	if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
+		return nil, nil, err
+	}
+
+	return pr, reviewers, nil
+}
+
+func (s *PRService) saveReviewers(tx repository.Store, pr *models.PullRequest, reviewers []string) error {
 	reviewersJSON, err := json.Marshal(reviewers)
 	if err != nil {
-		tx.Rollback()
-		return nil, "", err
+		return err
 	}
 	pr.AssignedReviewers = datatypes.JSON(reviewersJSON)
 
-	result = tx.Save(&pr)
-	if result.Error != nil {
-		tx.Rollback()
-		return nil, "", result.Error
+	if err := updatePR(tx, pr); err != nil {
+		return err
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, "", err
+	return s.syncAssignments(tx, pr.PullRequestID, reviewers)
+}
+
+// updatePR saves pr, reporting a lost race as a version mismatch.
+func updatePR(tx repository.Store, pr *models.PullRequest) error {
+	err := tx.PullRequests().Update(pr)
+	if errors.Is(err, repository.ErrConflict) {
+		return ErrVersionMismatch
+	}
+	return err
+}
+
+// syncAssignments keeps review_assignments in line with the reviewer list:
+// reviewers that were dropped lose their row, new ones start their SLA clock
+// now and reviewers that stay keep their original assignment time.
+func (s *PRService) syncAssignments(tx repository.Store, prID string, reviewers []string) error {
+	existing, err := tx.PullRequests().Assignments([]string{prID})
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool)
+	for _, reviewer := range reviewers {
+		current[reviewer] = true
+	}
+
+	assigned := make(map[string]bool)
+	for _, assignment := range existing {
+		if current[assignment.ReviewerID] {
+			assigned[assignment.ReviewerID] = true
+			continue
+		}
+		if err := tx.PullRequests().DeleteAssignment(prID, assignment.ReviewerID); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, reviewer := range reviewers {
+		if assigned[reviewer] {
+			continue
+		}
+		assignment := models.ReviewAssignment{
+			PullRequestID: prID,
+			ReviewerID:    reviewer,
+			AssignedAt:    now,
+		}
+		if err := tx.PullRequests().SaveAssignment(&assignment); err != nil {
+			return err
+		}
 	}
 
-	return &pr, newReviewer, nil
+	return nil
 }
 
-func (s *PRService) findReplacementCandidate(tx *gorm.DB, teamName string, authorID string, currentReviewers []string) (string, error) {
-	var availableUsers []models.User
+// handOffReviews moves userID off every OPEN pull request they review,
+// replacing them with another member of the pull request's team where
+// possible and dropping them otherwise. With teamName set only pull requests
+// of that team are touched.
+func (s *PRService) handOffReviews(tx repository.Store, userID string, teamName string) ([]models.ReviewHandoff, error) {
+	openPRs, err := tx.PullRequests().List(repository.PullRequestQuery{Status: "OPEN"})
+	if err != nil {
+		return nil, err
+	}
+
+	var handoffs []models.ReviewHandoff
+	for _, pr := range reviewsByUser(openPRs)[userID] {
+		if teamName != "" && pr.TeamName != teamName {
+			continue
+		}
 
-	query := "team_name = ? AND is_active = ? AND user_id != ?"
-	params := []interface{}{teamName, true, authorID}
+		var reviewers []string
+		if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
+			return nil, err
+		}
 
-	if len(currentReviewers) > 0 {
-		query += " AND user_id NOT IN (?"
-		params = append(params, currentReviewers[0])
-		for i := 1; i < len(currentReviewers); i++ {
-			query += ",?"
-			params = append(params, currentReviewers[i])
+		declined, err := s.declinedReviewers(tx, pr.PullRequestID)
+		if err != nil {
+			return nil, err
 		}
-		query += ")"
+
+		newReviewer := ""
+		team, err := s.loadTeam(tx, pr.TeamName)
+		if err == nil {
+			excluded := append(append([]string{}, reviewers...), declined...)
+			newReviewer, err = s.findReplacementCandidate(tx, team, pr.AuthorID, excluded)
+			if err != nil && !errors.Is(err, ErrNoCandidate) {
+				return nil, err
+			}
+		} else if !errors.Is(err, ErrTeamNotFound) {
+			return nil, err
+		}
+
+		remaining := []string{}
+		for _, reviewer := range reviewers {
+			if reviewer != userID {
+				remaining = append(remaining, reviewer)
+			} else if newReviewer != "" {
+				remaining = append(remaining, newReviewer)
+			}
+		}
+
+		if err := s.saveReviewers(tx, &pr, remaining); err != nil {
+			return nil, err
+		}
+
+		handoffs = append(handoffs, models.ReviewHandoff{
+			PullRequestID: pr.PullRequestID,
+			OldReviewerID: userID,
+			NewReviewerID: newReviewer,
+		})
 	}
 
-	result := tx.Where(query, params...).Find(&availableUsers)
-	if result.Error != nil {
-		return "", result.Error
+	return handoffs, nil
+}
+
+// publishHandoffs notifies the new reviewers of committed hand-offs.
+func (s *PRService) publishHandoffs(handoffs []models.ReviewHandoff) {
+	for _, handoff := range handoffs {
+		if handoff.NewReviewerID == "" {
+			continue
+		}
+
+		pr, err := s.store.PullRequests().Get(handoff.PullRequestID)
+		if err != nil {
+			continue
+		}
+		event := prEvent(notify.EventReviewerReplaced, pr, handoff.NewReviewerID)
+		event.PreviousReviewerID = handoff.OldReviewerID
+		s.publish(event)
 	}
+}
 
-	if len(availableUsers) == 0 {
-		return "", errors.New("no active replacement candidate in team")
+// deletePullRequest removes an OPEN pull request together with its review
+// bookkeeping.
+func (s *PRService) deletePullRequest(tx repository.Store, prID string) error {
+	pr, err := tx.PullRequests().Lock(prID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && pr.Status != "OPEN") {
+		return nil
+	} else if err != nil {
+		return err
 	}
+	return tx.PullRequests().Delete(prID)
+}
 
-	rand.Shuffle(len(availableUsers), func(i, j int) {
-		availableUsers[i], availableUsers[j] = availableUsers[j], availableUsers[i]
-	})
+// prEvent builds a notification about pr addressed to the reviewer.
+func prEvent(eventType notify.EventType, pr *models.PullRequest, reviewerID string) notify.Event {
+	event := notify.Event{
+		Type:            eventType,
+		TeamName:        pr.TeamName,
+		PullRequestID:   pr.PullRequestID,
+		PullRequestName: pr.PullRequestName,
+		AuthorID:        pr.AuthorID,
+		ReviewerID:      reviewerID,
+		OccurredAt:      time.Now(),
+	}
+	if reviewerID != "" {
+		event.Recipients = []string{reviewerID}
+	}
+	return event
+}
 
-	return availableUsers[0].UserID, nil
+// publish delivers notifications in the background once the change is
+// committed; delivery failures are logged and never fail the request.
+func (s *PRService) publish(event notify.Event) {
+	go func() {
+		if err := s.notifier.Notify(context.Background(), event); err != nil {
+			log.Printf("failed to deliver %s notification for %s: %v", event.Type, event.PullRequestID, err)
+		}
+	}()
+}
+
+func (s *PRService) loadTeam(tx repository.Store, teamName string) (*models.TeamDB, error) {
+	team, err := tx.Teams().Get(teamName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTeamNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return team, nil
 }
@@ -0,0 +1,220 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/clock"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/db/migrations"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm/logger"
+)
+
+func TestRankCandidatesWorkingHours(t *testing.T) {
+	// Monday 2025-11-24 08:00 UTC: Moscow is at work, Berlin starts in an
+	// hour and San Francisco is asleep.
+	now := time.Date(2025, 11, 24, 8, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 20; i++ {
+		users := []models.User{
+			{UserID: "sf", Timezone: "America/Los_Angeles", WorkStart: "09:00", WorkEnd: "17:00"},
+			{UserID: "berlin", Timezone: "Europe/Berlin", WorkStart: "10:00", WorkEnd: "18:00"},
+			{UserID: "msk", Timezone: "Europe/Moscow", WorkStart: "09:00", WorkEnd: "18:00"},
+		}
+
+		rankCandidates(users, models.StrategyWorkingHours, now)
+		assert.Equal(t, []string{"msk", "berlin", "sf"}, []string{users[0].UserID, users[1].UserID, users[2].UserID})
+	}
+}
+
+func TestSelectReviewersWorkingHours(t *testing.T) {
+	useMemoryStore(t)
+	_, err := NewTeamService().CreateTeam(models.Team{TeamName: "backend", MinReviewers: 1, MaxReviewers: 1, Strategy: models.StrategyWorkingHours,
+		Members: []models.TeamMember{
+			{UserID: "u1", Username: "u1", IsActive: true},
+			{UserID: "sf", Username: "sf", IsActive: true, Timezone: "America/Los_Angeles", WorkStart: "09:00", WorkEnd: "17:00"},
+			{UserID: "msk", Username: "msk", IsActive: true, Timezone: "Europe/Moscow", WorkStart: "09:00", WorkEnd: "18:00"},
+		}})
+	require.NoError(t, err)
+
+	// Monday 2025-11-24 08:00 UTC is 11:00 in Moscow and midnight in San
+	// Francisco; at 18:00 UTC Moscow has gone home and San Francisco is at
+	// work.
+	fake := clock.NewFake(time.Date(2025, 11, 24, 8, 0, 0, 0, time.UTC))
+	service := NewPRService()
+	service.clock = fake
+	for _, expected := range []string{"msk", "sf"} {
+		for i := 0; i < 10; i++ {
+			pr, err := service.CreatePullRequest(models.CreatePRRequest{
+				PullRequestID:   fmt.Sprintf("pr-%s-%d", expected, i),
+				PullRequestName: "Add search",
+				AuthorID:        "u1",
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []string{expected}, assignedReviewers(t, pr))
+		}
+		fake.Advance(10 * time.Hour)
+	}
+}
+
+// useMemoryStore points the services at a fresh in-memory store for the
+// duration of the test.
+func useMemoryStore(t *testing.T) {
+	store, notifier := db.Store, notify.Default
+	db.Store, notify.Default = repository.NewMemoryStore(), notify.Multi{}
+	t.Cleanup(func() {
+		db.Store, notify.Default = store, notifier
+	})
+}
+
+// useSQLiteStore points the services at a GORM store on a fresh SQLite file,
+// opened the way DATABASE_DRIVER=sqlite opens it, for the duration of the
+// test.
+func useSQLiteStore(t *testing.T) {
+	t.Setenv("DATABASE_DRIVER", "sqlite")
+	t.Setenv("DATABASE_PATH", filepath.Join(t.TempDir(), "reviews.db"))
+	gormDB, err := db.Open()
+	require.NoError(t, err)
+	gormDB.Logger = logger.Default.LogMode(logger.Silent)
+	sqlDB, err := gormDB.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	_, err = migrations.Up(gormDB)
+	require.NoError(t, err)
+
+	store, notifier := db.Store, notify.Default
+	db.Store, notify.Default = repository.NewGormStore(gormDB), notify.Multi{}
+	t.Cleanup(func() {
+		db.Store, notify.Default = store, notifier
+	})
+}
+
+func createTestTeam(t *testing.T, teamName string, userIDs ...string) {
+	team := models.Team{TeamName: teamName, MinReviewers: 1, MaxReviewers: 2}
+	for _, userID := range userIDs {
+		team.Members = append(team.Members, models.TeamMember{UserID: userID, Username: userID, IsActive: true})
+	}
+	_, err := NewTeamService().CreateTeam(team)
+	require.NoError(t, err)
+}
+
+func assignedReviewers(t *testing.T, pr *models.PullRequest) []string {
+	var reviewers []string
+	require.NoError(t, json.Unmarshal(pr.AssignedReviewers, &reviewers))
+	return reviewers
+}
+
+func TestPullRequestLifecycle(t *testing.T) {
+	useMemoryStore(t)
+	createTestTeam(t, "backend", "u1", "u2", "u3", "u4")
+	service := NewPRService()
+
+	pr, err := service.CreatePullRequest(models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Add search", AuthorID: "u1"})
+	require.NoError(t, err)
+	reviewers := assignedReviewers(t, pr)
+	assert.Len(t, reviewers, 2)
+	assert.NotContains(t, reviewers, "u1")
+	assert.Equal(t, "backend", pr.TeamName)
+
+	_, err = service.CreatePullRequest(models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Add search", AuthorID: "u1"})
+	assert.ErrorIs(t, err, ErrPRExists)
+
+	pr, replacement, err := service.ReassignReviewer("pr-1", reviewers[0], 0)
+	require.NoError(t, err)
+	assert.NotContains(t, []string{"u1", reviewers[0], reviewers[1]}, replacement)
+	assert.ElementsMatch(t, []string{replacement, reviewers[1]}, assignedReviewers(t, pr))
+
+	// The reviewer reassigned away is the only one left to take over.
+	pr, replacement, err = service.DeclineReview("pr-1", reviewers[1], models.DeclineReasonBusy, 0)
+	require.NoError(t, err)
+	assert.Equal(t, reviewers[0], replacement)
+	assert.NotContains(t, assignedReviewers(t, pr), reviewers[1])
+
+	_, err = service.AddReviewer("pr-1", reviewers[1], "", 0)
+	assert.ErrorIs(t, err, ErrReviewDeclined)
+
+	_, err = service.MergePullRequest("pr-1", pr.Version-1)
+	assert.ErrorIs(t, err, ErrVersionMismatch)
+	pr, err = service.MergePullRequest("pr-1", pr.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "MERGED", pr.Status)
+	assert.NotNil(t, pr.MergedAt)
+
+	_, _, err = service.ReassignReviewer("pr-1", assignedReviewers(t, pr)[0], 0)
+	assert.ErrorIs(t, err, ErrPRMerged)
+}
+
+// TestConcurrentReassign runs on the memory store and on SQLite, where the
+// row lock and the conditional update of the GORM store decide the race.
+func TestConcurrentReassign(t *testing.T) {
+	stores := map[string]func(t *testing.T){
+		"Memory": useMemoryStore,
+		"SQLite": useSQLiteStore,
+	}
+	for name, useStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			useStore(t)
+			testConcurrentReassign(t)
+		})
+	}
+}
+
+func testConcurrentReassign(t *testing.T) {
+	createTestTeam(t, "backend", "u1", "u2", "u3", "u4", "u5", "u6")
+	service := NewPRService()
+	_, err := service.CreatePullRequest(models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Add search", AuthorID: "u1"})
+	require.NoError(t, err)
+
+	// Every worker reassigns the reviewer it saw with the version it saw, the
+	// way a client sending If-Match does, so all but one of the workers that
+	// read the same version must fail.
+	var succeeded atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pr, err := db.Store.PullRequests().Get("pr-1")
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, _, err = service.ReassignReviewer("pr-1", assignedReviewers(t, pr)[i%2], pr.Version)
+			if err == nil {
+				succeeded.Add(1)
+			} else if !errors.Is(err, ErrVersionMismatch) && !errors.Is(err, ErrNoCandidate) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	pr, err := db.Store.PullRequests().Get("pr-1")
+	require.NoError(t, err)
+	assert.Positive(t, succeeded.Load())
+	assert.Equal(t, 1+int(succeeded.Load()), pr.Version)
+
+	reviewers := assignedReviewers(t, pr)
+	assert.Len(t, reviewers, 2)
+	assert.NotEqual(t, reviewers[0], reviewers[1])
+	assert.NotContains(t, reviewers, "u1")
+
+	assignments, err := db.Store.PullRequests().Assignments([]string{"pr-1"})
+	require.NoError(t, err)
+	var assigned []string
+	for _, assignment := range assignments {
+		assigned = append(assigned, assignment.ReviewerID)
+	}
+	assert.ElementsMatch(t, reviewers, assigned)
+}
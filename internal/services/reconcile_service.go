@@ -0,0 +1,401 @@
+package services
+
+import (
+	"fmt"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/repository"
+	"sort"
+)
+
+// The profile defaults of a new user, as in the users table.
+const (
+	defaultTimezone  = "UTC"
+	defaultWorkStart = "09:00"
+	defaultWorkEnd   = "18:00"
+)
+
+type ReconcileService struct {
+	store     repository.Store
+	prService *PRService
+}
+
+func NewReconcileService() *ReconcileService {
+	return &ReconcileService{store: db.Store, prService: NewPRService()}
+}
+
+// reconcilePlan is a validated roster spec: the teams with defaults applied
+// in the order they have to be written, the desired user profiles and the
+// memberships to deactivate.
+type reconcilePlan struct {
+	teams       []models.Team
+	existing    map[string]bool
+	users       []models.User
+	newUsers    map[string]bool
+	deactivated []models.TeamMembership
+	changes     []models.RosterChange
+}
+
+// Reconcile compares a YAML roster spec with the database. The spec is
+// authoritative for the teams it lists: their settings, the profiles of
+// their members and their memberships, so omitted fields fall back to their
+// defaults. Members missing from a listed team are deactivated in it rather
+// than deleted, and their open reviews of the team's pull requests are
+// handed off. Teams the spec does not list are left alone. With apply set
+// the database is converged to the spec in one transaction; otherwise only
+// the plan is returned.
+func (s *ReconcileService) Reconcile(data []byte, apply bool) (*models.ReconcileResponse, error) {
+	teams, err := parseImport(ImportFormatYAML, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var response *models.ReconcileResponse
+	var handoffs []models.ReviewHandoff
+	err = s.store.Transaction(func(tx repository.Store) error {
+		plan, err := s.plan(tx, documentTeams(teams))
+		if err != nil {
+			return err
+		}
+
+		response = &models.ReconcileResponse{Applied: apply, Changes: plan.changes}
+		if response.Changes == nil {
+			response.Changes = []models.RosterChange{}
+		}
+		if !apply {
+			return nil
+		}
+
+		handoffs, err = s.apply(tx, plan)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.prService.publishHandoffs(handoffs)
+	response.Handoffs = handoffs
+	return response, nil
+}
+
+// plan validates the spec and collects the changes converging to it. All
+// problems are reported together.
+func (s *ReconcileService) plan(tx repository.Store, teams []models.Team) (*reconcilePlan, error) {
+	order, current, problems, err := orderTeams(tx, teams)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, invalidRoster(problems)
+	}
+
+	plan := &reconcilePlan{existing: make(map[string]bool), newUsers: make(map[string]bool)}
+
+	desired := make(map[string]models.User)
+	describedIn := make(map[string]string)
+	var teamNames, userIDs []string
+	for _, i := range order {
+		team := teams[i]
+		applyTeamDefaults(&team)
+		if err := validateTeam(team); err != nil {
+			problems = append(problems, fmt.Sprintf("teams[%d] (%s): %s", i, team.TeamName, err.Error()))
+			continue
+		}
+
+		for _, member := range team.Members {
+			user := desiredUser(member)
+			if previous, ok := desired[member.UserID]; !ok {
+				desired[member.UserID] = user
+				describedIn[member.UserID] = team.TeamName
+				userIDs = append(userIDs, member.UserID)
+			} else if !sameProfile(previous, user) {
+				problems = append(problems, fmt.Sprintf("teams[%d] (%s): user %q is described differently in team %q", i, team.TeamName, member.UserID, describedIn[member.UserID]))
+			} else if member.IsActive {
+				previous.IsActive = true
+				desired[member.UserID] = previous
+			}
+		}
+
+		teamNames = append(teamNames, team.TeamName)
+		plan.teams = append(plan.teams, team)
+	}
+	if len(problems) > 0 {
+		return nil, invalidRoster(problems)
+	}
+
+	existingUsers, err := tx.Users().Find(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[string]models.User)
+	for _, user := range existingUsers {
+		users[user.UserID] = user
+	}
+
+	existingMemberships, err := tx.Teams().Memberships(repository.MembershipQuery{TeamNames: teamNames})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(existingMemberships, func(i, j int) bool {
+		a, b := existingMemberships[i], existingMemberships[j]
+		if a.TeamName != b.TeamName {
+			return a.TeamName < b.TeamName
+		}
+		return a.UserID < b.UserID
+	})
+	memberships := make(map[string][]models.TeamMembership)
+	for _, membership := range existingMemberships {
+		memberships[membership.TeamName] = append(memberships[membership.TeamName], membership)
+	}
+
+	planned := make(map[string]bool)
+	for _, team := range plan.teams {
+		if existing, ok := current[team.TeamName]; ok {
+			plan.existing[team.TeamName] = true
+			if fields := teamDiff(existing, team); len(fields) > 0 {
+				plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterUpdateTeam, TeamName: team.TeamName, Fields: fields})
+			}
+		} else {
+			plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterCreateTeam, TeamName: team.TeamName})
+		}
+
+		inSpec := make(map[string]bool)
+		for _, member := range team.Members {
+			inSpec[member.UserID] = true
+			if !planned[member.UserID] {
+				planned[member.UserID] = true
+				plan.changes = append(plan.changes, planUser(plan, team.TeamName, desired[member.UserID], users)...)
+			}
+
+			var membership *models.TeamMembership
+			for j := range memberships[team.TeamName] {
+				if memberships[team.TeamName][j].UserID == member.UserID {
+					membership = &memberships[team.TeamName][j]
+				}
+			}
+			if membership == nil {
+				plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterAddMember, TeamName: team.TeamName, UserID: member.UserID})
+				continue
+			}
+
+			var fields []string
+			if member.Role != membership.Role {
+				fields = append(fields, "role")
+			}
+			if member.IsActive != membership.IsActive {
+				fields = append(fields, "is_active")
+			}
+			if len(fields) > 0 {
+				plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterUpdateMember, TeamName: team.TeamName, UserID: member.UserID, Fields: fields})
+			}
+			if membership.IsActive && !member.IsActive {
+				plan.deactivated = append(plan.deactivated, *membership)
+			}
+		}
+
+		for _, membership := range memberships[team.TeamName] {
+			if !inSpec[membership.UserID] && membership.IsActive {
+				plan.changes = append(plan.changes, models.RosterChange{Action: models.RosterDeactivateMember, TeamName: team.TeamName, UserID: membership.UserID})
+				plan.deactivated = append(plan.deactivated, membership)
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// planUser records creating the desired user or the profile fields that
+// differ from the stored one. An inactive user is reactivated if any of
+// their memberships in the spec is active; users are never deactivated.
+func planUser(plan *reconcilePlan, teamName string, user models.User, users map[string]models.User) []models.RosterChange {
+	existing, ok := users[user.UserID]
+	if !ok {
+		plan.newUsers[user.UserID] = true
+		plan.users = append(plan.users, user)
+		return []models.RosterChange{{Action: models.RosterCreateUser, TeamName: teamName, UserID: user.UserID}}
+	}
+
+	fields := profileDiff(existing, user)
+	if user.IsActive && !existing.IsActive {
+		fields = append(fields, "is_active")
+	}
+	user.IsActive = user.IsActive || existing.IsActive
+	plan.users = append(plan.users, user)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return []models.RosterChange{{Action: models.RosterUpdateUser, TeamName: teamName, UserID: user.UserID, Fields: fields}}
+}
+
+// desiredUser is the profile a spec member describes, with the same defaults
+// a new user gets.
+func desiredUser(member models.TeamMember) models.User {
+	user := models.User{
+		UserID:     member.UserID,
+		Username:   member.Username,
+		IsActive:   member.IsActive,
+		Email:      member.Email,
+		ChatHandle: member.ChatHandle,
+		Timezone:   member.Timezone,
+		WorkStart:  member.WorkStart,
+		WorkEnd:    member.WorkEnd,
+		Capacity:   member.Capacity,
+		OOOFrom:    member.OOOFrom,
+		OOOUntil:   member.OOOUntil,
+	}
+	if user.Timezone == "" {
+		user.Timezone = defaultTimezone
+	}
+	if user.WorkStart == "" {
+		user.WorkStart = defaultWorkStart
+	}
+	if user.WorkEnd == "" {
+		user.WorkEnd = defaultWorkEnd
+	}
+	return user
+}
+
+func sameProfile(a, b models.User) bool {
+	return len(profileDiff(a, b)) == 0
+}
+
+// profileDiff lists the profile fields of want that differ from have.
+func profileDiff(have, want models.User) []string {
+	var fields []string
+	for _, field := range []struct {
+		name       string
+		have, want string
+	}{
+		{"username", have.Username, want.Username},
+		{"email", have.Email, want.Email},
+		{"chat_handle", have.ChatHandle, want.ChatHandle},
+		{"timezone", have.Timezone, want.Timezone},
+		{"work_start", have.WorkStart, want.WorkStart},
+		{"work_end", have.WorkEnd, want.WorkEnd},
+	} {
+		if field.have != field.want {
+			fields = append(fields, field.name)
+		}
+	}
+	if have.Capacity != want.Capacity {
+		fields = append(fields, "capacity")
+	}
+	if !sameTime(have.OOOFrom, want.OOOFrom) {
+		fields = append(fields, "ooo_from")
+	}
+	if !sameTime(have.OOOUntil, want.OOOUntil) {
+		fields = append(fields, "ooo_until")
+	}
+	return fields
+}
+
+// teamDiff lists the settings of team, with defaults applied, that differ
+// from the stored team.
+func teamDiff(have models.TeamDB, team models.Team) []string {
+	var fields []string
+	for _, field := range []struct {
+		name       string
+		have, want interface{}
+	}{
+		{"parent_team_name", have.ParentTeamName, team.ParentTeamName},
+		{"min_reviewers", have.MinReviewers, team.MinReviewers},
+		{"max_reviewers", have.MaxReviewers, team.MaxReviewers},
+		{"sla_hours", have.SLAHours, team.SLAHours},
+		{"sla_policy", have.SLAPolicy, team.SLAPolicy},
+		{"assignment_strategy", have.Strategy, team.Strategy},
+		{"digest_time", have.DigestTime, team.DigestTime},
+		{"quiet_hours_start", have.QuietStart, team.QuietStart},
+		{"quiet_hours_end", have.QuietEnd, team.QuietEnd},
+		{"chat_webhook_url", have.ChatWebhook, team.ChatWebhook},
+	} {
+		if field.have != field.want {
+			fields = append(fields, field.name)
+		}
+	}
+	return fields
+}
+
+// apply converges the database to the plan: teams first, parents ahead of
+// their sub-teams, then users and memberships. Deactivated members hand off
+// their open reviews of the team's pull requests last, once every
+// membership is in place.
+func (s *ReconcileService) apply(tx repository.Store, plan *reconcilePlan) ([]models.ReviewHandoff, error) {
+	homeTeams := make(map[string]string)
+	for _, team := range plan.teams {
+		teamDB := models.TeamDB{
+			TeamName:       team.TeamName,
+			ParentTeamName: team.ParentTeamName,
+			MinReviewers:   team.MinReviewers,
+			MaxReviewers:   team.MaxReviewers,
+			SLAHours:       team.SLAHours,
+			SLAPolicy:      team.SLAPolicy,
+			Strategy:       team.Strategy,
+			DigestTime:     team.DigestTime,
+			QuietStart:     team.QuietStart,
+			QuietEnd:       team.QuietEnd,
+			ChatWebhook:    team.ChatWebhook,
+		}
+		if plan.existing[team.TeamName] {
+			if err := tx.Teams().Update(&teamDB); err != nil {
+				return nil, err
+			}
+		} else if err := tx.Teams().Create(&teamDB); err != nil {
+			return nil, err
+		}
+
+		for _, member := range team.Members {
+			if _, ok := homeTeams[member.UserID]; !ok {
+				homeTeams[member.UserID] = team.TeamName
+			}
+		}
+	}
+
+	for _, user := range plan.users {
+		if plan.newUsers[user.UserID] {
+			user.TeamName = homeTeams[user.UserID]
+			if err := tx.Users().Create(&user); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		existing, err := tx.Users().Get(user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		user.TeamName = existing.TeamName
+		if user.TeamName == "" {
+			user.TeamName = homeTeams[user.UserID]
+		}
+		user.LastDigestAt = existing.LastDigestAt
+		if err := tx.Users().Update(&user); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, team := range plan.teams {
+		for _, member := range team.Members {
+			if err := tx.Teams().AddMembership(team.TeamName, member.UserID, member.Role, member.IsActive); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, membership := range plan.deactivated {
+		if err := tx.Teams().AddMembership(membership.TeamName, membership.UserID, "", false); err != nil {
+			return nil, err
+		}
+	}
+
+	var handoffs []models.ReviewHandoff
+	for _, membership := range plan.deactivated {
+		teamHandoffs, err := s.prService.handOffReviews(tx, membership.UserID, membership.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		handoffs = append(handoffs, teamHandoffs...)
+	}
+
+	return handoffs, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDesiredUserDefaults(t *testing.T) {
+	user := desiredUser(models.TeamMember{UserID: "u1", Username: "Alice", IsActive: true, Capacity: 2})
+	assert.Equal(t, models.User{
+		UserID:    "u1",
+		Username:  "Alice",
+		IsActive:  true,
+		Timezone:  "UTC",
+		WorkStart: "09:00",
+		WorkEnd:   "18:00",
+		Capacity:  2,
+	}, user)
+}
+
+func TestProfileDiff(t *testing.T) {
+	until := time.Date(2026, 11, 16, 0, 0, 0, 0, time.UTC)
+	sameUntil := until.In(time.FixedZone("UTC+3", 3*60*60))
+	have := models.User{UserID: "u1", Username: "Alice", Timezone: "UTC", WorkStart: "09:00", WorkEnd: "18:00", OOOUntil: &until}
+
+	want := have
+	want.OOOUntil = &sameUntil
+	want.IsActive = true
+	assert.Empty(t, profileDiff(have, want))
+
+	want.Username = "Alice A."
+	want.Capacity = 4
+	want.OOOUntil = nil
+	assert.Equal(t, []string{"username", "capacity", "ooo_until"}, profileDiff(have, want))
+}
+
+func TestTeamDiff(t *testing.T) {
+	have := models.TeamDB{TeamName: "backend", MaxReviewers: 2, SLAHours: 8, SLAPolicy: models.SLAPolicyEscalate, Strategy: models.StrategyRandom, DigestTime: "09:30"}
+	team := models.Team{TeamName: "backend", SLAHours: 8}
+	applyTeamDefaults(&team)
+
+	assert.Equal(t, []string{"digest_time"}, teamDiff(have, team))
+
+	team.DigestTime = "09:30"
+	team.ParentTeamName = "engineering"
+	team.MinReviewers = 1
+	assert.Equal(t, []string{"parent_team_name", "min_reviewers"}, teamDiff(have, team))
+}
@@ -0,0 +1,682 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/pagination"
+	"prReviewerAssignment/internal/repository"
+	"prReviewerAssignment/internal/workhours"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SCIMService maps SCIM 2.0 Users onto users and Groups onto teams.
+// Attributes a request leaves out are kept as they are, since teams and
+// profiles are also managed through the rest of the API. Users are never
+// deleted: deleting one deactivates it.
+type SCIMService struct {
+	store       repository.Store
+	userService *UserService
+	teamService *TeamService
+}
+
+func NewSCIMService() *SCIMService {
+	return &SCIMService{store: db.Store, userService: NewUserService(), teamService: NewTeamService()}
+}
+
+// scimFilterPattern matches the only filter identity providers use for
+// lookups: attribute eq "value".
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*([a-z.]+)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseSCIMFilter returns the value an eq filter on attribute asks for, or
+// nil for an empty filter.
+func parseSCIMFilter(filter string, attribute string) (*string, error) {
+	if strings.TrimSpace(filter) == "" {
+		return nil, nil
+	}
+
+	match := scimFilterPattern.FindStringSubmatch(filter)
+	if match == nil || !strings.EqualFold(match[1], attribute) {
+		return nil, ErrInvalidFilter
+	}
+	var value string
+	if err := json.Unmarshal([]byte(match[2]), &value); err != nil {
+		return nil, ErrInvalidFilter
+	}
+
+	return &value, nil
+}
+
+// scimPage turns startIndex and count into an offset and limit: startIndex
+// counts from 1 and count is capped at pagination.MaxLimit.
+func scimPage(startIndex int, count int) (int, int) {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	if count < 0 {
+		count = 0
+	}
+	if count > pagination.MaxLimit {
+		count = pagination.MaxLimit
+	}
+	return startIndex, count
+}
+
+func (s *SCIMService) ListUsers(filter string, startIndex int, count int) (*models.SCIMListResponse, error) {
+	userName, err := parseSCIMFilter(filter, "userName")
+	if err != nil {
+		return nil, err
+	}
+	startIndex, count = scimPage(startIndex, count)
+
+	var users []models.User
+	var total int
+	if userName != nil {
+		users, err = s.store.Users().Find([]string{*userName})
+		if err != nil {
+			return nil, err
+		}
+		total = len(users)
+		users = scimWindow(users, startIndex, count)
+	} else {
+		total, err = s.store.Users().Count(repository.UserQuery{})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			users, err = s.store.Users().List(repository.UserQuery{Page: repository.Page{Offset: startIndex - 1, Limit: count}})
+			if err != nil {
+				return nil, err
+			}
+			users = scimWindow(users, 1, count)
+		}
+	}
+
+	resources := []models.SCIMUser{}
+	for _, user := range users {
+		teams, err := s.userTeams(user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, toSCIMUser(user, teams))
+	}
+
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+// scimWindow returns the page of items that starts at startIndex, counting
+// from 1, and holds at most count items.
+func scimWindow[T any](items []T, startIndex int, count int) []T {
+	if startIndex > len(items) {
+		return nil
+	}
+	items = items[startIndex-1:]
+	if len(items) > count {
+		items = items[:count]
+	}
+	return items
+}
+
+func (s *SCIMService) GetUser(id string) (*models.SCIMUser, error) {
+	user, err := s.store.Users().Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	teams, err := s.userTeams(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := toSCIMUser(*user, teams)
+	return &resource, nil
+}
+
+func (s *SCIMService) userTeams(userID string) ([]string, error) {
+	memberships, err := s.store.Teams().Memberships(repository.MembershipQuery{UserIDs: []string{userID}})
+	if err != nil {
+		return nil, err
+	}
+
+	var teams []string
+	for _, membership := range memberships {
+		teams = append(teams, membership.TeamName)
+	}
+	return teams, nil
+}
+
+// CreateUser creates a user that belongs to no team yet; groups add it to
+// teams.
+func (s *SCIMService) CreateUser(resource models.SCIMUser) (*models.SCIMUser, error) {
+	user, err := userFromSCIM(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.store.Users().Get(user.UserID)
+	if err == nil {
+		return nil, ErrUserExists
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	if err := s.store.Users().Create(&user); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(user.UserID)
+}
+
+// ReplaceUser updates the profile from a full User resource. The userName
+// cannot change, and a change of active goes through
+// UserService.SetUserActive.
+func (s *SCIMService) ReplaceUser(id string, resource models.SCIMUser) (*models.SCIMUser, error) {
+	existing, err := s.store.Users().Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	if resource.UserName == "" {
+		resource.UserName = id
+	}
+	user, err := userFromSCIM(resource)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserID != id {
+		return nil, ErrUserNameImmutable
+	}
+
+	changed := false
+	if resource.DisplayName != "" || resource.Name != nil {
+		existing.Username = user.Username
+		changed = true
+	}
+	if resource.Emails != nil {
+		existing.Email = user.Email
+		changed = true
+	}
+	if user.Timezone != "" {
+		existing.Timezone = user.Timezone
+		changed = true
+	}
+	if changed {
+		if err := s.store.Users().Update(existing); err != nil {
+			return nil, err
+		}
+	}
+
+	if resource.Active != nil && *resource.Active != existing.IsActive {
+		if _, err := s.userService.SetUserActive(id, *resource.Active); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetUser(id)
+}
+
+func (s *SCIMService) PatchUser(id string, patch models.SCIMPatchRequest) (*models.SCIMUser, error) {
+	resource, err := s.GetUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyUserPatch(resource, patch.Operations); err != nil {
+		return nil, err
+	}
+
+	return s.ReplaceUser(id, *resource)
+}
+
+// DeleteUser deactivates the user.
+func (s *SCIMService) DeleteUser(id string) error {
+	_, err := s.userService.SetUserActive(id, false)
+	return err
+}
+
+// userFromSCIM maps a User resource onto the user fields it controls. The
+// username comes from displayName, then the full name, then userName.
+func userFromSCIM(resource models.SCIMUser) (models.User, error) {
+	if resource.UserName == "" {
+		return models.User{}, ErrUserNameRequired
+	}
+
+	user := models.User{
+		UserID:   resource.UserName,
+		Username: resource.DisplayName,
+		IsActive: resource.Active == nil || *resource.Active,
+		Timezone: resource.Timezone,
+	}
+	if user.Username == "" && resource.Name != nil {
+		user.Username = resource.Name.Formatted
+		if user.Username == "" {
+			user.Username = strings.TrimSpace(resource.Name.GivenName + " " + resource.Name.FamilyName)
+		}
+	}
+	if user.Username == "" {
+		user.Username = resource.UserName
+	}
+
+	for _, email := range resource.Emails {
+		if user.Email == "" || email.Primary {
+			user.Email = email.Value
+		}
+	}
+
+	if user.Timezone != "" {
+		if _, err := workhours.Parse(user.Timezone, "", ""); err != nil {
+			return models.User{}, ErrInvalidTimezone
+		}
+	}
+
+	return user, nil
+}
+
+func toSCIMUser(user models.User, teams []string) models.SCIMUser {
+	active := user.IsActive
+	resource := models.SCIMUser{
+		Schemas:     []string{models.SCIMUserSchema},
+		ID:          user.UserID,
+		UserName:    user.UserID,
+		DisplayName: user.Username,
+		Active:      &active,
+		Timezone:    user.Timezone,
+		Meta:        &models.SCIMMeta{ResourceType: "User", Location: "/scim/v2/Users/" + url.PathEscape(user.UserID)},
+	}
+	if user.Email != "" {
+		resource.Emails = []models.SCIMValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	for _, team := range teams {
+		resource.Groups = append(resource.Groups, models.SCIMValue{Value: team, Display: team})
+	}
+	return resource
+}
+
+// applyUserPatch applies PatchOp operations to a User resource. Attributes
+// the service does not map, such as extension attributes, are ignored.
+func applyUserPatch(resource *models.SCIMUser, operations []models.SCIMPatchOperation) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		switch {
+		case op == "remove" && operation.Path != "":
+			removeUserAttribute(resource, operation.Path)
+		case (op == "add" || op == "replace") && operation.Path != "":
+			if err := setUserAttribute(resource, operation.Path, operation.Value); err != nil {
+				return err
+			}
+		case op == "add" || op == "replace":
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return ErrInvalidPatch
+			}
+			for path, value := range values {
+				if err := setUserAttribute(resource, path, value); err != nil {
+					return err
+				}
+			}
+		default:
+			return ErrInvalidPatch
+		}
+	}
+	return nil
+}
+
+func setUserAttribute(resource *models.SCIMUser, path string, value json.RawMessage) error {
+	attribute := strings.ToLower(strings.TrimPrefix(path, models.SCIMUserSchema+":"))
+	var err error
+	switch {
+	case attribute == "active":
+		var active bool
+		active, err = scimBool(value)
+		resource.Active = &active
+	case attribute == "username":
+		err = json.Unmarshal(value, &resource.UserName)
+	case attribute == "displayname":
+		err = json.Unmarshal(value, &resource.DisplayName)
+	case attribute == "timezone":
+		err = json.Unmarshal(value, &resource.Timezone)
+	case attribute == "name":
+		resource.Name = &models.SCIMName{}
+		err = json.Unmarshal(value, resource.Name)
+	case strings.HasPrefix(attribute, "name."):
+		if resource.Name == nil {
+			resource.Name = &models.SCIMName{}
+		}
+		switch attribute {
+		case "name.formatted":
+			err = json.Unmarshal(value, &resource.Name.Formatted)
+		case "name.givenname":
+			err = json.Unmarshal(value, &resource.Name.GivenName)
+		case "name.familyname":
+			err = json.Unmarshal(value, &resource.Name.FamilyName)
+		}
+	case attribute == "emails":
+		err = json.Unmarshal(value, &resource.Emails)
+	case strings.HasPrefix(attribute, "emails["):
+		var email string
+		err = json.Unmarshal(value, &email)
+		resource.Emails = []models.SCIMValue{{Value: email, Type: "work", Primary: true}}
+	}
+	if err != nil {
+		return ErrInvalidPatch
+	}
+	return nil
+}
+
+func removeUserAttribute(resource *models.SCIMUser, path string) {
+	attribute := strings.ToLower(strings.TrimPrefix(path, models.SCIMUserSchema+":"))
+	switch {
+	case attribute == "displayname":
+		resource.DisplayName = ""
+	case attribute == "name":
+		resource.Name = nil
+	case attribute == "emails" || strings.HasPrefix(attribute, "emails["):
+		resource.Emails = []models.SCIMValue{}
+	}
+}
+
+// scimBool reads a boolean that some identity providers send as a string
+// such as "False".
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(strings.ToLower(s))
+}
+
+func (s *SCIMService) ListGroups(filter string, startIndex int, count int) (*models.SCIMListResponse, error) {
+	displayName, err := parseSCIMFilter(filter, "displayName")
+	if err != nil {
+		return nil, err
+	}
+	startIndex, count = scimPage(startIndex, count)
+
+	var teams []models.TeamDB
+	var total int
+	if displayName != nil {
+		team, err := s.store.Teams().Get(*displayName)
+		if err == nil {
+			teams = []models.TeamDB{*team}
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+		total = len(teams)
+		teams = scimWindow(teams, startIndex, count)
+	} else {
+		total, err = s.store.Teams().Count(repository.TeamQuery{})
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			teams, err = s.store.Teams().List(repository.TeamQuery{Page: repository.Page{Offset: startIndex - 1, Limit: count}})
+			if err != nil {
+				return nil, err
+			}
+			teams = scimWindow(teams, 1, count)
+		}
+	}
+
+	resources := []models.SCIMGroup{}
+	for _, team := range teams {
+		members, err := teamMembers(s.store, team.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, toSCIMGroup(team.TeamName, members))
+	}
+
+	return &models.SCIMListResponse{
+		Schemas:      []string{models.SCIMListSchema},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}, nil
+}
+
+func (s *SCIMService) GetGroup(id string) (*models.SCIMGroup, error) {
+	_, err := s.store.Teams().Get(id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTeamNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	members, err := teamMembers(s.store, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := toSCIMGroup(id, members)
+	return &resource, nil
+}
+
+// CreateGroup creates a team with the default settings. Members have to be
+// provisioned as users first.
+func (s *SCIMService) CreateGroup(resource models.SCIMGroup) (*models.SCIMGroup, error) {
+	if resource.DisplayName == "" {
+		return nil, ErrDisplayNameRequired
+	}
+	memberIDs := scimMemberIDs(resource.Members)
+	if err := s.checkUsersExist(memberIDs); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.teamService.CreateTeam(models.Team{TeamName: resource.DisplayName}); err != nil {
+		return nil, err
+	}
+	if _, err := s.teamService.ChangeMembers(resource.DisplayName, memberIDs, nil); err != nil {
+		return nil, err
+	}
+
+	return s.GetGroup(resource.DisplayName)
+}
+
+// ReplaceGroup makes the team's members match the resource. Teams cannot be
+// renamed.
+func (s *SCIMService) ReplaceGroup(id string, resource models.SCIMGroup) (*models.SCIMGroup, error) {
+	current, err := s.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if resource.DisplayName != "" && resource.DisplayName != id {
+		return nil, ErrDisplayNameImmutable
+	}
+
+	return s.syncGroupMembers(current, scimMemberIDs(resource.Members))
+}
+
+func (s *SCIMService) PatchGroup(id string, patch models.SCIMPatchRequest) (*models.SCIMGroup, error) {
+	current, err := s.GetGroup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := *current
+	patched.Members = append([]models.SCIMValue{}, current.Members...)
+	if err := applyGroupPatch(&patched, patch.Operations); err != nil {
+		return nil, err
+	}
+	if patched.DisplayName != id {
+		return nil, ErrDisplayNameImmutable
+	}
+
+	return s.syncGroupMembers(current, scimMemberIDs(patched.Members))
+}
+
+func (s *SCIMService) syncGroupMembers(current *models.SCIMGroup, memberIDs []string) (*models.SCIMGroup, error) {
+	wanted := make(map[string]bool)
+	for _, userID := range memberIDs {
+		wanted[userID] = true
+	}
+	existing := make(map[string]bool)
+	var remove []string
+	for _, member := range current.Members {
+		existing[member.Value] = true
+		if !wanted[member.Value] {
+			remove = append(remove, member.Value)
+		}
+	}
+	var add []string
+	for _, userID := range memberIDs {
+		if !existing[userID] {
+			add = append(add, userID)
+		}
+	}
+
+	if err := s.checkUsersExist(add); err != nil {
+		return nil, err
+	}
+	if len(add) > 0 || len(remove) > 0 {
+		if _, err := s.teamService.ChangeMembers(current.ID, add, remove); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetGroup(current.ID)
+}
+
+// DeleteGroup deletes the team with the BLOCK policy, so a team with open
+// pull requests is kept.
+func (s *SCIMService) DeleteGroup(id string) error {
+	_, err := s.teamService.DeleteTeam(models.DeleteTeamRequest{TeamName: id, Policy: models.DeletePolicyBlock})
+	return err
+}
+
+func (s *SCIMService) checkUsersExist(userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	users, err := s.store.Users().Find(userIDs)
+	if err != nil {
+		return err
+	}
+	if len(users) != len(userIDs) {
+		return ErrUnknownMember
+	}
+	return nil
+}
+
+func toSCIMGroup(teamName string, members []models.TeamMember) models.SCIMGroup {
+	resource := models.SCIMGroup{
+		Schemas:     []string{models.SCIMGroupSchema},
+		ID:          teamName,
+		DisplayName: teamName,
+		Meta:        &models.SCIMMeta{ResourceType: "Group", Location: "/scim/v2/Groups/" + url.PathEscape(teamName)},
+	}
+	for _, member := range members {
+		resource.Members = append(resource.Members, models.SCIMValue{Value: member.UserID, Display: member.Username})
+	}
+	return resource
+}
+
+// scimMemberIDs returns the distinct user ids of members in order.
+func scimMemberIDs(members []models.SCIMValue) []string {
+	seen := make(map[string]bool)
+	var userIDs []string
+	for _, member := range members {
+		if member.Value != "" && !seen[member.Value] {
+			seen[member.Value] = true
+			userIDs = append(userIDs, member.Value)
+		}
+	}
+	return userIDs
+}
+
+// scimMemberFilterPattern matches a path selecting one member:
+// members[value eq "id"].
+var scimMemberFilterPattern = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+("(?:[^"\\]|\\.)*")\s*\]$`)
+
+// applyGroupPatch applies PatchOp operations to a Group resource. Besides
+// displayName only members can be changed; other attributes are ignored.
+func applyGroupPatch(resource *models.SCIMGroup, operations []models.SCIMPatchOperation) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		path := strings.ToLower(operation.Path)
+
+		var members []models.SCIMValue
+		if path == "members" && len(operation.Value) > 0 {
+			if err := json.Unmarshal(operation.Value, &members); err != nil {
+				return ErrInvalidPatch
+			}
+		}
+
+		switch {
+		case op == "add" && path == "members":
+			resource.Members = append(resource.Members, members...)
+		case op == "replace" && path == "members":
+			resource.Members = members
+		case op == "remove" && path == "members":
+			if len(operation.Value) == 0 {
+				resource.Members = nil
+			} else {
+				resource.Members = withoutMembers(resource.Members, scimMemberIDs(members))
+			}
+		case op == "remove" && scimMemberFilterPattern.MatchString(operation.Path):
+			var userID string
+			quoted := scimMemberFilterPattern.FindStringSubmatch(operation.Path)[1]
+			if err := json.Unmarshal([]byte(quoted), &userID); err != nil {
+				return ErrInvalidPatch
+			}
+			resource.Members = withoutMembers(resource.Members, []string{userID})
+		case (op == "add" || op == "replace") && path == "displayname":
+			if err := json.Unmarshal(operation.Value, &resource.DisplayName); err != nil {
+				return ErrInvalidPatch
+			}
+		case (op == "add" || op == "replace") && path == "":
+			var values struct {
+				DisplayName *string             `json:"displayName"`
+				Members     *[]models.SCIMValue `json:"members"`
+			}
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return ErrInvalidPatch
+			}
+			if values.DisplayName != nil {
+				resource.DisplayName = *values.DisplayName
+			}
+			if values.Members != nil && op == "add" {
+				resource.Members = append(resource.Members, *values.Members...)
+			} else if values.Members != nil {
+				resource.Members = *values.Members
+			}
+		case op == "add" || op == "replace" || op == "remove":
+		default:
+			return ErrInvalidPatch
+		}
+	}
+	return nil
+}
+
+func withoutMembers(members []models.SCIMValue, userIDs []string) []models.SCIMValue {
+	removed := make(map[string]bool)
+	for _, userID := range userIDs {
+		removed[userID] = true
+	}
+
+	var remaining []models.SCIMValue
+	for _, member := range members {
+		if !removed[member.Value] {
+			remaining = append(remaining, member)
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"prReviewerAssignment/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// loadSCIMRequest decodes a request body captured from an identity provider.
+func loadSCIMRequest(t *testing.T, name string, request interface{}) {
+	data, err := os.ReadFile(filepath.Join("testdata", "scim", name))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, request))
+}
+
+func TestUserFromSCIM(t *testing.T) {
+	var okta models.SCIMUser
+	loadSCIMRequest(t, "okta_create_user.json", &okta)
+	user, err := userFromSCIM(okta)
+	assert.NoError(t, err)
+	assert.Equal(t, models.User{
+		UserID:   "alice@example.com",
+		Username: "Alice Liddell",
+		IsActive: true,
+		Email:    "alice@example.com",
+	}, user)
+
+	var azure models.SCIMUser
+	loadSCIMRequest(t, "azure_create_user.json", &azure)
+	user, err = userFromSCIM(azure)
+	assert.NoError(t, err)
+	assert.Equal(t, models.User{
+		UserID:   "bob@contoso.com",
+		Username: "Bob Builder",
+		IsActive: false,
+		Email:    "bob@contoso.com",
+	}, user)
+
+	_, err = userFromSCIM(models.SCIMUser{DisplayName: "Nobody"})
+	assert.ErrorIs(t, err, ErrUserNameRequired)
+	_, err = userFromSCIM(models.SCIMUser{UserName: "u1", Timezone: "Mars/Olympus"})
+	assert.ErrorIs(t, err, ErrInvalidTimezone)
+}
+
+func TestApplyUserPatch(t *testing.T) {
+	for _, name := range []string{"azure_patch_user_deactivate.json", "okta_patch_user_deactivate.json"} {
+		active := true
+		resource := models.SCIMUser{UserName: "alice@example.com", Active: &active}
+
+		var patch models.SCIMPatchRequest
+		loadSCIMRequest(t, name, &patch)
+		assert.NoError(t, applyUserPatch(&resource, patch.Operations), name)
+		if assert.NotNil(t, resource.Active, name) {
+			assert.False(t, *resource.Active, name)
+		}
+	}
+
+	resource := toSCIMUser(models.User{UserID: "alice@example.com", Username: "Alice Liddell", IsActive: true, Email: "alice@example.com"}, nil)
+	var patch models.SCIMPatchRequest
+	loadSCIMRequest(t, "azure_patch_user_attributes.json", &patch)
+	assert.NoError(t, applyUserPatch(&resource, patch.Operations))
+
+	user, err := userFromSCIM(resource)
+	assert.NoError(t, err)
+	assert.Equal(t, "Alice Kingsleigh", user.Username)
+	assert.Equal(t, "alice.k@example.com", user.Email)
+
+	err = applyUserPatch(&resource, []models.SCIMPatchOperation{{Op: "move", Path: "active"}})
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+	err = applyUserPatch(&resource, []models.SCIMPatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}})
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestApplyGroupPatch(t *testing.T) {
+	var create models.SCIMGroup
+	loadSCIMRequest(t, "okta_create_group.json", &create)
+	assert.Equal(t, []string{"alice@example.com"}, scimMemberIDs(create.Members))
+
+	group := create
+	for _, step := range []struct {
+		name    string
+		members []string
+	}{
+		{"okta_patch_group_members.json", []string{"bob@contoso.com", "carol@example.com"}},
+		{"azure_patch_group_remove_members.json", []string{"carol@example.com"}},
+		{"azure_patch_group_replace.json", []string{"carol@example.com"}},
+	} {
+		var patch models.SCIMPatchRequest
+		loadSCIMRequest(t, step.name, &patch)
+		assert.NoError(t, applyGroupPatch(&group, patch.Operations), step.name)
+		assert.Equal(t, step.members, scimMemberIDs(group.Members), step.name)
+		assert.Equal(t, "backend", group.DisplayName, step.name)
+	}
+
+	err := applyGroupPatch(&group, []models.SCIMPatchOperation{{Op: "add", Path: "members", Value: json.RawMessage(`"carol"`)}})
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestParseSCIMFilter(t *testing.T) {
+	value, err := parseSCIMFilter(`userName eq "alice@example.com"`, "userName")
+	assert.NoError(t, err)
+	if assert.NotNil(t, value) {
+		assert.Equal(t, "alice@example.com", *value)
+	}
+
+	value, err = parseSCIMFilter(`DISPLAYNAME eq "team \"a\""`, "displayName")
+	assert.NoError(t, err)
+	if assert.NotNil(t, value) {
+		assert.Equal(t, `team "a"`, *value)
+	}
+
+	value, err = parseSCIMFilter("", "userName")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	for _, filter := range []string{`emails eq "a@example.com"`, `userName sw "a"`, `userName eq "a" or userName eq "b"`} {
+		_, err := parseSCIMFilter(filter, "userName")
+		assert.ErrorIs(t, err, ErrInvalidFilter, filter)
+	}
+}
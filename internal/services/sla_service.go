@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"prReviewerAssignment/internal/clock"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/repository"
+	"prReviewerAssignment/internal/workhours"
+	"time"
+)
+
+type SLAService struct {
+	store     repository.Store
+	prService *PRService
+	notifier  notify.Notifier
+	clock     clock.Clock
+}
+
+func NewSLAService() *SLAService {
+	return &SLAService{
+		store:     db.Store,
+		prService: NewPRService(),
+		notifier:  notify.Default,
+		clock:     clock.Real{},
+	}
+}
+
+type slaBreach struct {
+	PR         models.PullRequest
+	Team       models.TeamDB
+	ReviewerID string
+	AssignedAt time.Time
+}
+
+// CheckBreaches finds reviewers on OPEN PRs who have been assigned for longer
+// than their team's SLA and applies the team's SLA policy to each of them.
+// Every assignment is escalated at most once; a reassignment starts a fresh
+// assignment for the new reviewer.
+func (s *SLAService) CheckBreaches(ctx context.Context) error {
+	teams, err := s.store.Teams().List(repository.TeamQuery{})
+	if err != nil {
+		return err
+	}
+
+	teamsByName := make(map[string]models.TeamDB)
+	for _, team := range teams {
+		if team.SLAHours > 0 {
+			teamsByName[team.TeamName] = team
+		}
+	}
+	if len(teamsByName) == 0 {
+		return nil
+	}
+
+	prs, err := s.store.PullRequests().List(repository.PullRequestQuery{Status: "OPEN"})
+	if err != nil {
+		return err
+	}
+	if len(prs) == 0 {
+		return nil
+	}
+
+	var prIDs, userIDs []string
+	for _, pr := range prs {
+		prIDs = append(prIDs, pr.PullRequestID)
+
+		var reviewers []string
+		if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err == nil {
+			userIDs = append(userIDs, reviewers...)
+		}
+	}
+
+	users, err := s.store.Users().Find(userIDs)
+	if err != nil {
+		return err
+	}
+	windows := make(map[string]workhours.Window)
+	for _, user := range users {
+		windows[user.UserID] = userWindow(user)
+	}
+
+	assignments, err := s.store.PullRequests().Assignments(prIDs)
+	if err != nil {
+		return err
+	}
+
+	now := s.clock.Now()
+	breaches := findBreaches(now, teamsByName, prs, windows, assignments)
+
+	var errs []error
+	for _, breach := range breaches {
+		if err := s.escalate(ctx, breach, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// findBreaches counts only the business hours of each reviewer's own working
+// window; reviewers without one use workhours.Default.
+func findBreaches(now time.Time, teams map[string]models.TeamDB, prs []models.PullRequest, windows map[string]workhours.Window, assignments []models.ReviewAssignment) []slaBreach {
+	assigned := make(map[string]models.ReviewAssignment)
+	for _, assignment := range assignments {
+		assigned[assignment.PullRequestID+"/"+assignment.ReviewerID] = assignment
+	}
+
+	var breaches []slaBreach
+	for _, pr := range prs {
+		team, ok := teams[pr.TeamName]
+		if !ok || team.SLAHours <= 0 {
+			continue
+		}
+
+		var reviewers []string
+		if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
+			continue
+		}
+
+		for _, reviewer := range reviewers {
+			// PRs created before assignments were tracked fall back to the
+			// PR creation time.
+			assignedAt := pr.CreatedAt
+			if assignment, ok := assigned[pr.PullRequestID+"/"+reviewer]; ok {
+				if assignment.EscalatedAt != nil {
+					continue
+				}
+				assignedAt = assignment.AssignedAt
+			}
+
+			window, ok := windows[reviewer]
+			if !ok {
+				window = workhours.Default
+			}
+
+			elapsed := window.BusinessHoursBetween(assignedAt, now)
+			if elapsed < time.Duration(team.SLAHours)*time.Hour {
+				continue
+			}
+
+			breaches = append(breaches, slaBreach{
+				PR:         pr,
+				Team:       team,
+				ReviewerID: reviewer,
+				AssignedAt: assignedAt,
+			})
+		}
+	}
+
+	return breaches
+}
+
+func (s *SLAService) escalate(ctx context.Context, breach slaBreach, now time.Time) error {
+	event := notify.Event{
+		Type:            notify.EventSLABreached,
+		TeamName:        breach.Team.TeamName,
+		PullRequestID:   breach.PR.PullRequestID,
+		PullRequestName: breach.PR.PullRequestName,
+		AuthorID:        breach.PR.AuthorID,
+		ReviewerID:      breach.ReviewerID,
+		Recipients:      []string{breach.ReviewerID},
+		OccurredAt:      now,
+	}
+
+	switch breach.Team.SLAPolicy {
+	case models.SLAPolicyReassign:
+		_, newReviewer, err := s.prService.ReassignReviewer(breach.PR.PullRequestID, breach.ReviewerID, 0)
+		if err == nil {
+			log.Printf("SLA breach on %s: reviewer %s replaced by %s", breach.PR.PullRequestID, breach.ReviewerID, newReviewer)
+			return nil
+		}
+		log.Printf("SLA breach on %s: could not reassign %s, escalating instead: %v", breach.PR.PullRequestID, breach.ReviewerID, err)
+	case models.SLAPolicyNotifyLead:
+		// A team without active leads falls back to escalating to the
+		// reviewer.
+		leads, err := teamLeads(s.store, breach.Team.TeamName)
+		if err != nil {
+			return err
+		}
+		if len(leads) > 0 {
+			event.Recipients = leads
+		}
+	}
+
+	if err := s.notifier.Notify(ctx, event); err != nil {
+		return err
+	}
+
+	assignment := models.ReviewAssignment{
+		PullRequestID: breach.PR.PullRequestID,
+		ReviewerID:    breach.ReviewerID,
+		AssignedAt:    breach.AssignedAt,
+		EscalatedAt:   &now,
+	}
+	return s.store.PullRequests().SaveAssignment(&assignment)
+}
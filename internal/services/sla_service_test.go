@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/clock"
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/workhours"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+)
+
+func TestFindBreaches(t *testing.T) {
+	// Monday 09:00 UTC.
+	fake := clock.NewFake(time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC))
+	created := fake.Now()
+
+	teams := map[string]models.TeamDB{
+		"backend":  {TeamName: "backend", SLAHours: 8, SLAPolicy: models.SLAPolicyEscalate},
+		"frontend": {TeamName: "frontend", SLAHours: 0},
+	}
+	prs := []models.PullRequest{
+		{PullRequestID: "pr-1", AuthorID: "u1", TeamName: "backend", Status: "OPEN", AssignedReviewers: datatypes.JSON(`["u2","u3","u4"]`), CreatedAt: created},
+		{PullRequestID: "pr-2", AuthorID: "u9", TeamName: "frontend", Status: "OPEN", AssignedReviewers: datatypes.JSON(`["u8"]`), CreatedAt: created},
+	}
+	escalatedAt := created.Add(time.Hour)
+	assignments := []models.ReviewAssignment{
+		{PullRequestID: "pr-1", ReviewerID: "u2", AssignedAt: created},
+		{PullRequestID: "pr-1", ReviewerID: "u3", AssignedAt: created.Add(4 * time.Hour)},
+		{PullRequestID: "pr-1", ReviewerID: "u4", AssignedAt: created, EscalatedAt: &escalatedAt},
+	}
+
+	fake.Advance(7 * time.Hour)
+	assert.Empty(t, findBreaches(fake.Now(), teams, prs, nil, assignments))
+
+	fake.Advance(time.Hour)
+	breaches := findBreaches(fake.Now(), teams, prs, nil, assignments)
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "u2", breaches[0].ReviewerID)
+		assert.Equal(t, "backend", breaches[0].Team.TeamName)
+	}
+
+	// Overnight and weekend hours do not count towards the SLA; u3 only
+	// reaches eight business hours on Tuesday at 12:00.
+	fake.Advance(18 * time.Hour)
+	breaches = findBreaches(fake.Now(), teams, prs, nil, assignments)
+	assert.Len(t, breaches, 1)
+
+	fake.Advance(time.Hour)
+	breaches = findBreaches(fake.Now(), teams, prs, nil, assignments)
+	assert.Len(t, breaches, 2)
+}
+
+func TestFindBreachesUsesReviewerTimezone(t *testing.T) {
+	// Monday 09:00 UTC is 01:00 in San Francisco and 12:00 in Moscow.
+	now := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(now)
+
+	teams := map[string]models.TeamDB{
+		"backend": {TeamName: "backend", SLAHours: 4, SLAPolicy: models.SLAPolicyEscalate},
+	}
+	prs := []models.PullRequest{
+		{PullRequestID: "pr-1", AuthorID: "u1", TeamName: "backend", Status: "OPEN", AssignedReviewers: datatypes.JSON(`["sf","msk"]`), CreatedAt: now},
+	}
+
+	sf, err := workhours.Parse("America/Los_Angeles", "09:00", "17:00")
+	assert.NoError(t, err)
+	msk, err := workhours.Parse("Europe/Moscow", "09:00", "18:00")
+	assert.NoError(t, err)
+	windows := map[string]workhours.Window{"sf": sf, "msk": msk}
+
+	fake.Advance(6 * time.Hour)
+	breaches := findBreaches(fake.Now(), teams, prs, windows, nil)
+	if assert.Len(t, breaches, 1) {
+		assert.Equal(t, "msk", breaches[0].ReviewerID)
+	}
+}
+
+// recordingNotifier keeps the events it is sent.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []notify.Event
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, event notify.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.events = append(n.events, event)
+	return nil
+}
+
+// breaches returns the recipients of every SLA breach event by reviewer and
+// forgets them.
+func (n *recordingNotifier) breaches() map[string][]string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	recipients := make(map[string][]string)
+	for _, event := range n.events {
+		if event.Type == notify.EventSLABreached {
+			recipients[event.ReviewerID] = event.Recipients
+		}
+	}
+	n.events = nil
+	return recipients
+}
+
+// newSLATest creates the team, opens pr-1 by u1 with its reviewers assigned
+// on Monday 09:00 and returns an SLAService whose clock stands eight business
+// hours later, when the team's SLA is breached.
+func newSLATest(t *testing.T, team models.Team) (*SLAService, *recordingNotifier) {
+	useMemoryStore(t)
+	team.SLAHours = 8
+	_, err := NewTeamService().CreateTeam(team)
+	require.NoError(t, err)
+	pr, err := NewPRService().CreatePullRequest(models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Add search", AuthorID: "u1"})
+	require.NoError(t, err)
+
+	monday := time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)
+	for _, reviewer := range assignedReviewers(t, pr) {
+		require.NoError(t, db.Store.PullRequests().SaveAssignment(&models.ReviewAssignment{PullRequestID: "pr-1", ReviewerID: reviewer, AssignedAt: monday}))
+	}
+
+	notifier := &recordingNotifier{}
+	service := &SLAService{store: db.Store, prService: NewPRService(), notifier: notifier, clock: clock.NewFake(monday.Add(8 * time.Hour))}
+	return service, notifier
+}
+
+func slaMembers(lead string, userIDs ...string) []models.TeamMember {
+	var members []models.TeamMember
+	for _, userID := range userIDs {
+		member := models.TeamMember{UserID: userID, Username: userID, IsActive: true}
+		if userID == lead {
+			member.Role = models.MembershipRoleLead
+		}
+		members = append(members, member)
+	}
+	return members
+}
+
+func TestCheckBreachesEscalate(t *testing.T) {
+	service, notifier := newSLATest(t, models.Team{TeamName: "backend", MinReviewers: 1, MaxReviewers: 2,
+		SLAPolicy: models.SLAPolicyEscalate, Members: slaMembers("u1", "u1", "u2", "u3")})
+
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Equal(t, map[string][]string{"u2": {"u2"}, "u3": {"u3"}}, notifier.breaches())
+
+	assignments, err := db.Store.PullRequests().Assignments([]string{"pr-1"})
+	require.NoError(t, err)
+	for _, assignment := range assignments {
+		assert.NotNil(t, assignment.EscalatedAt, assignment.ReviewerID)
+	}
+
+	// Every assignment is escalated once.
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Empty(t, notifier.breaches())
+}
+
+func TestCheckBreachesNotifyLead(t *testing.T) {
+	service, notifier := newSLATest(t, models.Team{TeamName: "backend", MinReviewers: 1, MaxReviewers: 2,
+		SLAPolicy: models.SLAPolicyNotifyLead, Members: slaMembers("u1", "u1", "u2", "u3")})
+
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Equal(t, map[string][]string{"u2": {"u1"}, "u3": {"u1"}}, notifier.breaches())
+
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Empty(t, notifier.breaches())
+}
+
+func TestCheckBreachesNotifyLeadWithoutActiveLead(t *testing.T) {
+	service, notifier := newSLATest(t, models.Team{TeamName: "backend", MinReviewers: 1, MaxReviewers: 2,
+		SLAPolicy: models.SLAPolicyNotifyLead, Members: slaMembers("u1", "u1", "u2", "u3")})
+	_, err := NewUserService().SetUserActive("u1", false)
+	require.NoError(t, err)
+
+	// The only lead is inactive, so each reviewer is escalated to.
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Equal(t, map[string][]string{"u2": {"u2"}, "u3": {"u3"}}, notifier.breaches())
+}
+
+func TestCheckBreachesReassign(t *testing.T) {
+	service, notifier := newSLATest(t, models.Team{TeamName: "backend", MinReviewers: 1, MaxReviewers: 1,
+		SLAPolicy: models.SLAPolicyReassign, Members: slaMembers("", "u1", "u2", "u3")})
+	pr, err := db.Store.PullRequests().Get("pr-1")
+	require.NoError(t, err)
+	breached := assignedReviewers(t, pr)[0]
+
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Empty(t, notifier.breaches())
+	pr, err = db.Store.PullRequests().Get("pr-1")
+	require.NoError(t, err)
+	reviewers := assignedReviewers(t, pr)
+	if assert.Len(t, reviewers, 1) {
+		assert.NotEqual(t, breached, reviewers[0])
+	}
+
+	// The replacement starts a fresh assignment, which has not breached yet.
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Empty(t, notifier.breaches())
+	pr, err = db.Store.PullRequests().Get("pr-1")
+	require.NoError(t, err)
+	assert.Equal(t, reviewers, assignedReviewers(t, pr))
+}
+
+func TestCheckBreachesReassignWithoutCandidate(t *testing.T) {
+	service, notifier := newSLATest(t, models.Team{TeamName: "backend", MinReviewers: 1, MaxReviewers: 1,
+		SLAPolicy: models.SLAPolicyReassign, Members: slaMembers("", "u1", "u2")})
+
+	// Nobody can take over, so the reviewer is escalated to instead.
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Equal(t, map[string][]string{"u2": {"u2"}}, notifier.breaches())
+
+	require.NoError(t, service.CheckBreaches(context.Background()))
+	assert.Empty(t, notifier.breaches())
+}
@@ -4,22 +4,40 @@ import (
 	"prReviewerAssignment/internal/db"
 	"prReviewerAssignment/internal/models"
 	"encoding/json"
-	"gorm.io/gorm"
+	"errors"
+	"prReviewerAssignment/internal/repository"
 )
 
 type StatsService struct {
-	db *gorm.DB
+	store repository.Store
 }
 
 func NewStatsService() *StatsService {
-	return &StatsService{db: db.DB}
+	return &StatsService{store: db.Store}
 }
 
-func (s *StatsService) GetReviewerStats() (*models.StatsResponse, error) {
-	var allPRs []models.PullRequest
-	result := s.db.Find(&allPRs)
-	if result.Error != nil {
-		return nil, result.Error
+// GetReviewerStats counts reviews over all pull requests or, with teamName
+// set, over the pull requests of that team and every team below it.
+func (s *StatsService) GetReviewerStats(teamName string) (*models.StatsResponse, error) {
+	var teams []string
+	if teamName != "" {
+		team, err := s.store.Teams().Get(teamName)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrTeamNotFound
+		} else if err != nil {
+			return nil, err
+		}
+
+		hierarchy, err := loadTeamHierarchy(s.store)
+		if err != nil {
+			return nil, err
+		}
+		teams = hierarchy.subtree(team.TeamName)
+	}
+
+	allPRs, err := s.store.PullRequests().List(repository.PullRequestQuery{TeamNames: teams})
+	if err != nil {
+		return nil, err
 	}
 
 	reviewerCount := make(map[string]int)
@@ -35,15 +53,39 @@ func (s *StatsService) GetReviewerStats() (*models.StatsResponse, error) {
 		}
 	}
 
-	var stats []models.ReviewerStats
+	declines, err := s.store.PullRequests().Declines(repository.DeclineQuery{TeamNames: teams})
+	if err != nil {
+		return nil, err
+	}
+
+	declineCount := make(map[string]int)
+	for _, decline := range declines {
+		declineCount[decline.ReviewerID]++
+		if _, ok := reviewerCount[decline.ReviewerID]; !ok {
+			reviewerCount[decline.ReviewerID] = 0
+		}
+	}
+
+	stats := []models.ReviewerStats{}
 	for userID, count := range reviewerCount {
+		declined := declineCount[userID]
+		// Declined reviews no longer show up in assigned_reviewers, so they
+		// are added back to get the total number of review requests.
+		rate := 0.0
+		if count+declined > 0 {
+			rate = float64(declined) / float64(count+declined)
+		}
 		stats = append(stats, models.ReviewerStats{
-			UserID: userID,
-			Count:  count,
+			UserID:       userID,
+			Count:        count,
+			DeclineCount: declined,
+			DeclineRate:  rate,
 		})
 	}
 
 	response := &models.StatsResponse{
+		TeamName:      teamName,
+		Teams:         teams,
 		ReviewerStats: stats,
 	}
 
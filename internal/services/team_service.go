@@ -1,114 +1,1045 @@
 package services
 
 import (
+	"context"
 	"errors"
-	"gorm.io/gorm"
+	"fmt"
+	"net/url"
 	"prReviewerAssignment/internal/db"
 	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/pagination"
+	"prReviewerAssignment/internal/repository"
+	"prReviewerAssignment/internal/workhours"
+	"sort"
+	"strings"
+	"time"
 )
 
+const defaultMaxReviewers = 2
+
 type TeamService struct {
-	db *gorm.DB
+	store     repository.Store
+	prService *PRService
 }
 
 func NewTeamService() *TeamService {
-	return &TeamService{db: db.DB}
+	return &TeamService{store: db.Store, prService: NewPRService()}
 }
 
 func (s *TeamService) CreateTeam(team models.Team) (*models.Team, error) {
-	tx := s.db.Begin()
-	if tx.Error != nil {
-		return nil, tx.Error
+	applyTeamDefaults(&team)
+	if err := validateTeam(team); err != nil {
+		return nil, err
 	}
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+
+	err := s.store.Transaction(func(tx repository.Store) error {
+		_, err := tx.Teams().Get(team.TeamName)
+		if err == nil {
+			return ErrTeamExists
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		if team.ParentTeamName != "" {
+			_, err := tx.Teams().Get(team.ParentTeamName)
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrParentTeamNotFound
+			} else if err != nil {
+				return err
+			}
+		}
+
+		teamDB := models.TeamDB{
+			TeamName:       team.TeamName,
+			ParentTeamName: team.ParentTeamName,
+			MinReviewers:   team.MinReviewers,
+			MaxReviewers:   team.MaxReviewers,
+			SLAHours:       team.SLAHours,
+			SLAPolicy:      team.SLAPolicy,
+			Strategy:       team.Strategy,
+			DigestTime:     team.DigestTime,
+			QuietStart:     team.QuietStart,
+			QuietEnd:       team.QuietEnd,
+			ChatWebhook:    team.ChatWebhook,
+		}
+		if err := tx.Teams().Create(&teamDB); err != nil {
+			return err
+		}
+
+		for _, member := range team.Members {
+			if err := s.upsertUser(tx, member, team.TeamName); err != nil {
+				return err
+			}
 		}
-	}()
 
-	var existingTeam models.TeamDB
-	result := tx.Where("team_name = ?", team.TeamName).First(&existingTeam)
-	if result.Error == nil {
-		tx.Rollback()
-		return nil, errors.New("team already exists")
-	} else if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		tx.Rollback()
-		return nil, result.Error
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	teamDB := models.TeamDB{
-		TeamName: team.TeamName,
+	return &team, nil
+}
+
+// applyTeamDefaults fills in the defaults of a new team and folds
+// lead_user_id into the member roles.
+func applyTeamDefaults(team *models.Team) {
+	if team.MaxReviewers == 0 {
+		team.MaxReviewers = defaultMaxReviewers
 	}
-	if err := tx.Create(&teamDB).Error; err != nil {
-		tx.Rollback()
-		return nil, err
+	if team.SLAPolicy == "" {
+		team.SLAPolicy = models.SLAPolicyEscalate
 	}
+	if team.Strategy == "" {
+		team.Strategy = models.StrategyRandom
+	}
+	for i, member := range team.Members {
+		if member.UserID == team.LeadUserID {
+			team.Members[i].Role = models.MembershipRoleLead
+		} else if member.Role == "" {
+			team.Members[i].Role = models.MembershipRoleMember
+		}
+	}
+}
 
+// validateTeam checks the settings and members of a team with defaults
+// applied and reports every problem found.
+func validateTeam(team models.Team) error {
+	var problems fieldErrors
+	if team.MinReviewers < 0 || team.MaxReviewers < 0 || team.MinReviewers > team.MaxReviewers {
+		problems.add("min_reviewers", "must be between 0 and max_reviewers")
+	}
+	problems = append(problems, validateSLA(team)...)
+	if team.Strategy != models.StrategyRandom && team.Strategy != models.StrategyWorkingHours {
+		problems.add("assignment_strategy", "must be RANDOM or WORKING_HOURS")
+	}
+	for _, clock := range []struct{ field, value string }{
+		{"digest_time", team.DigestTime},
+		{"quiet_hours_start", team.QuietStart},
+		{"quiet_hours_end", team.QuietEnd},
+	} {
+		if _, err := workhours.ParseClock(clock.value); clock.value != "" && err != nil {
+			problems.add(clock.field, "must be HH:MM")
+		}
+	}
+	if (team.QuietStart == "") != (team.QuietEnd == "") {
+		problems.add("quiet_hours_end", "must be set together with quiet_hours_start")
+	}
+	if team.ChatWebhook != "" {
+		if parsed, err := url.Parse(team.ChatWebhook); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			problems.add("chat_webhook_url", "must be an http(s) URL")
+		}
+	}
+	problems = append(problems, validateMembers("members", team.Members)...)
+	return problems.err()
+}
+
+// validateSLA expects lead_user_id to be folded into the member roles
+// already; a lead_user_id that matches no member is rejected.
+func validateSLA(team models.Team) fieldErrors {
+	var problems fieldErrors
+	if team.SLAHours < 0 {
+		problems.add("sla_hours", "must not be negative")
+	}
+
+	hasLead := false
 	for _, member := range team.Members {
-		if err := s.upsertUser(tx, member, team.TeamName); err != nil {
-			tx.Rollback()
+		if member.Role == models.MembershipRoleLead {
+			hasLead = true
+		}
+	}
+	if team.LeadUserID != "" && !hasLead {
+		problems.add("lead_user_id", "must be a team member")
+	}
+
+	switch team.SLAPolicy {
+	case models.SLAPolicyEscalate, models.SLAPolicyReassign:
+	case models.SLAPolicyNotifyLead:
+		if !hasLead {
+			problems.add("sla_policy", "NOTIFY_LEAD needs a team lead")
+		}
+	default:
+		problems.add("sla_policy", "must be ESCALATE, NOTIFY_LEAD or REASSIGN")
+	}
+	return problems
+}
+
+// validateMembers checks the members listed in the request field named
+// field.
+func validateMembers(field string, members []models.TeamMember) fieldErrors {
+	var problems fieldErrors
+	for i, member := range members {
+		prefix := fmt.Sprintf("%s[%d].", field, i)
+		if member.UserID == "" {
+			problems.add(prefix+"user_id", "is required")
+		}
+		switch member.Role {
+		case "", models.MembershipRoleLead, models.MembershipRoleMember, models.MembershipRoleObserver:
+		default:
+			problems.add(prefix+"role", "must be LEAD, MEMBER or OBSERVER")
+		}
+		if _, err := time.LoadLocation(member.Timezone); err != nil {
+			problems.add(prefix+"timezone", "must be an IANA name")
+		} else if _, err := workhours.Parse(member.Timezone, member.WorkStart, member.WorkEnd); err != nil {
+			problems.add(prefix+"work_end", "must be HH:MM after work_start")
+		}
+		if member.Capacity < 0 {
+			problems.add(prefix+"capacity", "must not be negative")
+		}
+		if member.OOOFrom != nil && (member.OOOUntil == nil || !member.OOOUntil.After(*member.OOOFrom)) {
+			problems.add(prefix+"ooo_until", "must be set after ooo_from")
+		}
+	}
+	return problems
+}
+
+// upsertUser creates or updates the member's profile and makes them a member
+// of teamName with the member's role. The first team a user joins becomes
+// their home team.
+func (s *TeamService) upsertUser(tx repository.Store, member models.TeamMember, teamName string) error {
+	existingUser, err := tx.Users().Get(member.UserID)
+
+	if errors.Is(err, repository.ErrNotFound) {
+		user := models.User{
+			UserID:     member.UserID,
+			Username:   member.Username,
+			TeamName:   teamName,
+			IsActive:   member.IsActive,
+			Email:      member.Email,
+			ChatHandle: member.ChatHandle,
+			Timezone:   member.Timezone,
+			WorkStart:  member.WorkStart,
+			WorkEnd:    member.WorkEnd,
+			Capacity:   member.Capacity,
+			OOOFrom:    member.OOOFrom,
+			OOOUntil:   member.OOOUntil,
+		}
+		if err := tx.Users().Create(&user); err != nil {
+			return err
+		}
+	} else if err == nil {
+		// Profile fields left empty keep their stored value.
+		mergeProfile(existingUser, member)
+		if existingUser.TeamName == "" {
+			existingUser.TeamName = teamName
+		}
+		if err := tx.Users().Update(existingUser); err != nil {
+			return err
+		}
+	} else {
+		return err
+	}
+
+	return tx.Teams().AddMembership(teamName, member.UserID, member.Role, member.IsActive)
+}
+
+// mergeProfile copies the non-empty profile fields of member onto user. An
+// inactive member does not deactivate the user.
+func mergeProfile(user *models.User, member models.TeamMember) {
+	if member.Username != "" {
+		user.Username = member.Username
+	}
+	if member.IsActive {
+		user.IsActive = true
+	}
+	if member.Email != "" {
+		user.Email = member.Email
+	}
+	if member.ChatHandle != "" {
+		user.ChatHandle = member.ChatHandle
+	}
+	if member.Timezone != "" {
+		user.Timezone = member.Timezone
+	}
+	if member.WorkStart != "" {
+		user.WorkStart = member.WorkStart
+	}
+	if member.WorkEnd != "" {
+		user.WorkEnd = member.WorkEnd
+	}
+	if member.Capacity != 0 {
+		user.Capacity = member.Capacity
+	}
+	if member.OOOFrom != nil {
+		user.OOOFrom = member.OOOFrom
+	}
+	if member.OOOUntil != nil {
+		user.OOOUntil = member.OOOUntil
+	}
+}
+
+// GetTeam returns the team and, with includeDescendants, every team below it
+// nested in sub_teams.
+func (s *TeamService) GetTeam(teamName string, includeDescendants bool) (*models.Team, error) {
+	team, err := s.getTeam(teamName)
+	if err != nil || !includeDescendants {
+		return team, err
+	}
+
+	hierarchy, err := loadTeamHierarchy(s.store)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachSubTeams(team, hierarchy, map[string]bool{team.TeamName: true}); err != nil {
+		return nil, err
+	}
+
+	return team, nil
+}
+
+func (s *TeamService) attachSubTeams(team *models.Team, hierarchy *teamHierarchy, visited map[string]bool) error {
+	for _, child := range hierarchy.children[team.TeamName] {
+		if visited[child] {
+			continue
+		}
+		visited[child] = true
+
+		subTeam, err := s.getTeam(child)
+		if err != nil {
+			return err
+		}
+		if err := s.attachSubTeams(subTeam, hierarchy, visited); err != nil {
+			return err
+		}
+		team.SubTeams = append(team.SubTeams, *subTeam)
+	}
+
+	return nil
+}
+
+func (s *TeamService) getTeam(teamName string) (*models.Team, error) {
+	teamDB, err := s.store.Teams().Get(teamName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrTeamNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	members, err := teamMembers(s.store, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	// lead_user_id is kept for clients that predate roles and names the
+	// first lead.
+	leadUserID := ""
+	for _, member := range members {
+		if member.Role == models.MembershipRoleLead {
+			leadUserID = member.UserID
+			break
+		}
+	}
+
+	team := &models.Team{
+		TeamName:       teamName,
+		ParentTeamName: teamDB.ParentTeamName,
+		MinReviewers:   teamDB.MinReviewers,
+		MaxReviewers:   teamDB.MaxReviewers,
+		SLAHours:       teamDB.SLAHours,
+		SLAPolicy:      teamDB.SLAPolicy,
+		LeadUserID:     leadUserID,
+		Strategy:       teamDB.Strategy,
+		DigestTime:     teamDB.DigestTime,
+		QuietStart:     teamDB.QuietStart,
+		QuietEnd:       teamDB.QuietEnd,
+		ChatWebhook:    teamDB.ChatWebhook,
+		Members:        members,
+	}
+
+	return team, nil
+}
+
+// ListTeams returns a page of teams sorted by team_name or created_at,
+// optionally filtered by a team name prefix and by parent team.
+func (s *TeamService) ListTeams(request models.ListTeamsRequest) (*models.ListTeamsResponse, error) {
+	page, err := parsePage(request.Limit, request.Sort, request.Cursor, "team_name", "created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := s.store.Teams().List(repository.TeamQuery{
+		Search:         request.Search,
+		ParentTeamName: request.ParentTeamName,
+		Page:           page,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.ListTeamsResponse{Teams: []models.TeamSummary{}}
+	if len(teams) > page.Limit {
+		teams = teams[:page.Limit]
+		last := teams[page.Limit-1]
+		response.NextCursor = nextCursor(page.Sort, last.TeamName, last.TeamName, last.CreatedAt)
+	}
+	if len(teams) == 0 {
+		return response, nil
+	}
+
+	names := []string{}
+	for _, team := range teams {
+		names = append(names, team.TeamName)
+	}
+
+	memberships, err := s.store.Teams().Memberships(repository.MembershipQuery{TeamNames: names})
+	if err != nil {
+		return nil, err
+	}
+	userIDs := []string{}
+	for _, membership := range memberships {
+		userIDs = append(userIDs, membership.UserID)
+	}
+	users, err := s.store.Users().Find(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]bool)
+	for _, user := range users {
+		active[user.UserID] = user.IsActive
+	}
+
+	summaries := make(map[string]models.TeamSummary)
+	for _, membership := range memberships {
+		userActive, ok := active[membership.UserID]
+		if !ok {
+			continue
+		}
+		summary := summaries[membership.TeamName]
+		summary.MemberCount++
+		if membership.IsActive && userActive {
+			summary.ActiveMemberCount++
+		}
+		summaries[membership.TeamName] = summary
+	}
+
+	for _, team := range teams {
+		summary := summaries[team.TeamName]
+		summary.TeamName = team.TeamName
+		summary.ParentTeamName = team.ParentTeamName
+		response.Teams = append(response.Teams, summary)
+	}
+
+	return response, nil
+}
+
+// parsePage validates the paging parameters of a list request; fields are
+// the columns it can be sorted by, the default first.
+func parsePage(limit int, sortBy string, cursor string, fields ...string) (repository.Page, error) {
+	page := repository.Page{}
+	var err error
+	if page.Limit, err = pagination.Limit(limit); err != nil {
+		return page, InvalidField(CodeInvalidQuery, "limit", fmt.Sprintf("must be between 1 and %d", pagination.MaxLimit))
+	}
+	if page.Sort, err = pagination.ParseSort(sortBy, fields...); err != nil {
+		return page, InvalidField(CodeInvalidQuery, "sort", "must be one of "+strings.Join(fields, ", ")+", optionally prefixed with -")
+	}
+	page.Cursor, err = pagination.Decode(cursor)
+	if err == nil {
+		page.CursorValue, err = cursorValue(page.Sort, page.Cursor)
+	}
+	if err != nil {
+		return page, InvalidField(CodeInvalidQuery, "cursor", "is invalid")
+	}
+	return page, nil
+}
+
+// cursorValue converts the cursor value to the type of the sort column.
+func cursorValue(sort pagination.Sort, cursor *pagination.Cursor) (interface{}, error) {
+	if cursor == nil {
+		return nil, nil
+	}
+	if sort.Field != "created_at" {
+		return cursor.Value, nil
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, cursor.Value)
+	if err != nil {
+		return nil, pagination.ErrInvalidCursor
+	}
+	return createdAt, nil
+}
+
+// nextCursor points past the last item of a page sorted by sort.
+func nextCursor(sort pagination.Sort, id string, value string, createdAt time.Time) string {
+	if sort.Field == "created_at" {
+		value = createdAt.UTC().Format(time.RFC3339Nano)
+	}
+	return pagination.Cursor{Value: value, ID: id}.Encode()
+}
+
+func (s *TeamService) ChatWebhook(ctx context.Context, teamName string) (string, error) {
+	teamDB, err := s.store.Teams().Get(teamName)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return teamDB.ChatWebhook, nil
+}
+
+// UpdateTeam adds or updates the given members and removes others. Open
+// reviews of removed members on the team's pull requests are handed off to
+// the remaining teammates.
+func (s *TeamService) UpdateTeam(request models.UpdateTeamRequest) (*models.Team, []models.ReviewHandoff, error) {
+	if err := validateMembers("add_members", request.AddMembers).err(); err != nil {
+		return nil, nil, err
+	}
+
+	var handoffs []models.ReviewHandoff
+	err := s.store.Transaction(func(tx repository.Store) error {
+		_, err := tx.Teams().Get(request.TeamName)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+
+		for _, member := range request.AddMembers {
+			if err := s.upsertUser(tx, member, request.TeamName); err != nil {
+				return err
+			}
+		}
+
+		handoffs, err = s.removeMembers(tx, request.TeamName, request.RemoveMembers)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.prService.publishHandoffs(handoffs)
+
+	team, err := s.GetTeam(request.TeamName, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return team, handoffs, nil
+}
+
+// ChangeMembers adds existing users to teamName and removes members from it
+// without touching their profiles. New members get the MEMBER role and
+// removed members hand off their open reviews of the team's pull requests.
+func (s *TeamService) ChangeMembers(teamName string, addUserIDs []string, removeUserIDs []string) ([]models.ReviewHandoff, error) {
+	var handoffs []models.ReviewHandoff
+	err := s.store.Transaction(func(tx repository.Store) error {
+		_, err := tx.Teams().Get(teamName)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+
+		for _, userID := range addUserIDs {
+			user, err := tx.Users().Get(userID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrUserNotFound
+			} else if err != nil {
+				return err
+			}
+
+			if err := tx.Teams().AddMembership(teamName, userID, "", true); err != nil {
+				return err
+			}
+			if user.TeamName == "" {
+				user.TeamName = teamName
+				if err := tx.Users().Update(user); err != nil {
+					return err
+				}
+			}
+		}
+
+		handoffs, err = s.removeMembers(tx, teamName, removeUserIDs)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.prService.publishHandoffs(handoffs)
+	return handoffs, nil
+}
+
+// removeMembers removes userIDs from teamName and hands off their open
+// reviews of the team's pull requests.
+func (s *TeamService) removeMembers(tx repository.Store, teamName string, userIDs []string) ([]models.ReviewHandoff, error) {
+	handoffs := []models.ReviewHandoff{}
+	for _, userID := range userIDs {
+		member, err := isTeamMember(tx, teamName, userID, false)
+		if err != nil {
 			return nil, err
 		}
+		if !member {
+			return nil, ErrNotTeamMember
+		}
+
+		if err := removeMembership(tx, teamName, userID); err != nil {
+			return nil, err
+		}
+
+		memberHandoffs, err := s.prService.handOffReviews(tx, userID, teamName)
+		if err != nil {
+			return nil, err
+		}
+		handoffs = append(handoffs, memberHandoffs...)
+	}
+
+	return handoffs, nil
+}
+
+// DeleteTeam removes a team. Depending on the policy it refuses while the
+// team still has open pull requests or members who would be left without a
+// team still take part in open pull requests (BLOCK), moves the memberships
+// and open pull requests to another team (REASSIGN), or deletes the team's
+// open pull requests (CASCADE). Members left without a team are deactivated
+// by BLOCK and CASCADE. Merged pull requests are always kept.
+func (s *TeamService) DeleteTeam(request models.DeleteTeamRequest) (*models.DeleteTeamResponse, error) {
+	if request.Policy == "" {
+		request.Policy = models.DeletePolicyBlock
+	}
+	switch request.Policy {
+	case models.DeletePolicyBlock, models.DeletePolicyCascade:
+	case models.DeletePolicyReassign:
+		if request.TransferTo == "" || request.TransferTo == request.TeamName {
+			return nil, ErrInvalidDeletePolicy
+		}
+	default:
+		return nil, ErrInvalidDeletePolicy
 	}
 
-	if err := tx.Commit().Error; err != nil {
+	var response *models.DeleteTeamResponse
+	handoffs := []models.ReviewHandoff{}
+	err := s.store.Transaction(func(tx repository.Store) error {
+		teamDB, err := tx.Teams().Get(request.TeamName)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+
+		memberships, err := tx.Teams().Memberships(repository.MembershipQuery{TeamNames: []string{teamDB.TeamName}})
+		if err != nil {
+			return err
+		}
+		memberIDs := []string{}
+		for _, membership := range memberships {
+			memberIDs = append(memberIDs, membership.UserID)
+		}
+
+		teamPRs, err := tx.PullRequests().List(repository.PullRequestQuery{TeamNames: []string{teamDB.TeamName}, Status: "OPEN"})
+		if err != nil {
+			return err
+		}
+
+		orphans, err := soleTeamMembers(tx, teamDB.TeamName, memberIDs)
+		if err != nil {
+			return err
+		}
+
+		response = &models.DeleteTeamResponse{
+			TeamName:            teamDB.TeamName,
+			Policy:              request.Policy,
+			Members:             memberIDs,
+			DeletedPullRequests: []string{},
+		}
+
+		switch request.Policy {
+		case models.DeletePolicyBlock:
+			involved, err := openPRsInvolving(tx, orphans)
+			if err != nil {
+				return err
+			}
+			if len(teamPRs) > 0 || len(involved) > 0 {
+				return ErrTeamHasOpenPRs
+			}
+		case models.DeletePolicyReassign:
+			target, err := tx.Teams().Get(request.TransferTo)
+			if errors.Is(err, repository.ErrNotFound) {
+				return ErrTransferTeamNotFound
+			} else if err != nil {
+				return err
+			}
+			for _, membership := range memberships {
+				if err := tx.Teams().AddMembership(target.TeamName, membership.UserID, "", membership.IsActive); err != nil {
+					return err
+				}
+			}
+			if err := moveHomeTeam(tx, teamDB.TeamName, target.TeamName); err != nil {
+				return err
+			}
+			if err := movePullRequests(tx, teamDB.TeamName, target.TeamName); err != nil {
+				return err
+			}
+			response.TransferredTo = target.TeamName
+		case models.DeletePolicyCascade:
+			for _, pr := range teamPRs {
+				if err := s.prService.deletePullRequest(tx, pr.PullRequestID); err != nil {
+					return err
+				}
+				response.DeletedPullRequests = append(response.DeletedPullRequests, pr.PullRequestID)
+			}
+			for _, userID := range orphans {
+				memberHandoffs, err := s.prService.handOffReviews(tx, userID, "")
+				if err != nil {
+					return err
+				}
+				handoffs = append(handoffs, memberHandoffs...)
+			}
+		}
+
+		if request.Policy != models.DeletePolicyReassign {
+			for _, userID := range memberIDs {
+				if err := removeMembership(tx, teamDB.TeamName, userID); err != nil {
+					return err
+				}
+			}
+			if err := deactivateUsers(tx, orphans); err != nil {
+				return err
+			}
+		}
+
+		// Sub-teams move up to the deleted team's parent.
+		if err := tx.Teams().MoveSubTeams(teamDB.TeamName, teamDB.ParentTeamName); err != nil {
+			return err
+		}
+
+		return tx.Teams().Delete(teamDB.TeamName)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return &team, nil
+	s.prService.publishHandoffs(handoffs)
+	response.Handoffs = handoffs
+
+	return response, nil
 }
 
-func (s *TeamService) upsertUser(tx *gorm.DB, member models.TeamMember, teamName string) error {
-	var existingUser models.User
-	result := tx.Where("user_id = ?", member.UserID).First(&existingUser)
+// moveHomeTeam moves the users whose home team is teamName to target.
+func moveHomeTeam(tx repository.Store, teamName string, target string) error {
+	users, err := tx.Users().List(repository.UserQuery{HomeTeam: teamName})
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		users[i].TeamName = target
+		if err := tx.Users().Update(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		user := models.User{
-			UserID:   member.UserID,
-			Username: member.Username,
-			TeamName: teamName,
-			IsActive: member.IsActive,
+// movePullRequests moves every pull request of teamName, merged ones
+// included, to target.
+func movePullRequests(tx repository.Store, teamName string, target string) error {
+	prs, err := tx.PullRequests().List(repository.PullRequestQuery{TeamNames: []string{teamName}})
+	if err != nil {
+		return err
+	}
+	for i := range prs {
+		prs[i].TeamName = target
+		if err := tx.PullRequests().Update(&prs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deactivateUsers marks the given users inactive.
+func deactivateUsers(tx repository.Store, userIDs []string) error {
+	users, err := tx.Users().Find(userIDs)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		users[i].IsActive = false
+		if err := tx.Users().Update(&users[i]); err != nil {
+			return err
 		}
-		return tx.Create(&user).Error
-	} else if result.Error == nil {
-		return tx.Model(&existingUser).Updates(models.User{
-			Username: member.Username,
-			TeamName: teamName,
-			IsActive: member.IsActive,
-		}).Error
+	}
+	return nil
+}
+
+// removeMembership takes userID off teamName. A user whose home team it was
+// falls back to their oldest remaining membership, or to no team at all.
+func removeMembership(tx repository.Store, teamName string, userID string) error {
+	if err := tx.Teams().RemoveMembership(teamName, userID); err != nil {
+		return err
+	}
+
+	user, err := tx.Users().Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if user.TeamName != teamName {
+		return nil
 	}
 
-	return result.Error
+	remaining, err := tx.Teams().Memberships(repository.MembershipQuery{UserIDs: []string{userID}})
+	if err != nil {
+		return err
+	}
+	user.TeamName = ""
+	if len(remaining) > 0 {
+		user.TeamName = remaining[0].TeamName
+	}
+
+	return tx.Users().Update(user)
 }
 
-func (s *TeamService) GetTeam(teamName string) (*models.Team, error) {
-	var teamDB models.TeamDB
-	result := s.db.Where("team_name = ?", teamName).First(&teamDB)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("team not found")
-	} else if result.Error != nil {
-		return nil, result.Error
+// isTeamMember reports whether userID belongs to teamName. With activeOnly
+// set both the membership and the user have to be active.
+func isTeamMember(tx repository.Store, teamName string, userID string, activeOnly bool) (bool, error) {
+	memberships, err := tx.Teams().Memberships(repository.MembershipQuery{TeamNames: []string{teamName}, UserIDs: []string{userID}})
+	if err != nil || len(memberships) == 0 {
+		return false, err
 	}
 
-	var users []models.User
-	result = s.db.Where("team_name = ?", teamName).Find(&users)
-	if result.Error != nil {
-		return nil, result.Error
+	user, err := tx.Users().Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
 	}
 
-	var members []models.TeamMember
+	return !activeOnly || (memberships[0].IsActive && user.IsActive), nil
+}
+
+// teamMembers lists the members of teamName together with all the teams each
+// of them belongs to. A member counts as active only if both the user and
+// the membership are.
+func teamMembers(tx repository.Store, teamName string) ([]models.TeamMember, error) {
+	memberships, err := tx.Teams().Memberships(repository.MembershipQuery{TeamNames: []string{teamName}})
+	if err != nil {
+		return nil, err
+	}
+	if len(memberships) == 0 {
+		return nil, nil
+	}
+	sort.Slice(memberships, func(i, j int) bool {
+		return memberships[i].UserID < memberships[j].UserID
+	})
+
+	var userIDs []string
+	for _, membership := range memberships {
+		userIDs = append(userIDs, membership.UserID)
+	}
+
+	users, err := tx.Users().Find(userIDs)
+	if err != nil {
+		return nil, err
+	}
+	usersByID := make(map[string]models.User)
 	for _, user := range users {
+		usersByID[user.UserID] = user
+	}
+
+	allMemberships, err := tx.Teams().Memberships(repository.MembershipQuery{UserIDs: userIDs})
+	if err != nil {
+		return nil, err
+	}
+	teams := make(map[string][]string)
+	for _, membership := range allMemberships {
+		teams[membership.UserID] = append(teams[membership.UserID], membership.TeamName)
+	}
+
+	var members []models.TeamMember
+	for _, membership := range memberships {
+		user, ok := usersByID[membership.UserID]
+		if !ok {
+			continue
+		}
 		members = append(members, models.TeamMember{
-			UserID:   user.UserID,
-			Username: user.Username,
-			IsActive: user.IsActive,
+			UserID:     user.UserID,
+			Username:   user.Username,
+			IsActive:   user.IsActive && membership.IsActive,
+			Role:       membership.Role,
+			Email:      user.Email,
+			ChatHandle: user.ChatHandle,
+			Timezone:   user.Timezone,
+			WorkStart:  user.WorkStart,
+			WorkEnd:    user.WorkEnd,
+			Capacity:   user.Capacity,
+			OOOFrom:    user.OOOFrom,
+			OOOUntil:   user.OOOUntil,
+			Teams:      teams[user.UserID],
 		})
 	}
 
-	team := &models.Team{
-		TeamName: teamName,
-		Members:  members,
+	return members, nil
+}
+
+// soleTeamMembers returns those of userIDs who belong to no team other than
+// teamName.
+func soleTeamMembers(tx repository.Store, teamName string, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
 	}
 
-	return team, nil
+	memberships, err := tx.Teams().Memberships(repository.MembershipQuery{UserIDs: userIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	other := make(map[string]bool)
+	for _, membership := range memberships {
+		if membership.TeamName != teamName {
+			other[membership.UserID] = true
+		}
+	}
+
+	var sole []string
+	for _, userID := range userIDs {
+		if !other[userID] {
+			sole = append(sole, userID)
+		}
+	}
+
+	return sole, nil
+}
+
+// teamHierarchy maps every team to its parent and to its direct sub-teams.
+type teamHierarchy struct {
+	parents  map[string]string
+	children map[string][]string
+}
+
+func loadTeamHierarchy(tx repository.Store) (*teamHierarchy, error) {
+	teams, err := tx.Teams().List(repository.TeamQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	return newTeamHierarchy(teams), nil
+}
+
+func newTeamHierarchy(teams []models.TeamDB) *teamHierarchy {
+	hierarchy := &teamHierarchy{
+		parents:  make(map[string]string),
+		children: make(map[string][]string),
+	}
+	for _, team := range teams {
+		hierarchy.parents[team.TeamName] = team.ParentTeamName
+		if team.ParentTeamName != "" {
+			hierarchy.children[team.ParentTeamName] = append(hierarchy.children[team.ParentTeamName], team.TeamName)
+		}
+	}
+
+	return hierarchy
+}
+
+// subtree returns root followed by all teams below it.
+func (h *teamHierarchy) subtree(root string) []string {
+	teams := []string{root}
+	visited := map[string]bool{root: true}
+	for i := 0; i < len(teams); i++ {
+		for _, child := range h.children[teams[i]] {
+			if !visited[child] {
+				visited[child] = true
+				teams = append(teams, child)
+			}
+		}
+	}
+
+	return teams
+}
+
+// siblingLevels walks up from teamName and returns, for the parent and each
+// further ancestor, the other teams directly below it.
+func (h *teamHierarchy) siblingLevels(teamName string) [][]string {
+	var levels [][]string
+	visited := map[string]bool{teamName: true}
+	current := teamName
+	for {
+		parent := h.parents[current]
+		if parent == "" || visited[parent] {
+			return levels
+		}
+		visited[parent] = true
+
+		var siblings []string
+		for _, child := range h.children[parent] {
+			if child != current {
+				siblings = append(siblings, child)
+			}
+		}
+		if len(siblings) > 0 {
+			levels = append(levels, siblings)
+		}
+		current = parent
+	}
+}
+
+// teamLeads returns the active leads of teamName.
+func teamLeads(tx repository.Store, teamName string) ([]string, error) {
+	memberships, err := tx.Teams().Memberships(repository.MembershipQuery{TeamNames: []string{teamName}})
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, membership := range memberships {
+		if membership.Role == models.MembershipRoleLead && membership.IsActive {
+			candidates = append(candidates, membership.UserID)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	users, err := tx.Users().Find(candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var leads []string
+	for _, user := range users {
+		if user.IsActive {
+			leads = append(leads, user.UserID)
+		}
+	}
+
+	return leads, nil
+}
+
+// openPRsInvolving returns OPEN pull requests authored or reviewed by any of
+// the given users.
+func openPRsInvolving(tx repository.Store, userIDs []string) ([]models.PullRequest, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	prs, err := tx.PullRequests().List(repository.PullRequestQuery{Status: "OPEN"})
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]bool)
+	for _, userID := range userIDs {
+		users[userID] = true
+	}
+
+	reviews := reviewsByUser(prs)
+	seen := make(map[string]bool)
+	var involved []models.PullRequest
+	for _, pr := range prs {
+		if users[pr.AuthorID] && !seen[pr.PullRequestID] {
+			seen[pr.PullRequestID] = true
+			involved = append(involved, pr)
+		}
+	}
+	for _, userID := range userIDs {
+		for _, pr := range reviews[userID] {
+			if !seen[pr.PullRequestID] {
+				seen[pr.PullRequestID] = true
+				involved = append(involved, pr)
+			}
+		}
+	}
+
+	return involved, nil
 }
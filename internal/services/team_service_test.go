@@ -0,0 +1,137 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"prReviewerAssignment/internal/db"
+	"prReviewerAssignment/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSLARequiresLeadForNotifyLead(t *testing.T) {
+	members := []models.TeamMember{
+		{UserID: "u1", Role: models.MembershipRoleMember},
+		{UserID: "u2", Role: models.MembershipRoleObserver},
+	}
+	team := models.Team{SLAHours: 8, SLAPolicy: models.SLAPolicyNotifyLead, Members: members}
+	assert.Equal(t, fieldErrors{{Field: "sla_policy", Message: "NOTIFY_LEAD needs a team lead"}}, validateSLA(team))
+
+	team.Members = append(team.Members, models.TeamMember{UserID: "u3", Role: models.MembershipRoleLead})
+	assert.Empty(t, validateSLA(team))
+
+	// lead_user_id has to be folded into the roles of an actual member.
+	team = models.Team{SLAPolicy: models.SLAPolicyEscalate, LeadUserID: "u9", Members: members}
+	assert.Equal(t, fieldErrors{{Field: "lead_user_id", Message: "must be a team member"}}, validateSLA(team))
+}
+
+func TestValidateMembersRoles(t *testing.T) {
+	for _, role := range []string{"", models.MembershipRoleLead, models.MembershipRoleMember, models.MembershipRoleObserver} {
+		assert.Empty(t, validateMembers("members", []models.TeamMember{{UserID: "u1", Role: role}}))
+	}
+
+	problems := validateMembers("members", []models.TeamMember{{UserID: "u1"}, {UserID: "u2", Role: "OWNER"}})
+	assert.Equal(t, fieldErrors{{Field: "members[1].role", Message: "must be LEAD, MEMBER or OBSERVER"}}, problems)
+}
+
+func TestValidateMembersCapacityAndOOO(t *testing.T) {
+	from := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	until := from.AddDate(0, 0, 14)
+
+	assert.Empty(t, validateMembers("members", []models.TeamMember{{UserID: "u1", Capacity: 3, OOOFrom: &from, OOOUntil: &until}}))
+	assert.Empty(t, validateMembers("members", []models.TeamMember{{UserID: "u1", OOOUntil: &until}}))
+
+	for field, member := range map[string]models.TeamMember{
+		"add_members[0].capacity":  {UserID: "u1", Capacity: -1},
+		"add_members[0].ooo_until": {UserID: "u1", OOOFrom: &until, OOOUntil: &from},
+	} {
+		problems := validateMembers("add_members", []models.TeamMember{member})
+		if assert.Len(t, problems, 1) {
+			assert.Equal(t, field, problems[0].Field)
+		}
+	}
+
+	problems := validateMembers("members", []models.TeamMember{{UserID: "u1", OOOFrom: &from}})
+	assert.Equal(t, fieldErrors{{Field: "members[0].ooo_until", Message: "must be set after ooo_from"}}, problems)
+}
+
+func TestValidateTeamReportsEveryProblem(t *testing.T) {
+	team := models.Team{
+		TeamName:     "payments",
+		MinReviewers: 3,
+		MaxReviewers: 2,
+		SLAPolicy:    models.SLAPolicyEscalate,
+		Strategy:     models.StrategyRandom,
+		DigestTime:   "9am",
+		Members:      []models.TeamMember{{UserID: "u1", Timezone: "Mars/Olympus"}},
+	}
+
+	err := validateTeam(team)
+	var serviceError *Error
+	if assert.ErrorAs(t, err, &serviceError) {
+		assert.Equal(t, CodeValidation, serviceError.Code)
+		assert.Equal(t, []models.FieldError{
+			{Field: "min_reviewers", Message: "must be between 0 and max_reviewers"},
+			{Field: "digest_time", Message: "must be HH:MM"},
+			{Field: "members[0].timezone", Message: "must be an IANA name"},
+		}, serviceError.Details)
+		assert.Equal(t, "min_reviewers must be between 0 and max_reviewers; digest_time must be HH:MM; members[0].timezone must be an IANA name", err.Error())
+	}
+}
+
+func TestTeamHierarchy(t *testing.T) {
+	hierarchy := newTeamHierarchy([]models.TeamDB{
+		{TeamName: "org"},
+		{TeamName: "payments", ParentTeamName: "org"},
+		{TeamName: "platform", ParentTeamName: "org"},
+		{TeamName: "billing", ParentTeamName: "payments"},
+		{TeamName: "checkout", ParentTeamName: "payments"},
+		{TeamName: "fraud", ParentTeamName: "payments"},
+		{TeamName: "infra", ParentTeamName: "platform"},
+	})
+
+	assert.Equal(t, []string{"payments", "billing", "checkout", "fraud"}, hierarchy.subtree("payments"))
+	assert.Equal(t, []string{"infra"}, hierarchy.subtree("infra"))
+
+	assert.Equal(t, [][]string{{"billing", "fraud"}, {"platform"}}, hierarchy.siblingLevels("checkout"))
+	assert.Equal(t, [][]string{{"payments"}}, hierarchy.siblingLevels("platform"))
+	assert.Empty(t, hierarchy.siblingLevels("org"))
+}
+
+func TestDeleteTeamReassign(t *testing.T) {
+	useMemoryStore(t)
+	createTestTeam(t, "payments", "u1", "u2", "u3")
+	createTestTeam(t, "billing", "u4")
+	_, err := NewPRService().CreatePullRequest(models.CreatePRRequest{PullRequestID: "pr-1", PullRequestName: "Refunds", AuthorID: "u1"})
+	require.NoError(t, err)
+
+	service := NewTeamService()
+	_, err = service.DeleteTeam(models.DeleteTeamRequest{TeamName: "payments"})
+	assert.ErrorIs(t, err, ErrTeamHasOpenPRs)
+
+	response, err := service.DeleteTeam(models.DeleteTeamRequest{TeamName: "payments", Policy: models.DeletePolicyReassign, TransferTo: "billing"})
+	require.NoError(t, err)
+	assert.Equal(t, "billing", response.TransferredTo)
+	assert.Equal(t, []string{"u1", "u2", "u3"}, response.Members)
+
+	_, err = service.GetTeam("payments", false)
+	assert.ErrorIs(t, err, ErrTeamNotFound)
+
+	team, err := service.GetTeam("billing", false)
+	require.NoError(t, err)
+	var members []string
+	for _, member := range team.Members {
+		members = append(members, member.UserID)
+		assert.Equal(t, []string{"billing"}, member.Teams)
+	}
+	assert.Equal(t, []string{"u1", "u2", "u3", "u4"}, members)
+
+	reviews, err := NewUserService().GetUserReviews("u1")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", reviews.Teams[0].TeamName)
+	pr, err := db.Store.PullRequests().Get("pr-1")
+	require.NoError(t, err)
+	assert.Equal(t, "billing", pr.TeamName)
+}
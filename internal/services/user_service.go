@@ -1,78 +1,241 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"gorm.io/gorm"
 	"prReviewerAssignment/internal/db"
 	"prReviewerAssignment/internal/models"
+	"prReviewerAssignment/internal/notify"
+	"prReviewerAssignment/internal/repository"
 )
 
 type UserService struct {
-	db *gorm.DB
+	store     repository.Store
+	prService *PRService
 }
 
 func NewUserService() *UserService {
-	return &UserService{db: db.DB}
+	return &UserService{store: db.Store, prService: NewPRService()}
+}
+
+func (s *UserService) GetUser(userID string) (*models.User, error) {
+	user, err := s.store.Users().Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	}
+	return user, err
 }
 
 func (s *UserService) SetUserActive(userID string, isActive bool) (*models.User, error) {
-	var user models.User
-	result := s.db.Where("user_id = ?", userID).First(&user)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("user not found")
-	} else if result.Error != nil {
-		return nil, result.Error
+	user, err := s.store.Users().Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
 	}
 
 	user.IsActive = isActive
-	result = s.db.Save(&user)
-	if result.Error != nil {
-		return nil, result.Error
+	if err := s.store.Users().Update(user); err != nil {
+		return nil, err
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 func (s *UserService) GetUserReviews(userID string) (*models.UserReviewResponse, error) {
-	var user models.User
-	result := s.db.Where("user_id = ?", userID).First(&user)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("user not found")
-	} else if result.Error != nil {
-		return nil, result.Error
+	_, err := s.store.Users().Get(userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	teams, err := s.store.Teams().Memberships(repository.MembershipQuery{UserIDs: []string{userID}})
+	if err != nil {
+		return nil, err
+	}
+
+	allPRs, err := s.store.PullRequests().List(repository.PullRequestQuery{})
+	if err != nil {
+		return nil, err
+	}
+
+	userPRs := []models.PullRequestShort{}
+	for _, pr := range reviewsByUser(allPRs)[userID] {
+		userPRs = append(userPRs, models.PullRequestShort{
+			PullRequestID:   pr.PullRequestID,
+			PullRequestName: pr.PullRequestName,
+			AuthorID:        pr.AuthorID,
+			TeamName:        pr.TeamName,
+			Status:          pr.Status,
+		})
+	}
+
+	response := &models.UserReviewResponse{
+		UserID:       userID,
+		Teams:        teams,
+		PullRequests: userPRs,
+	}
+
+	return response, nil
+}
+
+// ListUsers returns a page of users sorted by user_id, username or
+// created_at. search matches a prefix of the id or the name, team_name keeps
+// members of that team and is_active filters on the user's own flag.
+func (s *UserService) ListUsers(request models.ListUsersRequest) (*models.ListUsersResponse, error) {
+	page, err := parsePage(request.Limit, request.Sort, request.Cursor, "user_id", "username", "created_at")
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.store.Users().List(repository.UserQuery{
+		Search:   request.Search,
+		TeamName: request.TeamName,
+		IsActive: request.IsActive,
+		Page:     page,
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var allPRs []models.PullRequest
-	result = s.db.Find(&allPRs)
-	if result.Error != nil {
-		return nil, result.Error
+	response := &models.ListUsersResponse{Users: users}
+	if len(users) > page.Limit {
+		response.Users = users[:page.Limit]
+		last := users[page.Limit-1]
+		response.NextCursor = nextCursor(page.Sort, last.UserID, last.Username, last.CreatedAt)
+	}
+	if response.Users == nil {
+		response.Users = []models.User{}
 	}
 
-	var userPRs []models.PullRequestShort
-	for _, pr := range allPRs {
+	return response, nil
+}
+
+// MoveUser transfers a user from their home team to another team, which
+// becomes their new home team. Memberships in further teams are kept. Open
+// pull requests they authored for the old team follow them to the new team.
+// Their open reviews on the old team's pull requests are either handed off to
+// old teammates or kept, and the response lists which.
+func (s *UserService) MoveUser(request models.MoveUserRequest) (*models.MoveUserResponse, error) {
+	var user *models.User
+	var response *models.MoveUserResponse
+	err := s.store.Transaction(func(tx repository.Store) error {
+		var err error
+		user, err = tx.Users().Get(request.UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+
+		team, err := tx.Teams().Get(request.TeamName)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrTeamNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if user.TeamName == team.TeamName {
+			return ErrAlreadyMember
+		}
+
+		response = &models.MoveUserResponse{
+			PreviousTeam:         user.TeamName,
+			NewTeam:              team.TeamName,
+			Handoffs:             []models.ReviewHandoff{},
+			RetainedReviews:      []string{},
+			AuthoredPullRequests: []string{},
+		}
+
+		openPRs, err := tx.PullRequests().List(repository.PullRequestQuery{Status: "OPEN"})
+		if err != nil {
+			return err
+		}
+		for i, pr := range openPRs {
+			if pr.AuthorID != user.UserID || pr.TeamName != response.PreviousTeam {
+				continue
+			}
+			response.AuthoredPullRequests = append(response.AuthoredPullRequests, pr.PullRequestID)
+			pr.TeamName = team.TeamName
+			if err := tx.PullRequests().Update(&pr); err != nil {
+				return err
+			}
+			openPRs[i] = pr
+		}
+
+		if err := tx.Teams().AddMembership(team.TeamName, user.UserID, "", true); err != nil {
+			return err
+		}
+		user.TeamName = team.TeamName
+		if err := tx.Users().Update(user); err != nil {
+			return err
+		}
+		if response.PreviousTeam != "" {
+			if err := removeMembership(tx, response.PreviousTeam, user.UserID); err != nil {
+				return err
+			}
+		}
+
+		if request.HandOffReviews && response.PreviousTeam != "" {
+			handoffs, err := s.prService.handOffReviews(tx, user.UserID, response.PreviousTeam)
+			if err != nil {
+				return err
+			}
+			response.Handoffs = append(response.Handoffs, handoffs...)
+		} else if response.PreviousTeam != "" {
+			for _, pr := range reviewsByUser(openPRs)[user.UserID] {
+				if pr.TeamName == response.PreviousTeam {
+					response.RetainedReviews = append(response.RetainedReviews, pr.PullRequestID)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.prService.publishHandoffs(response.Handoffs)
+	response.User = user
+
+	return response, nil
+}
+
+// reviewsByUser groups pull requests by the reviewers assigned to them.
+func reviewsByUser(prs []models.PullRequest) map[string][]models.PullRequest {
+	reviews := make(map[string][]models.PullRequest)
+	for _, pr := range prs {
 		var reviewers []string
 		if err := json.Unmarshal(pr.AssignedReviewers, &reviewers); err != nil {
 			continue
 		}
 
 		for _, reviewer := range reviewers {
-			if reviewer == userID {
-				userPRs = append(userPRs, models.PullRequestShort{
-					PullRequestID:   pr.PullRequestID,
-					PullRequestName: pr.PullRequestName,
-					AuthorID:        pr.AuthorID,
-					Status:          pr.Status,
-				})
-				break
-			}
+			reviews[reviewer] = append(reviews[reviewer], pr)
 		}
 	}
 
-	response := &models.UserReviewResponse{
-		UserID:       userID,
-		PullRequests: userPRs,
+	return reviews
+}
+
+func (s *UserService) Contacts(ctx context.Context, userIDs []string) (map[string]notify.Contact, error) {
+	users, err := s.store.Users().Find(userIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	return response, nil
+	contacts := make(map[string]notify.Contact)
+	for _, user := range users {
+		contacts[user.UserID] = notify.Contact{
+			UserID:     user.UserID,
+			Name:       user.Username,
+			Email:      user.Email,
+			ChatHandle: user.ChatHandle,
+		}
+	}
+
+	return contacts, nil
 }
@@ -0,0 +1,130 @@
+package workhours
+
+import (
+	"fmt"
+	"time"
+)
+
+// Window describes the working hours of a day in a given location.
+// Start and End are offsets from local midnight.
+type Window struct {
+	Location *time.Location
+	Start    time.Duration
+	End      time.Duration
+}
+
+// Default is Monday to Friday, 09:00 to 18:00 UTC.
+var Default = Window{
+	Location: time.UTC,
+	Start:    9 * time.Hour,
+	End:      18 * time.Hour,
+}
+
+func isWorkday(day time.Weekday) bool {
+	return day != time.Saturday && day != time.Sunday
+}
+
+// BusinessHoursBetween returns how much of [from, to) falls inside the
+// window on working days.
+func (w Window) BusinessHoursBetween(from, to time.Time) time.Duration {
+	if !to.After(from) || w.End <= w.Start {
+		return 0
+	}
+
+	from = from.In(w.Location)
+	to = to.In(w.Location)
+
+	var total time.Duration
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, w.Location)
+	for day.Before(to) {
+		if isWorkday(day.Weekday()) {
+			open := day.Add(w.Start)
+			closed := day.Add(w.End)
+			if open.Before(from) {
+				open = from
+			}
+			if closed.After(to) {
+				closed = to
+			}
+			if closed.After(open) {
+				total += closed.Sub(open)
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return total
+}
+
+// Parse builds a window from an IANA timezone name and "HH:MM" start and
+// end times. Empty values fall back to Default.
+func Parse(timezone, start, end string) (Window, error) {
+	window := Default
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return Window{}, err
+		}
+		window.Location = location
+	}
+	if start != "" {
+		offset, err := ParseClock(start)
+		if err != nil {
+			return Window{}, err
+		}
+		window.Start = offset
+	}
+	if end != "" {
+		offset, err := ParseClock(end)
+		if err != nil {
+			return Window{}, err
+		}
+		window.End = offset
+	}
+	if window.End <= window.Start {
+		return Window{}, fmt.Errorf("working hours end %s is not after start %s", end, start)
+	}
+
+	return window, nil
+}
+
+// ParseClock converts an "HH:MM" time of day into an offset from midnight.
+func ParseClock(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside working hours.
+func (w Window) Contains(t time.Time) bool {
+	t = t.In(w.Location)
+	if !isWorkday(t.Weekday()) {
+		return false
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, w.Location)
+	return !t.Before(day.Add(w.Start)) && t.Before(day.Add(w.End))
+}
+
+// NextStart returns t itself when it is inside working hours, otherwise the
+// moment the next working window opens.
+func (w Window) NextStart(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+
+	local := t.In(w.Location)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, w.Location)
+	for i := 0; i < 8; i++ {
+		open := day.Add(w.Start)
+		if isWorkday(day.Weekday()) && open.After(local) {
+			return open
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return local
+}
@@ -0,0 +1,48 @@
+package workhours
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBusinessHoursBetween(t *testing.T) {
+	// Friday 2025-11-21 is followed by a weekend.
+	friday := func(hour int) time.Time {
+		return time.Date(2025, 11, 21, hour, 0, 0, 0, time.UTC)
+	}
+
+	assert.Equal(t, 3*time.Hour, Default.BusinessHoursBetween(friday(10), friday(13)))
+	assert.Equal(t, 9*time.Hour, Default.BusinessHoursBetween(friday(0), friday(23)))
+	assert.Equal(t, time.Duration(0), Default.BusinessHoursBetween(friday(19), friday(23)))
+	assert.Equal(t, time.Duration(0), Default.BusinessHoursBetween(friday(13), friday(10)))
+
+	monday := time.Date(2025, 11, 24, 11, 0, 0, 0, time.UTC)
+	assert.Equal(t, 10*time.Hour, Default.BusinessHoursBetween(friday(10), monday))
+}
+
+func TestParseAndNextStart(t *testing.T) {
+	berlin, err := Parse("Europe/Berlin", "10:00", "19:00")
+	assert.NoError(t, err)
+
+	// Friday 2025-11-21 08:30 UTC is 09:30 in Berlin.
+	early := time.Date(2025, 11, 21, 8, 30, 0, 0, time.UTC)
+	assert.False(t, berlin.Contains(early))
+	assert.True(t, berlin.NextStart(early).Equal(time.Date(2025, 11, 21, 9, 0, 0, 0, time.UTC)))
+
+	inside := time.Date(2025, 11, 21, 12, 0, 0, 0, time.UTC)
+	assert.True(t, berlin.Contains(inside))
+	assert.True(t, berlin.NextStart(inside).Equal(inside))
+
+	// After hours on Friday the next window is Monday morning.
+	late := time.Date(2025, 11, 21, 20, 0, 0, 0, time.UTC)
+	assert.True(t, berlin.NextStart(late).Equal(time.Date(2025, 11, 24, 9, 0, 0, 0, time.UTC)))
+
+	_, err = Parse("Mars/Olympus", "", "")
+	assert.Error(t, err)
+	_, err = Parse("", "18:00", "09:00")
+	assert.Error(t, err)
+	_, err = Parse("", "9am", "")
+	assert.Error(t, err)
+}
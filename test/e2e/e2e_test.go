@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sync"
 	"testing"
 	"time"
 
@@ -251,3 +253,1119 @@ func TestErrorScenarios(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, resp.StatusCode == 404 || resp.StatusCode == 409)
 }
+
+func TestManualReviewerWorkflow(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	team := models.Team{
+		TeamName:     "manual-team-" + suffix,
+		MinReviewers: 1,
+		MaxReviewers: 2,
+		Members: []models.TeamMember{
+			{UserID: "manual-dev1-" + suffix, Username: "Manual Developer 1", IsActive: true},
+			{UserID: "manual-dev2-" + suffix, Username: "Manual Developer 2", IsActive: true},
+		},
+	}
+
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	prID := "pr-manual-" + suffix
+	prRequest := map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Manual Reviewers",
+		"author_id":         "manual-dev1-" + suffix,
+	}
+	prJSON, _ := json.Marshal(prRequest)
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	reviewerRequest := map[string]string{
+		"pull_request_id": prID,
+		"reviewer_id":     "manual-dev2-" + suffix,
+	}
+	reviewerJSON, _ := json.Marshal(reviewerRequest)
+
+	resp, err = client.Post(baseURL+"/pullRequest/addReviewer", "application/json", bytes.NewBuffer(reviewerJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp, err = client.Post(baseURL+"/pullRequest/removeReviewer", "application/json", bytes.NewBuffer(reviewerJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	authorRequest := map[string]string{
+		"pull_request_id": prID,
+		"reviewer_id":     "manual-dev1-" + suffix,
+	}
+	authorJSON, _ := json.Marshal(authorRequest)
+	resp, err = client.Post(baseURL+"/pullRequest/addReviewer", "application/json", bytes.NewBuffer(authorJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	mergeJSON, _ := json.Marshal(map[string]string{"pull_request_id": prID})
+	resp, err = client.Post(baseURL+"/pullRequest/merge", "application/json", bytes.NewBuffer(mergeJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp, err = client.Post(baseURL+"/pullRequest/removeReviewer", "application/json", bytes.NewBuffer(reviewerJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+}
+
+func TestDeclineReviewWorkflow(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	team := models.Team{
+		TeamName:     "decline-team-" + suffix,
+		MaxReviewers: 1,
+		Members: []models.TeamMember{
+			{UserID: "decline-dev1-" + suffix, Username: "Decline Developer 1", IsActive: true},
+			{UserID: "decline-dev2-" + suffix, Username: "Decline Developer 2", IsActive: true},
+			{UserID: "decline-dev3-" + suffix, Username: "Decline Developer 3", IsActive: true},
+		},
+	}
+
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	prID := "pr-decline-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Decline Review",
+		"author_id":         "decline-dev1-" + suffix,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var prCreateResponse struct {
+		PR struct {
+			AssignedReviewers []string `json:"assigned_reviewers"`
+		} `json:"pr"`
+	}
+	json.NewDecoder(resp.Body).Decode(&prCreateResponse)
+	assert.Len(t, prCreateResponse.PR.AssignedReviewers, 1)
+	if len(prCreateResponse.PR.AssignedReviewers) != 1 {
+		return
+	}
+	decliner := prCreateResponse.PR.AssignedReviewers[0]
+
+	invalidJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id": prID,
+		"reviewer_id":     decliner,
+		"reason":          "BORED",
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/decline", "application/json", bytes.NewBuffer(invalidJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	declineJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id": prID,
+		"reviewer_id":     decliner,
+		"reason":          "BUSY",
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/decline", "application/json", bytes.NewBuffer(declineJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var declineResponse struct {
+		ReplacedBy string `json:"replaced_by"`
+	}
+	json.NewDecoder(resp.Body).Decode(&declineResponse)
+	assert.NotEmpty(t, declineResponse.ReplacedBy)
+	assert.NotEqual(t, decliner, declineResponse.ReplacedBy)
+
+	reassignJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id": prID,
+		"old_reviewer_id": declineResponse.ReplacedBy,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/reassign", "application/json", bytes.NewBuffer(reassignJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/stats/reviewers")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var statsResponse models.StatsResponse
+	json.NewDecoder(resp.Body).Decode(&statsResponse)
+	for _, stats := range statsResponse.ReviewerStats {
+		if stats.UserID == decliner {
+			assert.Equal(t, 1, stats.DeclineCount)
+			assert.Equal(t, 1.0, stats.DeclineRate)
+		}
+	}
+}
+
+func TestTeamUpdateAndDeleteWorkflow(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	teamName := "update-team-" + suffix
+	author := "update-dev1-" + suffix
+	team := models.Team{
+		TeamName:     teamName,
+		MaxReviewers: 1,
+		Members: []models.TeamMember{
+			{UserID: author, Username: "Update Developer 1", IsActive: true},
+			{UserID: "update-dev2-" + suffix, Username: "Update Developer 2", IsActive: true},
+		},
+	}
+
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	prID := "pr-update-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Team Update",
+		"author_id":         author,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	updateJSON, _ := json.Marshal(map[string]interface{}{
+		"team_name": teamName,
+		"add_members": []models.TeamMember{
+			{UserID: "update-dev3-" + suffix, Username: "Update Developer 3", IsActive: true},
+			{UserID: author, Username: "Renamed Developer 1", IsActive: true},
+		},
+		"remove_members": []string{"update-dev2-" + suffix},
+	})
+	resp, err = client.Post(baseURL+"/team/update", "application/json", bytes.NewBuffer(updateJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var updateResponse models.UpdateTeamResponse
+	json.NewDecoder(resp.Body).Decode(&updateResponse)
+	if assert.NotNil(t, updateResponse.Team) {
+		assert.Len(t, updateResponse.Team.Members, 2)
+	}
+	if assert.Len(t, updateResponse.Handoffs, 1) {
+		assert.Equal(t, "update-dev2-"+suffix, updateResponse.Handoffs[0].OldReviewerID)
+		assert.Equal(t, "update-dev3-"+suffix, updateResponse.Handoffs[0].NewReviewerID)
+	}
+
+	deleteJSON, _ := json.Marshal(map[string]string{"team_name": teamName, "policy": "BLOCK"})
+	resp, err = client.Post(baseURL+"/team/delete", "application/json", bytes.NewBuffer(deleteJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	deleteJSON, _ = json.Marshal(map[string]string{"team_name": teamName, "policy": "CASCADE"})
+	resp, err = client.Post(baseURL+"/team/delete", "application/json", bytes.NewBuffer(deleteJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var deleteResponse models.DeleteTeamResponse
+	json.NewDecoder(resp.Body).Decode(&deleteResponse)
+	assert.Equal(t, []string{prID}, deleteResponse.DeletedPullRequests)
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=" + teamName)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestMoveUserWorkflow(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	author := "move-dev1-" + suffix
+	mover := "move-dev2-" + suffix
+	for _, team := range []models.Team{
+		{
+			TeamName:     "move-from-" + suffix,
+			MaxReviewers: 1,
+			Members: []models.TeamMember{
+				{UserID: author, Username: "Move Developer 1", IsActive: true},
+				{UserID: mover, Username: "Move Developer 2", IsActive: true},
+			},
+		},
+		{
+			TeamName: "move-to-" + suffix,
+			Members: []models.TeamMember{
+				{UserID: "move-dev3-" + suffix, Username: "Move Developer 3", IsActive: true},
+			},
+		},
+	} {
+		teamJSON, _ := json.Marshal(team)
+		resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+		assert.NoError(t, err)
+		assert.Equal(t, 201, resp.StatusCode)
+	}
+
+	prID := "pr-move-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Move User",
+		"author_id":         author,
+	})
+	resp, err := client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	moveJSON, _ := json.Marshal(map[string]interface{}{
+		"user_id":          mover,
+		"team_name":        "move-to-" + suffix,
+		"hand_off_reviews": true,
+	})
+	resp, err = client.Post(baseURL+"/users/moveTeam", "application/json", bytes.NewBuffer(moveJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var moveResponse models.MoveUserResponse
+	json.NewDecoder(resp.Body).Decode(&moveResponse)
+	assert.Equal(t, "move-from-"+suffix, moveResponse.PreviousTeam)
+	assert.Equal(t, "move-to-"+suffix, moveResponse.NewTeam)
+	if assert.Len(t, moveResponse.Handoffs, 1) {
+		assert.Equal(t, prID, moveResponse.Handoffs[0].PullRequestID)
+		assert.Equal(t, "", moveResponse.Handoffs[0].NewReviewerID)
+	}
+
+	resp, err = client.Post(baseURL+"/users/moveTeam", "application/json", bytes.NewBuffer(moveJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+}
+
+func TestMultipleTeamMembership(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	shared := "multi-shared-" + suffix
+	backendDev := "multi-backend-" + suffix
+	platformDev := "multi-platform-" + suffix
+	for _, team := range []models.Team{
+		{
+			TeamName: "multi-backend-" + suffix,
+			Members: []models.TeamMember{
+				{UserID: backendDev, Username: "Backend Developer", IsActive: true},
+				{UserID: shared, Username: "Shared Developer", IsActive: true},
+			},
+		},
+		{
+			TeamName: "multi-platform-" + suffix,
+			Members: []models.TeamMember{
+				{UserID: platformDev, Username: "Platform Developer", IsActive: true},
+				{UserID: shared, Username: "Shared Developer", IsActive: true},
+			},
+		},
+	} {
+		teamJSON, _ := json.Marshal(team)
+		resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+		assert.NoError(t, err)
+		assert.Equal(t, 201, resp.StatusCode)
+	}
+
+	resp, err := client.Get(baseURL + "/team/get?team_name=multi-platform-" + suffix)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var team models.Team
+	json.NewDecoder(resp.Body).Decode(&team)
+	assert.Len(t, team.Members, 2)
+	for _, member := range team.Members {
+		if member.UserID == shared {
+			assert.ElementsMatch(t, []string{"multi-backend-" + suffix, "multi-platform-" + suffix}, member.Teams)
+		}
+	}
+
+	prID := "pr-multi-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Platform Change",
+		"author_id":         platformDev,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var prResponse struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.NewDecoder(resp.Body).Decode(&prResponse)
+	assert.Equal(t, "multi-platform-"+suffix, prResponse.PR.TeamName)
+	assert.JSONEq(t, fmt.Sprintf(`["%s"]`, shared), string(prResponse.PR.AssignedReviewers))
+
+	resp, err = client.Get(baseURL + "/users/getReview?user_id=" + shared)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var reviews models.UserReviewResponse
+	json.NewDecoder(resp.Body).Decode(&reviews)
+	assert.Len(t, reviews.Teams, 2)
+	if assert.Len(t, reviews.PullRequests, 1) {
+		assert.Equal(t, "multi-platform-"+suffix, reviews.PullRequests[0].TeamName)
+	}
+
+	prJSON, _ = json.Marshal(map[string]string{
+		"pull_request_id":   "pr-multi-other-" + suffix,
+		"pull_request_name": "Wrong Team",
+		"author_id":         backendDev,
+		"team_name":         "multi-platform-" + suffix,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+}
+
+func TestTeamRolesWorkflow(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	author := "roles-dev-" + suffix
+	lead := "roles-lead-" + suffix
+	observer := "roles-observer-" + suffix
+	team := models.Team{
+		TeamName:     "roles-" + suffix,
+		MaxReviewers: 1,
+		Members: []models.TeamMember{
+			{UserID: author, Username: "Roles Developer", IsActive: true},
+			{UserID: lead, Username: "Roles Lead", IsActive: true, Role: models.MembershipRoleLead},
+			{UserID: observer, Username: "Roles Observer", IsActive: true, Role: models.MembershipRoleObserver},
+		},
+	}
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=roles-" + suffix)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var fetched models.Team
+	json.NewDecoder(resp.Body).Decode(&fetched)
+	assert.Equal(t, lead, fetched.LeadUserID)
+	for _, member := range fetched.Members {
+		if member.UserID == observer {
+			assert.Equal(t, models.MembershipRoleObserver, member.Role)
+		}
+	}
+
+	// The observer is never picked automatically, so the lead gets the review.
+	prID := "pr-roles-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Roles",
+		"author_id":         author,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var prResponse struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.NewDecoder(resp.Body).Decode(&prResponse)
+	assert.JSONEq(t, fmt.Sprintf(`["%s"]`, lead), string(prResponse.PR.AssignedReviewers))
+
+	addJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":      prID,
+		"reviewer_id":          observer,
+		"override_approved_by": author,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/addReviewer", "application/json", bytes.NewBuffer(addJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 403, resp.StatusCode)
+
+	addJSON, _ = json.Marshal(map[string]string{
+		"pull_request_id":      prID,
+		"reviewer_id":          observer,
+		"override_approved_by": lead,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/addReviewer", "application/json", bytes.NewBuffer(addJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestHierarchicalTeams(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	group := "tree-group-" + suffix
+	author := "tree-author-" + suffix
+	sibling := "tree-sibling-" + suffix
+	for _, team := range []models.Team{
+		{
+			TeamName: group,
+			Members:  []models.TeamMember{{UserID: "tree-manager-" + suffix, Username: "Tree Manager", IsActive: true}},
+		},
+		{
+			TeamName:       "tree-solo-" + suffix,
+			ParentTeamName: group,
+			Members:        []models.TeamMember{{UserID: author, Username: "Tree Author", IsActive: true}},
+		},
+		{
+			TeamName:       "tree-sibling-" + suffix,
+			ParentTeamName: group,
+			Members:        []models.TeamMember{{UserID: sibling, Username: "Tree Sibling", IsActive: true}},
+		},
+	} {
+		teamJSON, _ := json.Marshal(team)
+		resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+		assert.NoError(t, err)
+		assert.Equal(t, 201, resp.StatusCode)
+	}
+
+	resp, err := client.Get(baseURL + "/team/get?include_descendants=true&team_name=" + group)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var tree models.Team
+	json.NewDecoder(resp.Body).Decode(&tree)
+	assert.Len(t, tree.SubTeams, 2)
+
+	// The author is alone in their team, so the review falls back to the
+	// sibling team of the same group.
+	prID := "pr-tree-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Tree",
+		"author_id":         author,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var prResponse struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.NewDecoder(resp.Body).Decode(&prResponse)
+	assert.JSONEq(t, fmt.Sprintf(`["%s"]`, sibling), string(prResponse.PR.AssignedReviewers))
+
+	resp, err = client.Get(baseURL + "/stats/reviewers?team_name=" + group)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var stats models.StatsResponse
+	json.NewDecoder(resp.Body).Decode(&stats)
+	assert.Len(t, stats.Teams, 3)
+	if assert.Len(t, stats.ReviewerStats, 1) {
+		assert.Equal(t, sibling, stats.ReviewerStats[0].UserID)
+	}
+}
+
+func TestListUsersPagination(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	teamName := "list-" + suffix
+	team := models.Team{TeamName: teamName}
+	for i := 0; i < 5; i++ {
+		team.Members = append(team.Members, models.TeamMember{
+			UserID:   fmt.Sprintf("list-%s-%d", suffix, i),
+			Username: fmt.Sprintf("List User %d", i),
+			IsActive: true,
+		})
+	}
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var userIDs []string
+	cursor := ""
+	for page := 0; page < 5; page++ {
+		resp, err := client.Get(baseURL + "/users/list?limit=2&sort=-user_id&team_name=" + teamName + "&cursor=" + cursor)
+		assert.NoError(t, err)
+		assert.Equal(t, 200, resp.StatusCode)
+
+		var list models.ListUsersResponse
+		json.NewDecoder(resp.Body).Decode(&list)
+		for _, user := range list.Users {
+			userIDs = append(userIDs, user.UserID)
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		cursor = list.NextCursor
+	}
+	assert.Equal(t, []string{
+		"list-" + suffix + "-4", "list-" + suffix + "-3", "list-" + suffix + "-2", "list-" + suffix + "-1", "list-" + suffix + "-0",
+	}, userIDs)
+
+	resp, err = client.Get(baseURL + "/team/list?search=" + teamName)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var teams models.ListTeamsResponse
+	json.NewDecoder(resp.Body).Decode(&teams)
+	if assert.Len(t, teams.Teams, 1) {
+		assert.Equal(t, 5, teams.Teams[0].MemberCount)
+	}
+
+	resp, err = client.Get(baseURL + "/users/list?sort=password")
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+}
+
+func TestBulkImport(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	parent := "import-org-" + suffix
+	child := "import-team-" + suffix
+	document := fmt.Sprintf(`
+teams:
+  - team_name: %[2]s
+    parent_team_name: %[1]s
+    lead_user_id: import-%[3]s-1
+    members:
+      - {user_id: import-%[3]s-1, username: Import Lead}
+      - {user_id: import-%[3]s-2, username: Import Member}
+  - team_name: %[1]s
+    members: []
+`, parent, child, suffix)
+
+	resp, err := client.Post(baseURL+"/admin/import?dry_run=true", "application/yaml", bytes.NewBufferString(document))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var plan models.ImportResponse
+	json.NewDecoder(resp.Body).Decode(&plan)
+	assert.True(t, plan.DryRun)
+	if assert.NotEmpty(t, plan.Changes) {
+		assert.Equal(t, models.RosterChange{Action: models.RosterCreateTeam, TeamName: parent}, plan.Changes[0])
+	}
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=" + child)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+
+	resp, err = client.Post(baseURL+"/admin/import", "application/yaml", bytes.NewBufferString(document))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=" + child)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var team models.Team
+	json.NewDecoder(resp.Body).Decode(&team)
+	assert.Equal(t, parent, team.ParentTeamName)
+	assert.Equal(t, "import-"+suffix+"-1", team.LeadUserID)
+	assert.Len(t, team.Members, 2)
+
+	csvDocument := "team_name,user_id,username,role\n" +
+		child + ",import-" + suffix + "-3,Import Observer,OBSERVER\n" +
+		"import-missing-" + suffix + ",import-" + suffix + "-4,Nobody,CAPTAIN\n"
+	resp, err = client.Post(baseURL+"/admin/import?format=csv", "text/csv", bytes.NewBufferString(csvDocument))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=" + child)
+	assert.NoError(t, err)
+	json.NewDecoder(resp.Body).Decode(&team)
+	assert.Len(t, team.Members, 2)
+}
+
+func TestReconcileRoster(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	teamName := "roster-" + suffix
+	lead := "roster-" + suffix + "-lead"
+	dev := "roster-" + suffix + "-dev"
+	leaver := "roster-" + suffix + "-leaver"
+
+	team := models.Team{TeamName: teamName, Members: []models.TeamMember{
+		{UserID: lead, Username: "Roster Lead", IsActive: true},
+		{UserID: dev, Username: "Roster Dev", IsActive: true},
+		{UserID: leaver, Username: "Roster Leaver", IsActive: true},
+	}}
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	spec := fmt.Sprintf(`
+teams:
+  - team_name: %s
+    lead_user_id: %s
+    members:
+      - {user_id: %s, username: Roster Lead, capacity: 3}
+      - {user_id: %s, username: Roster Dev, ooo_from: 2020-01-01T00:00:00Z, ooo_until: 2099-01-01T00:00:00Z}
+`, teamName, lead, lead, dev)
+
+	resp, err = client.Post(baseURL+"/admin/reconcile", "application/yaml", bytes.NewBufferString(spec))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var plan models.ReconcileResponse
+	json.NewDecoder(resp.Body).Decode(&plan)
+	assert.False(t, plan.Applied)
+	assert.Contains(t, plan.Changes, models.RosterChange{Action: models.RosterDeactivateMember, TeamName: teamName, UserID: leaver})
+	assert.Contains(t, plan.Changes, models.RosterChange{Action: models.RosterUpdateUser, TeamName: teamName, UserID: lead, Fields: []string{"capacity"}})
+
+	resp, err = client.Post(baseURL+"/admin/reconcile?apply=true", "application/yaml", bytes.NewBufferString(spec))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=" + teamName)
+	assert.NoError(t, err)
+	json.NewDecoder(resp.Body).Decode(&team)
+	assert.Equal(t, lead, team.LeadUserID)
+	for _, member := range team.Members {
+		assert.Equal(t, member.UserID != leaver, member.IsActive, member.UserID)
+	}
+
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   "pr-roster-" + suffix,
+		"pull_request_name": "Roster Feature",
+		"author_id":         lead,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var created struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	assert.JSONEq(t, "[]", string(created.PR.AssignedReviewers))
+
+	resp, err = client.Post(baseURL+"/admin/reconcile", "application/yaml", bytes.NewBufferString(spec))
+	assert.NoError(t, err)
+	json.NewDecoder(resp.Body).Decode(&plan)
+	assert.Empty(t, plan.Changes)
+}
+
+func TestSCIMProvisioning(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	userName := "scim-" + suffix + "@example.com"
+	groupName := "scim-group-" + suffix
+
+	userJSON := fmt.Sprintf(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": %q,
+		"name": {"givenName": "Scim", "familyName": "User"},
+		"emails": [{"value": %q, "type": "work", "primary": true}],
+		"active": true
+	}`, userName, userName)
+	resp, err := client.Post(baseURL+"/scim/v2/Users", "application/scim+json", bytes.NewBufferString(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	var user models.SCIMUser
+	json.NewDecoder(resp.Body).Decode(&user)
+	assert.Equal(t, userName, user.ID)
+	assert.Equal(t, "Scim User", user.DisplayName)
+
+	resp, err = client.Post(baseURL+"/scim/v2/Users", "application/scim+json", bytes.NewBufferString(userJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 409, resp.StatusCode)
+
+	groupJSON := fmt.Sprintf(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:Group"],
+		"displayName": %q,
+		"members": [{"value": %q}]
+	}`, groupName, userName)
+	resp, err = client.Post(baseURL+"/scim/v2/Groups", "application/scim+json", bytes.NewBufferString(groupJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/team/get?team_name=" + groupName)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var team models.Team
+	json.NewDecoder(resp.Body).Decode(&team)
+	if assert.Len(t, team.Members, 1) {
+		assert.Equal(t, userName, team.Members[0].UserID)
+	}
+
+	patchJSON := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [{"op": "Replace", "path": "active", "value": "False"}]
+	}`
+	request, _ := http.NewRequest(http.MethodPatch, baseURL+"/scim/v2/Users/"+userName, bytes.NewBufferString(patchJSON))
+	request.Header.Set("Content-Type", "application/scim+json")
+	resp, err = client.Do(request)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	json.NewDecoder(resp.Body).Decode(&user)
+	if assert.NotNil(t, user.Active) {
+		assert.False(t, *user.Active)
+	}
+
+	resp, err = client.Get(baseURL + "/scim/v2/Users?filter=" + url.QueryEscape(`userName eq "`+userName+`"`))
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var list struct {
+		TotalResults int               `json:"totalResults"`
+		Resources    []models.SCIMUser `json:"Resources"`
+	}
+	json.NewDecoder(resp.Body).Decode(&list)
+	assert.Equal(t, 1, list.TotalResults)
+
+	request, _ = http.NewRequest(http.MethodDelete, baseURL+"/scim/v2/Groups/"+groupName, nil)
+	resp, err = client.Do(request)
+	assert.NoError(t, err)
+	assert.Equal(t, 204, resp.StatusCode)
+
+	resp, err = client.Get(baseURL + "/scim/v2/Groups/" + groupName)
+	assert.NoError(t, err)
+	assert.Equal(t, 404, resp.StatusCode)
+}
+
+func TestPullRequestVersioning(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	team := models.Team{TeamName: "version-team-" + suffix, MinReviewers: 1, MaxReviewers: 2}
+	for i := 1; i <= 6; i++ {
+		userID := fmt.Sprintf("version-dev%d-%s", i, suffix)
+		team.Members = append(team.Members, models.TeamMember{UserID: userID, Username: userID, IsActive: true})
+	}
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	prID := "pr-version-" + suffix
+	prJSON, _ := json.Marshal(map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Versioned",
+		"author_id":         team.Members[0].UserID,
+	})
+	resp, err = client.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	var created struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.NewDecoder(resp.Body).Decode(&created)
+	var reviewers []string
+	json.Unmarshal(created.PR.AssignedReviewers, &reviewers)
+	if !assert.Len(t, reviewers, 2) {
+		return
+	}
+
+	post := func(path string, body interface{}, ifMatch string) *http.Response {
+		data, _ := json.Marshal(body)
+		request, _ := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewBuffer(data))
+		request.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			request.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := client.Do(request)
+		if !assert.NoError(t, err) {
+			return &http.Response{Header: http.Header{}, Body: http.NoBody}
+		}
+		return resp
+	}
+
+	reassign := map[string]string{"pull_request_id": prID, "old_reviewer_id": reviewers[0]}
+	resp = post("/pullRequest/reassign", reassign, `"1"`)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, `"2"`, resp.Header.Get("ETag"))
+
+	resp = post("/pullRequest/reassign", map[string]string{"pull_request_id": prID, "old_reviewer_id": reviewers[1]}, `"1"`)
+	assert.Equal(t, 412, resp.StatusCode)
+	var errorResponse models.ErrorResponse
+	json.NewDecoder(resp.Body).Decode(&errorResponse)
+	assert.Equal(t, "VERSION_MISMATCH", errorResponse.Error.Code)
+
+	// Reassigning the same reviewer in parallel without If-Match must succeed
+	// exactly once; the others find the reviewer already replaced.
+	version := 2
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp := post("/pullRequest/reassign", map[string]string{"pull_request_id": prID, "old_reviewer_id": reviewers[1]}, "")
+			if resp.StatusCode == 200 {
+				mu.Lock()
+				version++
+				mu.Unlock()
+				return
+			}
+			assert.Equal(t, 409, resp.StatusCode)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 3, version)
+
+	resp = post("/pullRequest/merge", map[string]string{"pull_request_id": prID}, `"3"`)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, `"4"`, resp.Header.Get("ETag"))
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	team := models.Team{TeamName: "idempotency-team-" + suffix, MinReviewers: 1, MaxReviewers: 2}
+	for i := 1; i <= 5; i++ {
+		userID := fmt.Sprintf("idempotency-dev%d-%s", i, suffix)
+		team.Members = append(team.Members, models.TeamMember{UserID: userID, Username: userID, IsActive: true})
+	}
+	teamJSON, _ := json.Marshal(team)
+	resp, err := client.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+
+	post := func(path string, body interface{}, key string) (*http.Response, []byte) {
+		data, _ := json.Marshal(body)
+		request, _ := http.NewRequest(http.MethodPost, baseURL+path, bytes.NewBuffer(data))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Idempotency-Key", key)
+		resp, err := client.Do(request)
+		if !assert.NoError(t, err) {
+			return &http.Response{Header: http.Header{}, Body: http.NoBody}, nil
+		}
+		defer resp.Body.Close()
+		var buf bytes.Buffer
+		buf.ReadFrom(resp.Body)
+		return resp, buf.Bytes()
+	}
+
+	prID := "pr-idempotency-" + suffix
+	create := map[string]string{
+		"pull_request_id":   prID,
+		"pull_request_name": "Idempotent",
+		"author_id":         team.Members[0].UserID,
+	}
+	first, firstBody := post("/pullRequest/create", create, "create-"+suffix)
+	assert.Equal(t, 201, first.StatusCode)
+	assert.Empty(t, first.Header.Get("Idempotent-Replayed"))
+
+	second, secondBody := post("/pullRequest/create", create, "create-"+suffix)
+	assert.Equal(t, 201, second.StatusCode)
+	assert.Equal(t, "true", second.Header.Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Header.Get("ETag"), second.Header.Get("ETag"))
+	assert.JSONEq(t, string(firstBody), string(secondBody))
+
+	var created struct {
+		PR models.PullRequest `json:"pr"`
+	}
+	json.Unmarshal(firstBody, &created)
+	var reviewers []string
+	json.Unmarshal(created.PR.AssignedReviewers, &reviewers)
+	if !assert.NotEmpty(t, reviewers) {
+		return
+	}
+
+	// A retried reassignment must not pick yet another reviewer.
+	reassign := map[string]string{"pull_request_id": prID, "old_reviewer_id": reviewers[0]}
+	resp, firstBody = post("/pullRequest/reassign", reassign, "reassign-"+suffix)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp, secondBody = post("/pullRequest/reassign", reassign, "reassign-"+suffix)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get("Idempotent-Replayed"))
+	assert.JSONEq(t, string(firstBody), string(secondBody))
+
+	create["pull_request_id"] = prID + "-other"
+	resp, body := post("/pullRequest/create", create, "create-"+suffix)
+	assert.Equal(t, 422, resp.StatusCode)
+	var errorResponse models.ErrorResponse
+	json.Unmarshal(body, &errorResponse)
+	assert.Equal(t, "IDEMPOTENCY_KEY_REUSED", errorResponse.Error.Code)
+}
+
+func TestErrorCatalog(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	send := func(method, path, body string) (int, models.ErrorResponse) {
+		request, _ := http.NewRequest(method, baseURL+path, bytes.NewBufferString(body))
+		request.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(request)
+		var errorResponse models.ErrorResponse
+		if !assert.NoError(t, err) {
+			return 0, errorResponse
+		}
+		defer resp.Body.Close()
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		return resp.StatusCode, errorResponse
+	}
+
+	status, errorResponse := send(http.MethodPost, "/pullRequest/create", `{"pull_request_id": `)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "VALIDATION_ERROR", errorResponse.Error.Code)
+
+	status, errorResponse = send(http.MethodPost, "/pullRequest/create", `{"pull_request_id": "pr-catalog"}`)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "VALIDATION_ERROR", errorResponse.Error.Code)
+	assert.Equal(t, []models.FieldError{
+		{Field: "pull_request_name", Message: "is required"},
+		{Field: "author_id", Message: "is required"},
+	}, errorResponse.Error.Details)
+
+	status, errorResponse = send(http.MethodPost, "/pullRequest/merge", `{"pull_request_id": 42}`)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, []models.FieldError{{Field: "pull_request_id", Message: "must be a string"}}, errorResponse.Error.Details)
+
+	status, errorResponse = send(http.MethodPost, "/team/add", `{
+		"team_name": "catalog-team",
+		"min_reviewers": 3,
+		"max_reviewers": 2,
+		"members": [{"user_id": "catalog-dev1", "role": "OWNER"}]
+	}`)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "VALIDATION_ERROR", errorResponse.Error.Code)
+	assert.Equal(t, []models.FieldError{
+		{Field: "min_reviewers", Message: "must be between 0 and max_reviewers"},
+		{Field: "members[0].role", Message: "must be LEAD, MEMBER or OBSERVER"},
+	}, errorResponse.Error.Details)
+
+	status, errorResponse = send(http.MethodGet, "/users/list?limit=500", "")
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "INVALID_QUERY", errorResponse.Error.Code)
+	assert.Equal(t, []models.FieldError{{Field: "limit", Message: "must be between 1 and 200"}}, errorResponse.Error.Details)
+
+	status, errorResponse = send(http.MethodPost, "/pullRequest/merge", `{"pull_request_id": "nonexistent-pr"}`)
+	assert.Equal(t, 404, status)
+	assert.Equal(t, "NOT_FOUND", errorResponse.Error.Code)
+	assert.Equal(t, "PR not found", errorResponse.Error.Message)
+	assert.Empty(t, errorResponse.Error.Details)
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	resp, err := client.Get(baseURL + "/openapi.json")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	var spec struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	json.NewDecoder(resp.Body).Decode(&spec)
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	assert.Contains(t, spec.Paths, "/pullRequest/create")
+	assert.Contains(t, spec.Paths, "/scim/v2/Groups/{id}")
+
+	resp, err = client.Get(baseURL + "/docs")
+	assert.NoError(t, err)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+}
+
+func TestV2Workflow(t *testing.T) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	send := func(method, path string, body interface{}) *http.Response {
+		var data []byte
+		if body != nil {
+			data, _ = json.Marshal(body)
+		}
+		request, _ := http.NewRequest(method, baseURL+path, bytes.NewBuffer(data))
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := client.Do(request)
+		if !assert.NoError(t, err) {
+			return &http.Response{Header: http.Header{}, Body: http.NoBody}
+		}
+		return resp
+	}
+
+	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
+	team := models.Team{TeamName: "v2-team-" + suffix}
+	for i := 1; i <= 4; i++ {
+		userID := fmt.Sprintf("v2-dev%d-%s", i, suffix)
+		team.Members = append(team.Members, models.TeamMember{UserID: userID, Username: userID, IsActive: true})
+	}
+	resp := send(http.MethodPost, "/v2/teams", team)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "/v2/teams/"+team.TeamName, resp.Header.Get("Location"))
+
+	// Teams created through v2 are visible through v1.
+	resp = send(http.MethodGet, "/team/get?team_name="+team.TeamName, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+
+	author := team.Members[0].UserID
+	for i := 1; i <= 3; i++ {
+		resp = send(http.MethodPost, "/v2/pull-requests", map[string]string{
+			"pull_request_id":   fmt.Sprintf("pr-v2-%d-%s", i, suffix),
+			"pull_request_name": "Feature",
+			"author_id":         author,
+		})
+		assert.Equal(t, 201, resp.StatusCode)
+	}
+
+	prID := "pr-v2-1-" + suffix
+	resp = send(http.MethodGet, "/v2/pull-requests/"+prID, nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+	var pr models.PullRequestV2
+	json.NewDecoder(resp.Body).Decode(&pr)
+	assert.Equal(t, team.TeamName, pr.TeamName)
+	assert.False(t, pr.CreatedAt.IsZero())
+	if !assert.Len(t, pr.AssignedReviewers, 2) {
+		return
+	}
+
+	var page struct {
+		Items      []models.PullRequestV2 `json:"items"`
+		NextCursor string                 `json:"next_cursor"`
+	}
+	query := url.Values{"author_id": {author}, "limit": {"2"}}
+	resp = send(http.MethodGet, "/v2/pull-requests?"+query.Encode(), nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	json.NewDecoder(resp.Body).Decode(&page)
+	assert.Len(t, page.Items, 2)
+	if !assert.NotEmpty(t, page.NextCursor) {
+		return
+	}
+	query.Set("cursor", page.NextCursor)
+	page.NextCursor = ""
+	resp = send(http.MethodGet, "/v2/pull-requests?"+query.Encode(), nil)
+	json.NewDecoder(resp.Body).Decode(&page)
+	assert.Len(t, page.Items, 1)
+	assert.Empty(t, page.NextCursor)
+
+	reviewer := pr.AssignedReviewers[0]
+	resp = send(http.MethodPost, "/v2/pull-requests/"+prID+"/reviewers/"+reviewer+"/decline", map[string]string{"reason": "BUSY"})
+	assert.Equal(t, 200, resp.StatusCode)
+	var replacement models.ReplacementV2
+	json.NewDecoder(resp.Body).Decode(&replacement)
+	assert.NotEmpty(t, replacement.ReplacedBy)
+	assert.NotContains(t, replacement.PullRequest.AssignedReviewers, reviewer)
+
+	resp = send(http.MethodGet, "/v2/users/"+replacement.ReplacedBy+"/reviews", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	page.Items = nil
+	json.NewDecoder(resp.Body).Decode(&page)
+	reviewed := []string{}
+	for _, item := range page.Items {
+		reviewed = append(reviewed, item.PullRequestID)
+	}
+	assert.Contains(t, reviewed, prID)
+
+	resp = send(http.MethodPatch, "/v2/pull-requests/"+prID, map[string]string{"status": "MERGED"})
+	assert.Equal(t, 200, resp.StatusCode)
+	json.NewDecoder(resp.Body).Decode(&pr)
+	assert.Equal(t, "MERGED", pr.Status)
+	assert.NotNil(t, pr.MergedAt)
+
+	resp = send(http.MethodPatch, "/v2/users/"+reviewer, map[string]bool{"is_active": false})
+	assert.Equal(t, 200, resp.StatusCode)
+	var user models.User
+	json.NewDecoder(resp.Body).Decode(&user)
+	assert.False(t, user.IsActive)
+
+	resp = send(http.MethodDelete, "/v2/teams/"+team.TeamName+"?policy=CASCADE", nil)
+	assert.Equal(t, 200, resp.StatusCode)
+	resp = send(http.MethodGet, "/v2/teams/"+team.TeamName, nil)
+	assert.Equal(t, 404, resp.StatusCode)
+}